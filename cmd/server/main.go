@@ -1,89 +1,115 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/config"
 	"github.com/iafnetworkspa/bc-odata-mcp/internal/mcp"
 )
 
 func main() {
-	// Parse command line flags
-	configPath := flag.String("config", "", "Path to configuration file (optional, uses environment variables by default)")
+	// Parse command line flags; when set they override the configuration file and environment
+	configPath := flag.String("config", "", "Path to a YAML, JSON or TOML configuration file (optional, environment variables override it)")
+	transportName := flag.String("transport", "stdio", "Transport to serve MCP on: stdio or http (MCP_TRANSPORT)")
+	httpAddr := flag.String("http-addr", "127.0.0.1:8080", "Listen address for the http transport (MCP_HTTP_ADDR)")
+	maxConcurrency := flag.Int("max-concurrency", 8, "Maximum number of requests processed at the same time, 0 = unlimited (MCP_MAX_CONCURRENCY)")
+	promptsPath := flag.String("prompts", "", "Path to a YAML file with additional prompts (MCP_PROMPTS_FILE)")
+	toolTimeout := flag.Duration("tool-timeout", 0, "Deadline for a single tool call, e.g. 5m, 0 = none (MCP_TOOL_TIMEOUT)")
 	flag.Parse()
 
 	// Load configuration
-	cfg, err := loadConfig(*configPath)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "transport":
+			cfg.Server.Transport = *transportName
+		case "http-addr":
+			cfg.Server.HTTPAddr = *httpAddr
+		case "max-concurrency":
+			cfg.Server.MaxConcurrency = *maxConcurrency
+		case "prompts":
+			cfg.Server.PromptsFile = *promptsPath
+		case "tool-timeout":
+			cfg.Tools.Timeout = *toolTimeout
+		}
+	})
 
 	// Create and run MCP server
-	server, err := mcp.NewServer(cfg)
+	server, err := mcp.NewServer(cfg.ClientConfig())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating server: %v\n", err)
 		os.Exit(1)
 	}
-
-	if err := server.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
-		os.Exit(1)
+	for _, name := range cfg.ProfileNames() {
+		profileConfig, err := cfg.ProfileConfig(name)
+		if err == nil {
+			err = server.AddProfile(name, profileConfig)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding profile %s: %v\n", name, err)
+			os.Exit(1)
+		}
 	}
-}
 
-// loadConfig loads configuration from environment variables
-func loadConfig(configPath string) (bc.Config, error) {
-	cfg := bc.Config{
-		GrantType:    getEnv("BC_GRANT_TYPE", "client_credentials"),
-		ClientID:     getEnv("BC_CLIENT_ID", ""),
-		ClientSecret: getEnv("BC_CLIENT_SECRET", ""),
-		ScopeAPI:     getEnv("BC_SCOPE_API", ""),
-		TokenURL:     getEnv("BC_TOKEN_URL", ""),
-		ContentType:  getEnv("BC_CONTENT_TYPE", "application/x-www-form-urlencoded"),
-		BasePath:     getEnv("BC_BASE_PATH", ""),
-		TenantID:     getEnv("BC_TENANT_ID", ""),
-		Environment:  getEnv("BC_ENVIRONMENT", "Production"),
-		Company:      getEnv("BC_COMPANY", ""),
-		APITimeout:   getEnvInt("BC_API_TIMEOUT", 90),
+	if cfg.Server.PromptsFile != "" {
+		if err := server.LoadPrompts(cfg.Server.PromptsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading prompts: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Validate required fields
-	if cfg.ClientID == "" {
-		return cfg, fmt.Errorf("BC_CLIENT_ID is required")
+	server.SetMaxConcurrency(cfg.Server.MaxConcurrency)
+	server.SetMetadataCacheTTL(cfg.Cache.MetadataTTL)
+	policy := mcp.Policy{
+		ReadOnly:   cfg.Tools.ReadOnly,
+		AllowTools: cfg.Tools.Allow,
+		DenyTools:  cfg.Tools.Deny,
+		EntitySets: make(map[string]mcp.EntitySetPermissions, len(cfg.Tools.EntitySets)),
 	}
-	if cfg.ClientSecret == "" {
-		return cfg, fmt.Errorf("BC_CLIENT_SECRET is required")
+	for name, writes := range cfg.Tools.EntitySets {
+		policy.EntitySets[name] = mcp.EntitySetPermissions{Allow: writes.Allow, Deny: writes.Deny, RequireConfirmation: writes.Confirm}
 	}
-	if cfg.ScopeAPI == "" {
-		return cfg, fmt.Errorf("BC_SCOPE_API is required")
-	}
-	if cfg.TokenURL == "" {
-		return cfg, fmt.Errorf("BC_TOKEN_URL is required")
+	if err := server.SetPolicy(policy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error in tools configuration: %v\n", err)
+		os.Exit(1)
 	}
-	if cfg.BasePath == "" {
-		return cfg, fmt.Errorf("BC_BASE_PATH is required")
+	server.SetToolTimeout(cfg.Tools.Timeout)
+	for tool, timeout := range cfg.Tools.Timeouts {
+		if err := server.SetToolTimeoutFor(tool, timeout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error in tools configuration: timeouts: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	return cfg, nil
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	var transport mcp.Transport
+	switch cfg.Server.Transport {
+	case "stdio":
+		transport = mcp.NewStdioTransport(os.Stdin, os.Stdout)
+	case "http":
+		transport = mcp.NewHTTPTransport(mcp.HTTPOptions{
+			Addr:           cfg.Server.HTTPAddr,
+			AuthToken:      cfg.Server.HTTPToken,
+			AllowedOrigins: cfg.Server.AllowedOrigins,
+		})
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown transport %q (use stdio or http)\n", cfg.Server.Transport)
+		os.Exit(1)
 	}
-	return defaultValue
-}
 
-func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		var intVal int
-		if _, err := fmt.Sscanf(value, "%d", &intVal); err == nil {
-			return intVal
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Serve(ctx, transport); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running server: %v\n", err)
+		os.Exit(1)
 	}
-	return defaultValue
 }
-
@@ -2,7 +2,11 @@ module github.com/iafnetworkspa/bc-odata-mcp
 
 go 1.21
 
-require github.com/rs/zerolog v1.34.0
+require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/rs/zerolog v1.34.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
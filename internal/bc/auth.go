@@ -2,6 +2,7 @@ package bc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -42,6 +43,9 @@ type Config struct {
 	Environment  string
 	Company      string
 	APITimeout   int
+	MaxPageSize  int           // sent as Prefer: odata.maxpagesize; 0 leaves the page size to the service
+	MaxRetries   int           // attempts per request; 0 uses defaultMaxRetries
+	RetryBackoff time.Duration // wait before the first retry, doubled on each retry; 0 uses defaultRetryBackoff
 }
 
 // NewAuth creates a new Business Central authentication handler
@@ -58,8 +62,9 @@ func NewAuth(cfg Config) *Auth {
 	}
 }
 
-// GetToken retrieves or refreshes the OAuth token
-func (a *Auth) GetToken() (string, error) {
+// GetToken retrieves or refreshes the OAuth token; a token request in progress
+// is abandoned when ctx is cancelled
+func (a *Auth) GetToken(ctx context.Context) (string, error) {
 	a.mu.RLock()
 	// Check if we have a valid token (with 5 minute safety margin)
 	if a.token != "" && time.Now().Before(a.tokenExpiry.Add(-5*time.Minute)) {
@@ -72,11 +77,11 @@ func (a *Auth) GetToken() (string, error) {
 
 	// Need to get a new token
 	log.Info().Msg("Fetching new OAuth token from Business Central")
-	return a.refreshToken()
+	return a.refreshToken(ctx)
 }
 
 // refreshToken forces a token refresh (thread-safe)
-func (a *Auth) refreshToken() (string, error) {
+func (a *Auth) refreshToken(ctx context.Context) (string, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -86,7 +91,7 @@ func (a *Auth) refreshToken() (string, error) {
 		return a.token, nil
 	}
 
-	token, err := a.fetchToken()
+	token, err := a.fetchToken(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch OAuth token")
 		return "", fmt.Errorf("failed to fetch token: %w", err)
@@ -116,7 +121,7 @@ func (a *Auth) InvalidateToken() {
 }
 
 // fetchToken makes the OAuth token request
-func (a *Auth) fetchToken() (*TokenResponse, error) {
+func (a *Auth) fetchToken(ctx context.Context) (*TokenResponse, error) {
 	log.Debug().
 		Str("token_url", a.config.TokenURL).
 		Str("grant_type", a.config.GrantType).
@@ -131,7 +136,7 @@ func (a *Auth) fetchToken() (*TokenResponse, error) {
 	data.Set("client_secret", a.config.ClientSecret)
 	data.Set("scope", a.config.ScopeAPI)
 
-	req, err := http.NewRequest("POST", a.config.TokenURL, bytes.NewBufferString(data.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", a.config.TokenURL, bytes.NewBufferString(data.Encode()))
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create HTTP request")
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -2,6 +2,7 @@ package bc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -55,7 +56,7 @@ func TestAuth_GetToken_WithValidCachedToken(t *testing.T) {
 		tokenExpiry: time.Now().Add(10 * time.Minute),
 	}
 
-	token, err := auth.GetToken()
+	token, err := auth.GetToken(context.Background())
 	if err != nil {
 		t.Fatalf("GetToken() error = %v, want nil", err)
 	}
@@ -93,7 +94,7 @@ func TestAuth_GetToken_WithExpiredToken(t *testing.T) {
 	auth.token = "old-token"
 	auth.tokenExpiry = time.Now().Add(-1 * time.Hour) // Expired
 
-	token, err := auth.GetToken()
+	token, err := auth.GetToken(context.Background())
 	if err != nil {
 		t.Fatalf("GetToken() error = %v, want nil", err)
 	}
@@ -169,7 +170,7 @@ func TestAuth_fetchToken_Success(t *testing.T) {
 	}
 
 	auth := NewAuth(cfg)
-	tokenResp, err := auth.fetchToken()
+	tokenResp, err := auth.fetchToken(context.Background())
 	if err != nil {
 		t.Fatalf("fetchToken() error = %v, want nil", err)
 	}
@@ -199,7 +200,7 @@ func TestAuth_fetchToken_HTTPError(t *testing.T) {
 	}
 
 	auth := NewAuth(cfg)
-	_, err := auth.fetchToken()
+	_, err := auth.fetchToken(context.Background())
 	if err == nil {
 		t.Fatal("fetchToken() error = nil, want error")
 	}
@@ -223,7 +224,7 @@ func TestAuth_fetchToken_InvalidJSON(t *testing.T) {
 	}
 
 	auth := NewAuth(cfg)
-	_, err := auth.fetchToken()
+	_, err := auth.fetchToken(context.Background())
 	if err == nil {
 		t.Fatal("fetchToken() error = nil, want error")
 	}
@@ -256,7 +257,7 @@ func TestAuth_refreshToken_DoubleCheck(t *testing.T) {
 	auth.token = "old-token"
 	auth.tokenExpiry = time.Now().Add(-1 * time.Hour) // Expired
 
-	token, err := auth.refreshToken()
+	token, err := auth.refreshToken(context.Background())
 	if err != nil {
 		t.Fatalf("refreshToken() error = %v, want nil", err)
 	}
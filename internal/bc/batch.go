@@ -0,0 +1,145 @@
+package bc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MaxBatchRequests is the largest number of operations Business Central
+// accepts in one $batch request
+const MaxBatchRequests = 100
+
+// BatchRequest is one operation of a JSON $batch request
+type BatchRequest struct {
+	// ID identifies the operation within the batch; later operations reference
+	// it in DependsOn and in URLs such as $1/SalesLines
+	ID     string
+	Method string // GET, POST, PATCH, PUT or DELETE
+	// URL is relative to the company, e.g. Customers('10000'), or starts with
+	// $<id> to address the entity created or read by another operation
+	URL     string
+	Headers map[string]string // e.g. If-Match
+	Body    interface{}       // JSON body of writes, nil for GET and DELETE
+	// AtomicityGroup names a change set: its operations succeed or fail together
+	AtomicityGroup string
+	DependsOn      []string // operations or change sets that must succeed first
+}
+
+// BatchResponse is the result of one operation of a $batch request
+type BatchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchRequestJSON is the wire form of a BatchRequest
+type batchRequestJSON struct {
+	ID             string            `json:"id"`
+	Method         string            `json:"method"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           interface{}       `json:"body,omitempty"`
+	AtomicityGroup string            `json:"atomicityGroup,omitempty"`
+	DependsOn      []string          `json:"dependsOn,omitempty"`
+}
+
+// Batch sends operations in a single OData JSON $batch request and returns
+// the responses in the order of the requests. Business Central stops at the
+// first failed change set, so an operation can have no response; it gets
+// status 0. The request is not repeated after a server error, as parts of it
+// may have been applied.
+func (c *Client) Batch(ctx context.Context, requests []BatchRequest) ([]BatchResponse, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("batch requires at least one operation")
+	}
+	if len(requests) > MaxBatchRequests {
+		return nil, fmt.Errorf("batch has %d operations, the maximum is %d", len(requests), MaxBatchRequests)
+	}
+
+	// The batch is posted to the service root, so operation URLs are prefixed
+	// with the Company('...') segment of the client
+	companyPrefix := ""
+	if c.serviceRoot != "" {
+		companyPrefix = strings.TrimPrefix(c.baseURL, c.serviceRoot)
+	}
+
+	seen := make(map[string]bool, len(requests))
+	wire := make([]batchRequestJSON, 0, len(requests))
+	for i, r := range requests {
+		if r.ID == "" || seen[r.ID] {
+			return nil, fmt.Errorf("batch operation %d needs a unique id", i+1)
+		}
+		seen[r.ID] = true
+
+		headers := make(map[string]string, len(r.Headers)+1)
+		for name, value := range r.Headers {
+			headers[name] = value
+		}
+		if r.Body != nil {
+			headers["Content-Type"] = "application/json"
+		}
+		url := r.URL
+		if !strings.HasPrefix(url, "$") {
+			url = companyPrefix + strings.TrimPrefix(url, "/")
+		}
+		wire = append(wire, batchRequestJSON{
+			ID:             r.ID,
+			Method:         strings.ToUpper(r.Method),
+			URL:            url,
+			Headers:        headers,
+			Body:           r.Body,
+			AtomicityGroup: r.AtomicityGroup,
+			DependsOn:      r.DependsOn,
+		})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"requests": wire})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+	resp, err := c.do(ctx, request{
+		method:     http.MethodPost,
+		endpoint:   "$batch",
+		root:       true,
+		body:       data,
+		maxRetries: c.maxRetries(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newODataError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Responses []BatchResponse `json:"responses"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse batch response: %w", err)
+	}
+
+	byID := make(map[string]BatchResponse, len(result.Responses))
+	for _, r := range result.Responses {
+		byID[r.ID] = r
+	}
+	responses := make([]BatchResponse, len(requests))
+	for i, r := range requests {
+		response, ok := byID[r.ID]
+		if !ok {
+			response = BatchResponse{ID: r.ID}
+		}
+		responses[i] = response
+	}
+	return responses, nil
+}
@@ -0,0 +1,73 @@
+package bc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestClient_Batch(t *testing.T) {
+	var sent struct {
+		Requests []batchRequestJSON `json:"requests"`
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/$batch" {
+			t.Errorf("request = %s %s, want POST /$batch", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Fatalf("decode batch: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"responses":[
+			{"id":"1","status":201,"body":{"No":"1001"}},
+			{"id":"2","status":400,"body":{"error":{"code":"BadRequest","message":"Invalid line"}}}
+		]}`))
+	})
+	root := client.baseURL
+	client.serviceRoot = root
+	client.baseURL = root + CompanyPath("CRONUS")
+
+	responses, err := client.Batch(context.Background(), []BatchRequest{
+		{ID: "1", Method: "post", URL: "SalesOrders", Body: map[string]interface{}{"Sell_to_Customer_No": "10000"}, AtomicityGroup: "order"},
+		{ID: "2", Method: "POST", URL: "$1/SalesLines", Body: map[string]interface{}{"No": "1000"}, AtomicityGroup: "order", DependsOn: []string{"1"}},
+		{ID: "3", Method: "GET", URL: "Customers('10000')", DependsOn: []string{"order"}},
+	})
+	if err != nil {
+		t.Fatalf("Batch() error = %v", err)
+	}
+
+	if len(sent.Requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(sent.Requests))
+	}
+	first, second := sent.Requests[0], sent.Requests[1]
+	if first.Method != "POST" || first.URL != "Company('CRONUS')/SalesOrders" || first.Headers["Content-Type"] != "application/json" || first.AtomicityGroup != "order" {
+		t.Errorf("first request = %+v", first)
+	}
+	if second.URL != "$1/SalesLines" || len(second.DependsOn) != 1 || second.DependsOn[0] != "1" {
+		t.Errorf("second request = %+v, want the reference kept as is", second)
+	}
+
+	if len(responses) != 3 || responses[0].Status != 201 || responses[1].Status != 400 {
+		t.Errorf("responses = %+v", responses)
+	}
+	if responses[2].ID != "3" || responses[2].Status != 0 {
+		t.Errorf("operation without a response = %+v, want status 0", responses[2])
+	}
+}
+
+func TestClient_BatchValidation(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+	ctx := context.Background()
+	if _, err := client.Batch(ctx, nil); err == nil {
+		t.Error("Batch() of no operations error = nil")
+	}
+	if _, err := client.Batch(ctx, []BatchRequest{{ID: "1", Method: "GET", URL: "Items"}, {ID: "1", Method: "GET", URL: "Items"}}); err == nil {
+		t.Error("Batch() with duplicate ids error = nil")
+	}
+	if _, err := client.Batch(ctx, make([]BatchRequest, MaxBatchRequests+1)); err == nil {
+		t.Error("Batch() over the limit error = nil")
+	}
+}
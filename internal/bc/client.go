@@ -1,13 +1,11 @@
 package bc
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -19,14 +17,29 @@ import (
 type ODataResponse struct {
 	Value    []map[string]interface{} `json:"value"`
 	NextLink string                   `json:"@odata.nextLink,omitempty"`
+	Count    *int64                   `json:"@odata.count,omitempty"`
+}
+
+// ServiceDocumentEntry is a single resource listed in the OData service document
+type ServiceDocumentEntry struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+	URL  string `json:"url"`
+}
+
+// serviceDocument is the root response of an OData v4 service
+type serviceDocument struct {
+	Value []ServiceDocumentEntry `json:"value"`
 }
 
 // Client handles HTTP requests to Business Central API
 type Client struct {
-	config     Config
-	auth       *Auth
-	httpClient *http.Client
-	baseURL    string
+	config      Config
+	auth        *Auth
+	httpClient  *http.Client
+	baseURL     string
+	serviceRoot string // OData V4 service root, "" when companies cannot be selected
+	company     string // company addressed by baseURL, "" when unknown
 }
 
 // NewClient creates a new Business Central API client
@@ -35,453 +48,96 @@ func NewClient(cfg Config, auth *Auth) *Client {
 	if timeout == 0 {
 		timeout = 90
 	}
+	baseURL, serviceRoot, company := resolveBasePath(cfg.BasePath, cfg.Company)
 	return &Client{
 		config: cfg,
 		auth:   auth,
 		httpClient: &http.Client{
 			Timeout: time.Duration(timeout) * time.Second,
 		},
-		baseURL: cfg.BasePath,
+		baseURL:     baseURL,
+		serviceRoot: serviceRoot,
+		company:     company,
+	}
+}
+
+// Retry policy used when Config leaves it unset
+const (
+	defaultMaxRetries   = 5
+	defaultRetryBackoff = 2 * time.Second
+)
+
+// maxRetries returns the number of attempts per request
+func (c *Client) maxRetries() int {
+	if c.config.MaxRetries > 0 {
+		return c.config.MaxRetries
+	}
+	return defaultMaxRetries
+}
+
+// retryBackoff returns the wait before the given retry (1 for the first)
+func (c *Client) retryBackoff(retry int) time.Duration {
+	backoff := c.config.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
 	}
+	return time.Duration(1<<uint(retry-1)) * backoff
 }
 
 // Get makes a GET request to the Business Central API with automatic token handling
 func (c *Client) Get(ctx context.Context, endpoint string) (*http.Response, error) {
-	return c.GetWithRetry(ctx, endpoint, 5)
+	return c.GetWithRetry(ctx, endpoint, c.maxRetries())
 }
 
 // GetWithRetry makes a GET request with retry logic
 func (c *Client) GetWithRetry(ctx context.Context, endpoint string, maxRetries int) (*http.Response, error) {
-	log := log.With().
-		Str("component", "bc_client").
-		Str("endpoint", endpoint).
-		Int("max_retries", maxRetries).
-		Logger()
-
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff for non-rate-limit errors: 2s, 4s, 8s
-			backoff := time.Duration(1<<uint(attempt-1)) * 2 * time.Second
-			log.Warn().
-				Int("attempt", attempt+1).
-				Dur("backoff", backoff).
-				Err(lastErr).
-				Msg("Retrying API request after error")
-
-			select {
-			case <-ctx.Done():
-				log.Error().Err(ctx.Err()).Msg("Context cancelled during retry")
-				return nil, ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
-
-		log.Debug().
-			Int("attempt", attempt+1).
-			Msg("Getting OAuth token")
-
-		token, err := c.auth.GetToken()
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to get OAuth token")
-			return nil, fmt.Errorf("failed to get token: %w", err)
-		}
-
-		// Construct full URL
-		fullURL := c.baseURL + endpoint
-
-		// Parse URL to ensure proper encoding
-		parsedURL, err := url.Parse(fullURL)
-		if err != nil {
-			log.Error().Err(err).Str("url", fullURL).Msg("Failed to parse URL")
-			return nil, fmt.Errorf("failed to parse URL: %w", err)
-		}
-
-		fullURL = parsedURL.String()
-
-		log.Debug().
-			Str("url", fullURL).
-			Str("endpoint", endpoint).
-			Str("base_url", c.baseURL).
-			Msg("Creating HTTP request")
-
-		req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
-		if err != nil {
-			log.Error().Err(err).Str("url", fullURL).Msg("Failed to create HTTP request")
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Authorization", "Bearer "+token)
-		req.Header.Set("Accept", "application/json")
-
-		log.Debug().Msg("Sending HTTP request")
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			log.Warn().Err(err).Msg("HTTP request failed")
-			lastErr = err
-			continue
-		}
-
-		log.Debug().Int("status_code", resp.StatusCode).Msg("Received HTTP response")
-
-		// Check for unauthorized (401) - token may have expired, refresh and retry
-		if resp.StatusCode == http.StatusUnauthorized {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-
-			log.Warn().
-				Int("status_code", resp.StatusCode).
-				Str("status", resp.Status).
-				Str("response_body", string(bodyBytes)).
-				Msg("Unauthorized (401) - token may have expired, refreshing token")
-
-			// Invalidate current token
-			c.auth.InvalidateToken()
-
-			// Refresh token and retry
-			newToken, err := c.auth.GetToken()
-			if err != nil {
-				log.Error().Err(err).Msg("Failed to refresh token after 401")
-				lastErr = fmt.Errorf("failed to refresh token: %w", err)
-				continue
-			}
-
-			log.Info().Msg("Token refreshed successfully, retrying request")
-
-			// Update token in request and retry
-			req.Header.Set("Authorization", "Bearer "+newToken)
-
-			// Retry the request with new token
-			resp, err = c.httpClient.Do(req)
-			if err != nil {
-				log.Warn().Err(err).Msg("HTTP request failed after token refresh")
-				lastErr = err
-				continue
-			}
-
-			log.Debug().Int("status_code", resp.StatusCode).Msg("Received HTTP response after token refresh")
-		}
-
-		// Check for rate limiting (429) - needs special handling
-		if resp.StatusCode == http.StatusTooManyRequests {
-			// Try to read Retry-After header
-			retryAfter := resp.Header.Get("Retry-After")
-			var backoffDuration time.Duration
-
-			if retryAfter != "" {
-				// Parse Retry-After header (can be seconds or HTTP date)
-				if seconds, err := time.ParseDuration(retryAfter + "s"); err == nil {
-					backoffDuration = seconds
-				} else {
-					// Try parsing as integer seconds
-					if secs, err := strconv.ParseInt(retryAfter, 10, 64); err == nil {
-						backoffDuration = time.Duration(secs) * time.Second
-					} else {
-						// Fallback to exponential backoff
-						backoffDuration = time.Duration(attempt+1) * 5 * time.Second
-					}
-				}
-			} else {
-				// No Retry-After header, use exponential backoff: 5s, 10s, 20s
-				backoffDuration = time.Duration(1<<uint(attempt)) * 5 * time.Second
-			}
-
-			log.Warn().
-				Int("status_code", resp.StatusCode).
-				Str("status", resp.Status).
-				Str("retry_after", retryAfter).
-				Dur("backoff", backoffDuration).
-				Int("attempt", attempt+1).
-				Msg("Rate limit exceeded (429), waiting before retry")
-
-			resp.Body.Close()
-			lastErr = fmt.Errorf("rate limit exceeded (429)")
-
-			// Wait before retrying
-			select {
-			case <-ctx.Done():
-				log.Error().Err(ctx.Err()).Msg("Context cancelled during rate limit wait")
-				return nil, ctx.Err()
-			case <-time.After(backoffDuration):
-			}
-			continue
-		}
-
-		// Check for other server errors (5xx)
-		if resp.StatusCode >= 500 {
-			log.Warn().
-				Int("status_code", resp.StatusCode).
-				Str("status", resp.Status).
-				Msg("Server error, will retry")
-			resp.Body.Close()
-			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
-			continue
-		}
-
-		// Success
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			log.Debug().Int("status_code", resp.StatusCode).Msg("Request successful")
-			return resp, nil
-		}
-
-		// Client error (4xx) - read body for error details
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-
-		log.Error().
-			Int("status_code", resp.StatusCode).
-			Str("status", resp.Status).
-			Str("response_body", string(bodyBytes)).
-			Str("url", fullURL).
-			Msg("Client error (4xx), not retrying")
+	return c.getWithRetry(ctx, endpoint, maxRetries, "application/json")
+}
 
-		// For 4xx errors, return response with body so Query() can parse the error
-		// Recreate the response body from the bytes we read
-		resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-		return resp, nil
-	}
+// getWithRetry makes a GET request with retry logic and the given Accept header
+func (c *Client) getWithRetry(ctx context.Context, endpoint string, maxRetries int, accept string) (*http.Response, error) {
+	return c.do(ctx, request{
+		method:     http.MethodGet,
+		endpoint:   endpoint,
+		header:     map[string]string{"Accept": accept},
+		maxRetries: maxRetries,
+	})
+}
 
-	log.Error().
-		Int("attempts", maxRetries).
-		Err(lastErr).
-		Msg("Max retries exceeded")
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+// getRoot makes a GET request relative to the service root, when known
+func (c *Client) getRoot(ctx context.Context, endpoint string) (*http.Response, error) {
+	return c.do(ctx, request{
+		method:     http.MethodGet,
+		endpoint:   endpoint,
+		root:       true,
+		header:     map[string]string{"Accept": "application/json"},
+		maxRetries: c.maxRetries(),
+	})
 }
 
-// GetPaginated fetches all pages of an OData endpoint
-func (c *Client) GetPaginated(ctx context.Context, endpoint string) ([]map[string]interface{}, error) {
+// GetPaginated fetches all pages of an OData query. Large result sets are better
+// read incrementally with Pages or ForEachPage.
+func (c *Client) GetPaginated(ctx context.Context, query *Query) ([]map[string]interface{}, error) {
 	log := log.With().
 		Str("component", "bc_client").
-		Str("endpoint", endpoint).
+		Str("endpoint", query.String()).
 		Logger()
 
 	log.Info().Msg("Fetching paginated data from Business Central API")
 
 	var allResults []map[string]interface{}
-	currentEndpoint := endpoint
-	skipCount := 0
-	pageNum := 1
-
-	// Check if $top is specified in the endpoint
-	maxResults := -1 // -1 means no limit
-	if strings.Contains(endpoint, "$top=") {
-		// Extract $top value from endpoint
-		topIndex := strings.Index(endpoint, "$top=")
-		if topIndex != -1 {
-			topPart := endpoint[topIndex+5:]
-			// Find where $top value ends (either & or end of string)
-			endIndex := strings.Index(topPart, "&")
-			if endIndex == -1 {
-				endIndex = len(topPart)
-			}
-			topValue := strings.TrimSpace(topPart[:endIndex])
-			if top, err := strconv.Atoi(topValue); err == nil {
-				maxResults = top
-				log.Debug().Int("max_results", maxResults).Msg("Found $top parameter, limiting results")
-			}
-		}
-	}
-
-	// Rate limiting: add delay between requests to avoid hitting rate limits
-	requestDelay := 200 * time.Millisecond
-
-	for {
-		// Check if we've reached the limit specified by $top
-		if maxResults > 0 && len(allResults) >= maxResults {
-			log.Debug().
-				Int("max_results", maxResults).
-				Int("current_results", len(allResults)).
-				Msg("Reached $top limit, stopping pagination")
-			// Trim results to exact limit
-			allResults = allResults[:maxResults]
-			break
-		}
-
-		// Add $skip if we're paginating manually
-		if skipCount > 0 && len(allResults) > 0 {
-			baseEndpoint := currentEndpoint
-			if strings.Contains(currentEndpoint, "?") {
-				baseEndpoint = strings.Split(currentEndpoint, "?")[0]
-			}
-			// Preserve all original query parameters (filter, select, orderby, top) when adding $skip
-			queryParams := []string{}
-
-			// Parse existing query parameters to preserve them
-			if strings.Contains(currentEndpoint, "?") {
-				queryPart := strings.Split(currentEndpoint, "?")[1]
-				// Split by & but be careful with URL encoding
-				params := strings.Split(queryPart, "&")
-				for _, param := range params {
-					// Skip existing $skip if present (we'll add our own)
-					if strings.HasPrefix(param, "$skip=") {
-						continue
-					}
-					// Preserve all other parameters
-					if strings.HasPrefix(param, "$filter=") ||
-						strings.HasPrefix(param, "$select=") ||
-						strings.HasPrefix(param, "$orderby=") ||
-						strings.HasPrefix(param, "$top=") {
-						queryParams = append(queryParams, param)
-					}
-				}
-			}
-
-			// Add $skip parameter
-			queryParams = append(queryParams, fmt.Sprintf("$skip=%d", skipCount))
-
-			// Rebuild endpoint with all parameters
-			if len(queryParams) > 0 {
-				currentEndpoint = baseEndpoint + "?" + strings.Join(queryParams, "&")
-			} else {
-				currentEndpoint = fmt.Sprintf("%s?$skip=%d", baseEndpoint, skipCount)
-			}
-		}
-
-		log.Debug().
-			Int("page", pageNum).
-			Int("skip", skipCount).
-			Str("endpoint", currentEndpoint).
-			Msg("Fetching page")
-
-		// Add delay between requests to respect rate limits (except for first request)
-		if pageNum > 1 {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(requestDelay):
-			}
-		}
-
-		resp, err := c.Get(ctx, currentEndpoint)
-		if err != nil {
-			log.Error().Err(err).
-				Int("page", pageNum).
-				Msg("Failed to fetch page")
-			return nil, fmt.Errorf("failed to fetch page: %w", err)
-		}
-
-		body, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			log.Error().Err(err).
-				Int("page", pageNum).
-				Msg("Failed to read response body")
-			return nil, fmt.Errorf("failed to read response: %w", err)
-		}
-
-		var odataResp ODataResponse
-		if err := json.Unmarshal(body, &odataResp); err != nil {
-			log.Error().Err(err).
-				Int("page", pageNum).
-				Msg("Failed to parse OData response")
-			return nil, fmt.Errorf("failed to parse OData response: %w", err)
-		}
-
-		pageResults := len(odataResp.Value)
-		allResults = append(allResults, odataResp.Value...)
-
-		log.Debug().
-			Int("page", pageNum).
-			Int("results_in_page", pageResults).
-			Int("total_results", len(allResults)).
-			Bool("has_next_link", odataResp.NextLink != "").
-			Msg("Page fetched")
-
-		// Check if we've reached the limit specified by $top after adding this page
-		if maxResults > 0 && len(allResults) >= maxResults {
-			log.Debug().
-				Int("max_results", maxResults).
-				Int("current_results", len(allResults)).
-				Msg("Reached $top limit after fetching page, stopping pagination")
-			// Trim results to exact limit
-			allResults = allResults[:maxResults]
-			break
-		}
-
-		// Check for next link
-		if odataResp.NextLink != "" {
-			// If $top is specified, check if we should continue before following next link
-			if maxResults > 0 && len(allResults) >= maxResults {
-				log.Debug().
-					Int("max_results", maxResults).
-					Int("current_results", len(allResults)).
-					Msg("Reached $top limit, stopping pagination (next link available but limit reached)")
-				allResults = allResults[:maxResults]
-				break
-			}
-			// Extract endpoint from next link (remove base URL)
-			nextURL, err := url.Parse(odataResp.NextLink)
-			if err != nil {
-				log.Error().Err(err).
-					Str("next_link", odataResp.NextLink).
-					Msg("Failed to parse next link")
-				return nil, fmt.Errorf("failed to parse next link: %w", err)
-			}
-			// Remove base path from next link
-			nextPath := strings.TrimPrefix(nextURL.Path, strings.TrimSuffix(c.baseURL, "/"))
-			currentEndpoint = nextPath + "?" + nextURL.RawQuery
-			skipCount = 0 // Reset skip count when using next link
-			pageNum++
-		} else {
-			// No next link - check if we should continue paginating manually
-			// Business Central often doesn't include nextLink even when more data is available
-
-			// If we got no results, we're done
-			if len(odataResp.Value) == 0 {
-				log.Debug().Msg("No more results, pagination complete")
-				break
-			}
-
-			// If $top is specified and we've reached it, stop
-			if maxResults > 0 && len(allResults) >= maxResults {
-				log.Debug().
-					Int("max_results", maxResults).
-					Int("current_results", len(allResults)).
-					Msg("Reached $top limit, stopping pagination")
-				allResults = allResults[:maxResults]
-				break
-			}
-
-			// Business Central typically returns 20 results per page when not limited
-			// If we got fewer results than a typical page size, we're likely at the end
-			// However, with $filter, the page size can vary, so we'll try one more page
-			// to be sure, but track if we get the same or fewer results
-			typicalPageSize := 20
-			if len(odataResp.Value) < typicalPageSize {
-				// We got fewer results than typical - might be the last page
-				// Try one more page to confirm, but if we already have results from previous iteration
-				// with same count, we're done
-				if skipCount > 0 {
-					// We're already paginating manually, and got a small page
-					// This likely means we're at the end
-					log.Debug().
-						Int("page_size", len(odataResp.Value)).
-						Int("typical_page_size", typicalPageSize).
-						Msg("Received smaller page than typical, likely at end of results")
-					break
-				}
-			}
-
-			// Continue manual pagination with $skip
-			// This works even with $filter - Business Central supports $skip with filters
-			log.Debug().
-				Int("results_in_page", len(odataResp.Value)).
-				Int("skip_count", skipCount).
-				Msg("No nextLink found, continuing manual pagination with $skip")
-			skipCount += len(odataResp.Value)
-			pageNum++
-		}
-
-		// Safety check to avoid infinite loops
-		if len(odataResp.Value) == 0 {
-			log.Debug().Msg("Empty page received, stopping pagination")
-			break
-		}
+	pages := 0
+	_, err := c.ForEachPage(ctx, query, PageOptions{}, func(page *Page) error {
+		allResults = append(allResults, page.Value...)
+		pages++
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	log.Info().
-		Int("total_pages", pageNum-1).
+		Int("total_pages", pages).
 		Int("total_results", len(allResults)).
 		Msg("Pagination complete")
 
@@ -489,9 +145,14 @@ func (c *Client) GetPaginated(ctx context.Context, endpoint string) ([]map[strin
 }
 
 // Query executes an OData query and returns the results
-func (c *Client) Query(ctx context.Context, endpoint string, includePagination bool) ([]map[string]interface{}, error) {
+func (c *Client) Query(ctx context.Context, query *Query, includePagination bool) ([]map[string]interface{}, error) {
 	if includePagination {
-		return c.GetPaginated(ctx, endpoint)
+		return c.GetPaginated(ctx, query)
+	}
+
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
 	}
 
 	resp, err := c.Get(ctx, endpoint)
@@ -507,16 +168,7 @@ func (c *Client) Query(ctx context.Context, endpoint string, includePagination b
 
 	// Check if response is an error
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// Try to parse error response
-		var errorResp map[string]interface{}
-		if jsonErr := json.Unmarshal(body, &errorResp); jsonErr == nil {
-			if errorObj, ok := errorResp["error"].(map[string]interface{}); ok {
-				if message, ok := errorObj["message"].(string); ok {
-					return nil, fmt.Errorf("OData error (status %d): %s", resp.StatusCode, message)
-				}
-			}
-		}
-		return nil, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, string(body))
+		return nil, newODataError(resp.StatusCode, body)
 	}
 
 	var odataResp ODataResponse
@@ -527,26 +179,85 @@ func (c *Client) Query(ctx context.Context, endpoint string, includePagination b
 	return odataResp.Value, nil
 }
 
-// Post creates a new entity using POST
-func (c *Client) Post(ctx context.Context, endpoint string, data []byte) (map[string]interface{}, error) {
-	token, err := c.auth.GetToken()
+// Count returns the server-side number of entities matched by the query's filter.
+// It uses the /$count path segment and falls back to $count=true with $top=0,
+// reading @odata.count, for endpoints that don't support the path segment.
+func (c *Client) Count(ctx context.Context, query *Query) (int64, error) {
+	countEndpoint, err := query.CountEndpoint()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+		return 0, fmt.Errorf("invalid query: %w", err)
 	}
 
-	fullURL := c.baseURL + endpoint
-	req, err := http.NewRequestWithContext(ctx, "POST", fullURL, strings.NewReader(string(data)))
+	resp, err := c.getWithRetry(ctx, countEndpoint, c.maxRetries(), "text/plain, application/json")
+	if err != nil {
+		return 0, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return 0, fmt.Errorf("failed to read count response: %w", err)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// Strip a UTF-8 byte order mark, which some BC versions prepend to text/plain
+		text := strings.TrimSpace(strings.TrimPrefix(string(body), "\uFEFF"))
+		if count, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return count, nil
+		}
+		log.Debug().Str("entity_set", query.EntitySet()).Str("body", text).Msg("Unexpected /$count response, falling back to @odata.count")
+	} else {
+		log.Debug().Str("entity_set", query.EntitySet()).Int("status_code", resp.StatusCode).Msg("/$count not supported, falling back to @odata.count")
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	fallback := NewQuery(query.EntitySet()).Filter(query.FilterExpr()).WithCount().Top(0)
+	if len(query.key) > 0 {
+		fallback.Key(query.key...)
+	}
+	fallback.Search(query.search)
+	endpoint, err := fallback.Endpoint()
+	if err != nil {
+		return 0, fmt.Errorf("invalid query: %w", err)
+	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err = c.Get(ctx, endpoint)
 	if err != nil {
-		return nil, fmt.Errorf("POST request failed: %w", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read count response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, newODataError(resp.StatusCode, body)
+	}
+
+	var odataResp ODataResponse
+	if err := json.Unmarshal(body, &odataResp); err != nil {
+		return 0, fmt.Errorf("failed to parse OData response: %w", err)
+	}
+	if odataResp.Count == nil {
+		return 0, fmt.Errorf("response for '%s' did not include @odata.count", query.EntitySet())
+	}
+
+	return *odataResp.Count, nil
+}
+
+// GetEntity fetches a single entity addressed by its canonical URL (e.g. Customers('10000')).
+// The query must carry a key; $select and $expand are honored.
+func (c *Client) GetEntity(ctx context.Context, query *Query) (map[string]interface{}, error) {
+	if len(query.key) == 0 {
+		return nil, fmt.Errorf("entity lookup on '%s' requires a key", query.EntitySet())
+	}
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+
+	resp, err := c.Get(ctx, endpoint)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -556,85 +267,160 @@ func (c *Client) Post(ctx context.Context, endpoint string, data []byte) (map[st
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("POST failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newODataError(resp.StatusCode, body)
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	var entity map[string]interface{}
+	if err := json.Unmarshal(body, &entity); err != nil {
+		return nil, fmt.Errorf("failed to parse entity response: %w (response body: %s)", err, string(body))
 	}
 
-	return result, nil
+	return entity, nil
 }
 
-// Patch updates an entity using PATCH
-func (c *Client) Patch(ctx context.Context, endpoint string, data []byte, etag string) (map[string]interface{}, error) {
-	token, err := c.auth.GetToken()
+// Metadata fetches the raw $metadata (EDMX) document of the OData service,
+// from the service root when the base path selects a company
+func (c *Client) Metadata(ctx context.Context) ([]byte, error) {
+	resp, err := c.getRoot(ctx, "$metadata")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	fullURL := c.baseURL + endpoint
-	req, err := http.NewRequestWithContext(ctx, "PATCH", fullURL, strings.NewReader(string(data)))
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to read metadata response: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	if etag != "" {
-		req.Header.Set("If-Match", etag)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newODataError(resp.StatusCode, body)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	return body, nil
+}
+
+// ServiceDocument fetches the OData service document and returns the published
+// entity sets, singletons and function imports. It is read from the service
+// root: below Company('...') the root URL answers with the company record.
+func (c *Client) ServiceDocument(ctx context.Context) ([]ServiceDocumentEntry, error) {
+	resp, err := c.getRoot(ctx, "")
 	if err != nil {
-		return nil, fmt.Errorf("PATCH request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read service document: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("PATCH failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newODataError(resp.StatusCode, body)
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	var doc serviceDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse service document: %w", err)
+	}
+
+	// The service document lists resources with a name and url; anything else
+	// (e.g. a company record returned by a company-scoped root) is not a service document
+	entries := make([]ServiceDocumentEntry, 0, len(doc.Value))
+	for _, entry := range doc.Value {
+		if entry.Name == "" || entry.URL == "" {
+			continue
+		}
+		if entry.Kind == "" {
+			// Kind is optional and defaults to EntitySet per the OData JSON format
+			entry.Kind = "EntitySet"
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 && len(doc.Value) > 0 {
+		return nil, fmt.Errorf("root response is not an OData service document")
 	}
 
-	return result, nil
+	return entries, nil
 }
 
-// Delete deletes an entity using DELETE
-func (c *Client) Delete(ctx context.Context, endpoint string) error {
-	token, err := c.auth.GetToken()
+// Post creates a new entity using POST. The request is repeated after 401 and
+// 429 answers and when it could not be sent, never when it may have been applied.
+func (c *Client) Post(ctx context.Context, endpoint string, data []byte) (map[string]interface{}, error) {
+	resp, err := c.do(ctx, request{
+		method:     http.MethodPost,
+		endpoint:   endpoint,
+		body:       data,
+		maxRetries: c.maxRetries(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get token: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	fullURL := c.baseURL + endpoint
-	req, err := http.NewRequestWithContext(ctx, "DELETE", fullURL, nil)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/json")
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newODataError(resp.StatusCode, body)
+	}
+
+	return writeResult(resp.Header, body)
+}
 
-	resp, err := c.httpClient.Do(req)
+// Patch updates an entity using PATCH. A non-empty etag is sent as If-Match
+// (AnyETag overwrites any version); a 412 answer returns a *ConflictError with
+// the current version. The result carries the new @odata.etag.
+func (c *Client) Patch(ctx context.Context, endpoint string, data []byte, etag string) (map[string]interface{}, error) {
+	resp, err := c.do(ctx, request{
+		method:     http.MethodPatch,
+		endpoint:   endpoint,
+		body:       data,
+		header:     ifMatch(etag),
+		maxRetries: c.maxRetries(),
+	})
 	if err != nil {
-		return fmt.Errorf("DELETE request failed: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, c.conflictError(ctx, endpoint, body)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newODataError(resp.StatusCode, body)
+	}
+
+	return writeResult(resp.Header, body)
+}
+
+// Delete deletes an entity using DELETE. A non-empty etag is sent as If-Match
+// (AnyETag deletes any version); a 412 answer returns a *ConflictError.
+func (c *Client) Delete(ctx context.Context, endpoint string, etag string) error {
+	resp, err := c.do(ctx, request{
+		method:     http.MethodDelete,
+		endpoint:   endpoint,
+		header:     ifMatch(etag),
+		maxRetries: c.maxRetries(),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		body, _ := io.ReadAll(resp.Body)
+		return c.conflictError(ctx, endpoint, body)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("DELETE failed with status %d: %s", resp.StatusCode, string(body))
+		return newODataError(resp.StatusCode, body)
 	}
 
 	return nil
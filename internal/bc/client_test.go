@@ -3,6 +3,7 @@ package bc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -85,7 +86,7 @@ func TestClient_Query_Success(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	results, err := client.Query(ctx, "/test", false)
+	results, err := client.Query(ctx, NewQuery("/test"), false)
 	if err != nil {
 		t.Fatalf("Query() error = %v, want nil", err)
 	}
@@ -151,7 +152,7 @@ func TestClient_Query_WithPagination(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	results, err := client.Query(ctx, "/test", true) // Enable pagination
+	results, err := client.Query(ctx, NewQuery("/test"), true) // Enable pagination
 	if err != nil {
 		t.Fatalf("Query() error = %v, want nil", err)
 	}
@@ -195,7 +196,7 @@ func TestClient_Query_HTTPError(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	_, err := client.Query(ctx, "/test", false)
+	_, err := client.Query(ctx, NewQuery("/test"), false)
 	if err == nil {
 		t.Fatal("Query() error = nil, want error")
 	}
@@ -236,7 +237,7 @@ func TestClient_Query_InvalidJSON(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	_, err := client.Query(ctx, "/test", false)
+	_, err := client.Query(ctx, NewQuery("/test"), false)
 	if err == nil {
 		t.Fatal("Query() error = nil, want error")
 	}
@@ -332,7 +333,7 @@ func TestClient_GetPaginated_WithTopLimit(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	results, err := client.GetPaginated(ctx, "/test?$top=2")
+	results, err := client.GetPaginated(ctx, NewQuery("/test").Top(2))
 	if err != nil {
 		t.Fatalf("GetPaginated() error = %v, want nil", err)
 	}
@@ -391,13 +392,17 @@ func TestClient_GetPaginated_NoNextLink(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	results, err := client.GetPaginated(ctx, "/test")
+	results, err := client.GetPaginated(ctx, NewQuery("/test"))
 	if err != nil {
 		t.Fatalf("GetPaginated() error = %v, want nil", err)
 	}
 	if len(results) != 1 {
 		t.Errorf("GetPaginated() returned %d results, want 1", len(results))
 	}
+	// Without @odata.nextLink the first page is the last one
+	if requestCount != 1 {
+		t.Errorf("GetPaginated() made %d requests, want 1", requestCount)
+	}
 }
 
 func TestClient_Post_Success(t *testing.T) {
@@ -582,12 +587,43 @@ func TestClient_Delete_Success(t *testing.T) {
 	client := NewClient(cfg, auth)
 
 	ctx := context.Background()
-	err := client.Delete(ctx, "/test('001')")
+	err := client.Delete(ctx, "/test('001')", "")
 	if err != nil {
 		t.Fatalf("Delete() error = %v, want nil", err)
 	}
 }
 
+func TestClient_WriteErrors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":{"code":"Internal_EntityWithSameKeyExists","message":"The record already exists."}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":"BadRequest_NotFound","message":"The record does not exist."}}`))
+	})
+	ctx := context.Background()
+
+	_, postErr := client.Post(ctx, "test", []byte(`{}`))
+	_, patchErr := client.Patch(ctx, "test('001')", []byte(`{}`), AnyETag)
+	deleteErr := client.Delete(ctx, "test('001')", AnyETag)
+	for name, tt := range map[string]struct {
+		err    error
+		status int
+	}{
+		"Post":   {postErr, http.StatusConflict},
+		"Patch":  {patchErr, http.StatusNotFound},
+		"Delete": {deleteErr, http.StatusNotFound},
+	} {
+		var odataErr *ODataError
+		if !errors.As(tt.err, &odataErr) || odataErr.StatusCode != tt.status || odataErr.Message == "" {
+			t.Errorf("%s() error = %v, want an *ODataError with status %d", name, tt.err, tt.status)
+		}
+	}
+}
+
 func TestODataResponse_JSON(t *testing.T) {
 	// Test JSON marshaling/unmarshaling
 	jsonData := `{"value":[{"No":"001","Name":"Test"}],"@odata.nextLink":"/next"}`
@@ -612,3 +648,158 @@ func TestODataResponse_JSON(t *testing.T) {
 		t.Errorf("Marshaled JSON is invalid: %s", string(marshaled))
 	}
 }
+
+// newTestClient returns a client wired to a mock OAuth server and the given OData handler
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+
+	oauthServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenResp := TokenResponse{
+			AccessToken: "test-token",
+			TokenType:   "Bearer",
+			ExpiresIn:   3600,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tokenResp)
+	}))
+	t.Cleanup(oauthServer.Close)
+
+	odataServer := httptest.NewServer(handler)
+	t.Cleanup(odataServer.Close)
+
+	cfg := Config{
+		GrantType:    "client_credentials",
+		ClientID:     "test-client-id",
+		ClientSecret: "test-client-secret",
+		ScopeAPI:     "https://api.businesscentral.dynamics.com/.default",
+		TokenURL:     oauthServer.URL,
+		ContentType:  "application/x-www-form-urlencoded",
+		BasePath:     odataServer.URL + "/",
+		APITimeout:   90,
+	}
+
+	return NewClient(cfg, NewAuth(cfg))
+}
+
+func TestClient_ServiceDocument(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"@odata.context":"$metadata","value":[
+			{"name":"Customers","url":"Customers"},
+			{"name":"CompanyInformation","kind":"Singleton","url":"CompanyInformation"},
+			{"name":"GetTotals","kind":"FunctionImport","url":"GetTotals"}
+		]}`))
+	})
+
+	entries, err := client.ServiceDocument(context.Background())
+	if err != nil {
+		t.Fatalf("ServiceDocument() error = %v, want nil", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("ServiceDocument() returned %d entries, want 3", len(entries))
+	}
+	if entries[0].Kind != "EntitySet" {
+		t.Errorf("default Kind = %v, want EntitySet", entries[0].Kind)
+	}
+	if entries[1].Kind != "Singleton" {
+		t.Errorf("Kind = %v, want Singleton", entries[1].Kind)
+	}
+}
+
+func TestClient_ServiceDocument_NotServiceDocument(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"Name":"CRONUS","Display_Name":"CRONUS Italia"}]}`))
+	})
+
+	if _, err := client.ServiceDocument(context.Background()); err == nil {
+		t.Fatal("ServiceDocument() error = nil, want error")
+	}
+}
+
+func TestClient_Metadata(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/$metadata" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(`<edmx:Edmx Version="4.0"/>`))
+	})
+
+	body, err := client.Metadata(context.Background())
+	if err != nil {
+		t.Fatalf("Metadata() error = %v, want nil", err)
+	}
+	if string(body) != `<edmx:Edmx Version="4.0"/>` {
+		t.Errorf("Metadata() = %s", string(body))
+	}
+}
+
+func TestClient_Count(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Customers/$count" {
+			t.Errorf("Expected /Customers/$count, got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("$filter") != "Balance gt 0" {
+			t.Errorf("Expected $filter 'Balance gt 0', got %q", r.URL.Query().Get("$filter"))
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("\uFEFF1234"))
+	})
+
+	count, err := client.Count(context.Background(), NewQuery("Customers").Filter(RawFilter("Balance gt 0")))
+	if err != nil {
+		t.Fatalf("Count() error = %v, want nil", err)
+	}
+	if count != 1234 {
+		t.Errorf("Count() = %d, want 1234", count)
+	}
+}
+
+func TestClient_Count_FallbackToODataCount(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ODV_List/$count" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Segment not supported"}}`))
+			return
+		}
+		if r.URL.Query().Get("$count") != "true" || r.URL.Query().Get("$top") != "0" {
+			t.Errorf("Expected $count=true&$top=0, got %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"@odata.count":4321,"value":[]}`))
+	})
+
+	count, err := client.Count(context.Background(), NewQuery("ODV_List"))
+	if err != nil {
+		t.Fatalf("Count() error = %v, want nil", err)
+	}
+	if count != 4321 {
+		t.Errorf("Count() = %d, want 4321", count)
+	}
+}
+
+func TestClient_Get_CancelledDuringRateLimitWait(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.Get(ctx, "Customers")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Get() returned after %v, want it to stop when cancelled", elapsed)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
@@ -0,0 +1,75 @@
+package bc
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// companySegment matches the Company('...') segment of an OData V4 base path
+var companySegment = regexp.MustCompile(`(?i)/Company\('((?:[^']|'')*)'\)/?$`)
+
+// serviceRootPattern matches a base path that ends at the OData V4 service root
+var serviceRootPattern = regexp.MustCompile(`(?i)/ODataV4/?$`)
+
+// resolveBasePath works out the service root and the default company from the
+// configured base path. A base path ending in Company('...') keeps working as
+// is and names the default company; a base path ending at the ODataV4 service
+// root gets cfg.Company inserted. Any other base path is used as is and does
+// not allow selecting a company.
+func resolveBasePath(basePath, company string) (baseURL, serviceRoot, defaultCompany string) {
+	if match := companySegment.FindStringSubmatchIndex(basePath); match != nil {
+		name := basePath[match[2]:match[3]]
+		if decoded, err := url.PathUnescape(name); err == nil {
+			name = decoded
+		}
+		return basePath, basePath[:match[0]+1], strings.ReplaceAll(name, "''", "'")
+	}
+	if serviceRootPattern.MatchString(basePath) {
+		root := strings.TrimSuffix(basePath, "/") + "/"
+		if company == "" {
+			return root, root, ""
+		}
+		return root + CompanyPath(company), root, company
+	}
+	return basePath, "", company
+}
+
+// CompanyPath renders the Company('<name>')/ path segment, doubling quotes and
+// percent-encoding the name
+func CompanyPath(name string) string {
+	return "Company('" + url.PathEscape(strings.ReplaceAll(name, "'", "''")) + "')/"
+}
+
+// Company returns the company the client addresses, or "" when it is unknown
+func (c *Client) Company() string {
+	return c.company
+}
+
+// ForCompany returns a client for another company of the same environment. It
+// shares the token and HTTP connections of c. The name of the default company,
+// or "", returns c itself.
+func (c *Client) ForCompany(name string) (*Client, error) {
+	if name == "" || name == c.company {
+		return c, nil
+	}
+	if c.serviceRoot == "" {
+		return nil, fmt.Errorf("selecting a company requires BC_BASE_PATH to end at the ODataV4 service root or at a Company('...') segment")
+	}
+	clone := *c
+	clone.baseURL = c.serviceRoot + CompanyPath(name)
+	clone.company = name
+	return &clone, nil
+}
+
+// ListCompanies returns the records of the Companies entity set at the service root
+func (c *Client) ListCompanies(ctx context.Context) ([]map[string]interface{}, error) {
+	if c.serviceRoot == "" {
+		return nil, fmt.Errorf("listing companies requires BC_BASE_PATH to end at the ODataV4 service root or at a Company('...') segment")
+	}
+	root := *c
+	root.baseURL = c.serviceRoot
+	return root.Query(ctx, NewQuery("Companies"), true)
+}
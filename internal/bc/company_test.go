@@ -0,0 +1,92 @@
+package bc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestResolveBasePath(t *testing.T) {
+	tests := []struct {
+		basePath, company                      string
+		wantBase, wantRoot, wantDefaultCompany string
+	}{
+		{
+			basePath: "https://bc.example/v2.0/t/Production/ODataV4/Company('CRONUS%20Italia')/", company: "ignored",
+			wantBase: "https://bc.example/v2.0/t/Production/ODataV4/Company('CRONUS%20Italia')/", wantRoot: "https://bc.example/v2.0/t/Production/ODataV4/", wantDefaultCompany: "CRONUS Italia",
+		},
+		{
+			basePath: "https://bc.example/v2.0/t/Production/ODataV4", company: "CRONUS Italia",
+			wantBase: "https://bc.example/v2.0/t/Production/ODataV4/Company('CRONUS%20Italia')/", wantRoot: "https://bc.example/v2.0/t/Production/ODataV4/", wantDefaultCompany: "CRONUS Italia",
+		},
+		{
+			basePath: "https://bc.example/v2.0/t/Production/api/v2.0/companies(123)/", company: "CRONUS",
+			wantBase: "https://bc.example/v2.0/t/Production/api/v2.0/companies(123)/", wantDefaultCompany: "CRONUS",
+		},
+	}
+	for _, tt := range tests {
+		base, root, company := resolveBasePath(tt.basePath, tt.company)
+		if base != tt.wantBase || root != tt.wantRoot || company != tt.wantDefaultCompany {
+			t.Errorf("resolveBasePath(%q, %q) = %q, %q, %q; want %q, %q, %q", tt.basePath, tt.company, base, root, company, tt.wantBase, tt.wantRoot, tt.wantDefaultCompany)
+		}
+	}
+}
+
+func TestCompanyPath(t *testing.T) {
+	if got := CompanyPath("O'Brien & Co/IT"); got != "Company('O%27%27Brien%20&%20Co%2FIT')/" {
+		t.Errorf("CompanyPath() = %s", got)
+	}
+}
+
+func TestClient_ForCompany(t *testing.T) {
+	var paths []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"Name":"CRONUS"}]}`))
+	})
+	client.serviceRoot = client.baseURL
+
+	other, err := client.ForCompany("CRONUS Italia")
+	if err != nil {
+		t.Fatalf("ForCompany() error = %v", err)
+	}
+	if _, err := other.Query(context.Background(), NewQuery("Customers"), false); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if _, err := client.ListCompanies(context.Background()); err != nil {
+		t.Fatalf("ListCompanies() error = %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/Company('CRONUS%20Italia')/Customers" || paths[1] != "/Companies" {
+		t.Errorf("paths = %v", paths)
+	}
+
+	client.serviceRoot = ""
+	if _, err := client.ForCompany("CRONUS Italia"); err == nil {
+		t.Error("ForCompany() error = nil without a service root")
+	}
+}
+
+func TestClient_ServiceRootRequests(t *testing.T) {
+	var paths []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"name":"Customers","url":"Customers"}]}`))
+	})
+	// A base path that selects a company, as BC_BASE_PATH=.../ODataV4/Company('CRONUS')/
+	cfg := client.config
+	cfg.BasePath = client.baseURL + "ODataV4/Company('CRONUS')/"
+	client = NewClient(cfg, client.auth)
+
+	entries, err := client.ServiceDocument(context.Background())
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ServiceDocument() = %v, %v; want the entity sets", entries, err)
+	}
+	if _, err := client.Metadata(context.Background()); err != nil {
+		t.Fatalf("Metadata() error = %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/ODataV4/" || paths[1] != "/ODataV4/$metadata" {
+		t.Errorf("paths = %v, want the service root", paths)
+	}
+}
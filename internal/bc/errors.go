@@ -0,0 +1,59 @@
+package bc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ODataError is returned when Business Central answers with a non-2xx status
+type ODataError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Body       string
+}
+
+func (e *ODataError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("OData error (status %d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("HTTP error %d: %s", e.StatusCode, e.Body)
+}
+
+// newODataError builds an ODataError from a response, extracting the OData
+// error object ({"error":{"code":...,"message":...}}) when present
+func newODataError(statusCode int, body []byte) *ODataError {
+	odataErr := &ODataError{
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+
+	var errorResp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResp); err == nil {
+		odataErr.Code = errorResp.Error.Code
+		odataErr.Message = errorResp.Error.Message
+	}
+
+	return odataErr
+}
+
+// ConflictError is returned when a conditional write fails with 412
+// Precondition Failed because the entity changed since its ETag was read
+type ConflictError struct {
+	*ODataError
+	ETag    string                 // current @odata.etag, "" when unknown
+	Current map[string]interface{} // current server version, nil when it could not be read
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict: the entity was changed by someone else (status %d), read it again and retry with its current ETag", e.StatusCode)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.ODataError
+}
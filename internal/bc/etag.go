@@ -0,0 +1,73 @@
+package bc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// AnyETag as the etag of Patch or Delete writes whatever the current version is
+const AnyETag = "*"
+
+// ifMatch returns the If-Match header of a conditional write, none for ""
+func ifMatch(etag string) map[string]string {
+	if etag == "" {
+		return nil
+	}
+	return map[string]string{"If-Match": etag}
+}
+
+// Version reads the entity at endpoint (e.g. Customers('10000')) and returns
+// its @odata.etag, "" when the entity set does not use ETags, with the entity
+func (c *Client) Version(ctx context.Context, endpoint string) (string, map[string]interface{}, error) {
+	resp, err := c.Get(ctx, endpoint)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", nil, newODataError(resp.StatusCode, body)
+	}
+
+	entity, err := writeResult(resp.Header, body)
+	if err != nil {
+		return "", nil, err
+	}
+	etag, _ := entity["@odata.etag"].(string)
+	return etag, entity, nil
+}
+
+// conflictError builds the error of a write rejected with 412, reading the
+// current version of the entity so the caller can review it and retry
+func (c *Client) conflictError(ctx context.Context, endpoint string, body []byte) error {
+	conflict := &ConflictError{ODataError: newODataError(http.StatusPreconditionFailed, body)}
+	if etag, current, err := c.Version(ctx, endpoint); err == nil {
+		conflict.ETag = etag
+		conflict.Current = current
+	}
+	return conflict
+}
+
+// writeResult decodes the entity returned by a request, taking @odata.etag
+// from the ETag header when the body omits it or is empty (204 No Content)
+func writeResult(header http.Header, body []byte) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	if _, ok := result["@odata.etag"]; !ok {
+		if etag := header.Get("ETag"); etag != "" {
+			result["@odata.etag"] = etag
+		}
+	}
+	return result, nil
+}
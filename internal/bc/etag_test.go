@@ -0,0 +1,60 @@
+package bc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClient_Patch_Conflict(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"@odata.etag":"W/\"2\"","No":"001","Name":"Changed"}`))
+			return
+		}
+		if r.Header.Get("If-Match") != `W/"1"` {
+			t.Errorf("If-Match = %q", r.Header.Get("If-Match"))
+		}
+		w.WriteHeader(http.StatusPreconditionFailed)
+		_, _ = w.Write([]byte(`{"error":{"code":"Request_EntityChanged","message":"Another user has already changed the record."}}`))
+	})
+
+	_, err := client.Patch(context.Background(), "test('001')", []byte(`{"Name":"Mine"}`), `W/"1"`)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("Patch() error = %v, want *ConflictError", err)
+	}
+	if conflict.ETag != `W/"2"` || conflict.Current["Name"] != "Changed" {
+		t.Errorf("conflict = %+v", conflict)
+	}
+	var odataErr *ODataError
+	if !errors.As(err, &odataErr) || odataErr.Code != "Request_EntityChanged" {
+		t.Errorf("conflict does not unwrap to the OData error: %v", err)
+	}
+}
+
+func TestClient_WriteETags(t *testing.T) {
+	var ifMatch []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+		w.Header().Set("ETag", `W/"3"`)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ctx := context.Background()
+
+	result, err := client.Patch(ctx, "test('001')", []byte(`{}`), AnyETag)
+	if err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	if result["@odata.etag"] != `W/"3"` {
+		t.Errorf("Patch() result = %v, want the new ETag from the header", result)
+	}
+	if err := client.Delete(ctx, "test('001')", `W/"3"`); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if len(ifMatch) != 2 || ifMatch[0] != "*" || ifMatch[1] != `W/"3"` {
+		t.Errorf("If-Match headers = %q", ifMatch)
+	}
+}
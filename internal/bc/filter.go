@@ -0,0 +1,170 @@
+package bc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is a node of an OData $filter expression tree
+type Filter interface {
+	// Render returns the $filter expression for this node
+	Render() (string, error)
+}
+
+// comparisonOperators are the OData binary comparison operators
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// filterFunctions are the OData string functions usable as boolean predicates
+var filterFunctions = map[string]bool{
+	"contains": true, "startswith": true, "endswith": true,
+}
+
+// Comparison compares a field with a literal value, e.g. Amount gt 100
+type Comparison struct {
+	Field string
+	Op    string
+	Type  string // EDM type of the field; empty infers the literal from Value
+	Value interface{}
+}
+
+// Render implements Filter
+func (c Comparison) Render() (string, error) {
+	op := strings.ToLower(c.Op)
+	if !comparisonOperators[op] {
+		return "", fmt.Errorf("unsupported comparison operator '%s'", c.Op)
+	}
+	if c.Field == "" {
+		return "", fmt.Errorf("comparison requires a field")
+	}
+	literal, err := FormatLiteral(c.Type, c.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for '%s': %w", c.Field, err)
+	}
+	return fmt.Sprintf("%s %s %s", c.Field, op, literal), nil
+}
+
+// FunctionCall applies a string function to a field, e.g. contains(Name,'Contoso')
+type FunctionCall struct {
+	Name  string
+	Field string
+	Type  string
+	Value interface{}
+}
+
+// Render implements Filter
+func (f FunctionCall) Render() (string, error) {
+	name := strings.ToLower(f.Name)
+	if !filterFunctions[name] {
+		return "", fmt.Errorf("unsupported filter function '%s'", f.Name)
+	}
+	if f.Field == "" {
+		return "", fmt.Errorf("%s requires a field", name)
+	}
+	literal, err := FormatLiteral(f.Type, f.Value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value for '%s': %w", f.Field, err)
+	}
+	return fmt.Sprintf("%s(%s,%s)", name, f.Field, literal), nil
+}
+
+// In matches a field against a list of values. It renders as a chain of eq
+// comparisons joined by or, which every Business Central version understands.
+type In struct {
+	Field  string
+	Type   string
+	Values []interface{}
+}
+
+// Render implements Filter
+func (in In) Render() (string, error) {
+	if len(in.Values) == 0 {
+		return "", fmt.Errorf("in requires at least one value for '%s'", in.Field)
+	}
+	filters := make([]Filter, 0, len(in.Values))
+	for _, v := range in.Values {
+		filters = append(filters, Comparison{Field: in.Field, Op: "eq", Type: in.Type, Value: v})
+	}
+	return Or(filters...).Render()
+}
+
+// Logical combines filters with and/or
+type Logical struct {
+	Op      string
+	Filters []Filter
+}
+
+// Render implements Filter
+func (l Logical) Render() (string, error) {
+	op := strings.ToLower(l.Op)
+	if op != "and" && op != "or" {
+		return "", fmt.Errorf("unsupported logical operator '%s'", l.Op)
+	}
+	if len(l.Filters) == 0 {
+		return "", fmt.Errorf("'%s' requires at least one operand", op)
+	}
+	if len(l.Filters) == 1 {
+		return l.Filters[0].Render()
+	}
+
+	parts := make([]string, 0, len(l.Filters))
+	for _, f := range l.Filters {
+		rendered, err := f.Render()
+		if err != nil {
+			return "", err
+		}
+		// Parenthesize operands that may contain operators of lower precedence
+		switch f.(type) {
+		case Logical, RawFilter, In:
+			rendered = "(" + rendered + ")"
+		}
+		parts = append(parts, rendered)
+	}
+	return strings.Join(parts, " "+op+" "), nil
+}
+
+// Negation negates a filter
+type Negation struct {
+	Filter Filter
+}
+
+// Render implements Filter
+func (n Negation) Render() (string, error) {
+	if n.Filter == nil {
+		return "", fmt.Errorf("not requires an operand")
+	}
+	rendered, err := n.Filter.Render()
+	if err != nil {
+		return "", err
+	}
+	return "not (" + rendered + ")", nil
+}
+
+// RawFilter is a $filter expression supplied verbatim
+type RawFilter string
+
+// Render implements Filter
+func (r RawFilter) Render() (string, error) {
+	return string(r), nil
+}
+
+// And combines filters with the and operator
+func And(filters ...Filter) Filter {
+	return Logical{Op: "and", Filters: filters}
+}
+
+// Or combines filters with the or operator
+func Or(filters ...Filter) Filter {
+	return Logical{Op: "or", Filters: filters}
+}
+
+// Not negates a filter
+func Not(filter Filter) Filter {
+	return Negation{Filter: filter}
+}
+
+// Eq compares a field for equality, inferring the literal from the value
+func Eq(field string, value interface{}) Filter {
+	return Comparison{Field: field, Op: "eq", Value: value}
+}
@@ -0,0 +1,188 @@
+package bc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var guidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// KeyPart is a single key property of an entity key predicate
+type KeyPart struct {
+	Name  string
+	Type  string // EDM type name; empty infers the literal from the Go value
+	Value interface{}
+}
+
+// EntityKey renders a key predicate such as ('10000') for single keys or
+// (Document_Type='Order',No='1001') for composite keys
+func EntityKey(parts []KeyPart) (string, error) {
+	if len(parts) == 0 {
+		return "", fmt.Errorf("entity key requires at least one key value")
+	}
+
+	if len(parts) == 1 {
+		literal, err := FormatLiteral(parts[0].Type, parts[0].Value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for key '%s': %w", parts[0].Name, err)
+		}
+		return "(" + literal + ")", nil
+	}
+
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		literal, err := FormatLiteral(part.Type, part.Value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for key '%s': %w", part.Name, err)
+		}
+		segments = append(segments, part.Name+"="+literal)
+	}
+	return "(" + strings.Join(segments, ",") + ")", nil
+}
+
+// QuoteString renders a string as an OData string literal, doubling embedded quotes
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// FormatLiteral renders a value as an OData URL literal for the given EDM type.
+// Values usually come from JSON, so numbers arrive as float64 and everything
+// else may arrive as a string; both are accepted where unambiguous.
+//
+// Enumeration types (any non-Edm type) are rendered as quoted member names,
+// which Business Central accepts both in key predicates and in $filter.
+func FormatLiteral(edmType string, value interface{}) (string, error) {
+	if value == nil {
+		return "null", nil
+	}
+
+	switch edmType {
+	case "":
+		return inferLiteral(value)
+
+	case "Edm.String":
+		switch v := value.(type) {
+		case string:
+			return QuoteString(v), nil
+		case float64:
+			return QuoteString(strconv.FormatFloat(v, 'f', -1, 64)), nil
+		default:
+			return QuoteString(fmt.Sprint(v)), nil
+		}
+
+	case "Edm.Guid":
+		s := strings.Trim(fmt.Sprint(value), "{}")
+		if !guidPattern.MatchString(s) {
+			return "", fmt.Errorf("'%v' is not a valid GUID", value)
+		}
+		return strings.ToLower(s), nil
+
+	case "Edm.Byte", "Edm.SByte", "Edm.Int16", "Edm.Int32", "Edm.Int64":
+		switch v := value.(type) {
+		case float64:
+			if v != float64(int64(v)) {
+				return "", fmt.Errorf("%v is not an integer", v)
+			}
+			return strconv.FormatInt(int64(v), 10), nil
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case string:
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				return "", fmt.Errorf("'%s' is not an integer", v)
+			}
+			return v, nil
+		}
+
+	case "Edm.Decimal", "Edm.Double", "Edm.Single":
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int:
+			return strconv.Itoa(v), nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case string:
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return "", fmt.Errorf("'%s' is not a number", v)
+			}
+			return v, nil
+		}
+
+	case "Edm.Boolean":
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("'%s' is not a boolean", v)
+			}
+			return strconv.FormatBool(b), nil
+		}
+
+	case "Edm.Date":
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return "", fmt.Errorf("'%s' is not a date (expected YYYY-MM-DD)", s)
+			}
+			return s, nil
+		}
+
+	case "Edm.DateTimeOffset":
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				// A bare date is a common shorthand; widen it to midnight UTC
+				if _, dateErr := time.Parse("2006-01-02", s); dateErr == nil {
+					return s + "T00:00:00Z", nil
+				}
+				return "", fmt.Errorf("'%s' is not a date-time (expected RFC 3339, e.g. 2024-01-31T00:00:00Z)", s)
+			}
+			return s, nil
+		}
+
+	case "Edm.TimeOfDay":
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse("15:04:05", strings.SplitN(s, ".", 2)[0]); err != nil {
+				return "", fmt.Errorf("'%s' is not a time of day (expected hh:mm:ss)", s)
+			}
+			return s, nil
+		}
+
+	case "Edm.Duration":
+		if s, ok := value.(string); ok {
+			return "duration" + QuoteString(s), nil
+		}
+
+	default:
+		if strings.HasPrefix(edmType, "Edm.") {
+			return inferLiteral(value)
+		}
+		// Enumeration member
+		return QuoteString(fmt.Sprint(value)), nil
+	}
+
+	return "", fmt.Errorf("value %v (%T) cannot be used as %s", value, value, edmType)
+}
+
+// inferLiteral renders a literal from the Go type of a decoded JSON value
+func inferLiteral(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return QuoteString(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("unsupported literal value %v (%T)", value, value)
+	}
+}
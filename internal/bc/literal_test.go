@@ -0,0 +1,77 @@
+package bc
+
+import "testing"
+
+func TestFormatLiteral(t *testing.T) {
+	tests := []struct {
+		name    string
+		edmType string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{"string", "Edm.String", "10000", "'10000'", false},
+		{"string with quote", "Edm.String", "O'Brien", "'O''Brien'", false},
+		{"string from number", "Edm.String", float64(10000), "'10000'", false},
+		{"guid", "Edm.Guid", "5A3B0C2D-1111-2222-3333-444455556666", "5a3b0c2d-1111-2222-3333-444455556666", false},
+		{"guid with braces", "Edm.Guid", "{5a3b0c2d-1111-2222-3333-444455556666}", "5a3b0c2d-1111-2222-3333-444455556666", false},
+		{"invalid guid", "Edm.Guid", "not-a-guid", "", true},
+		{"int32 from float", "Edm.Int32", float64(42), "42", false},
+		{"int32 from string", "Edm.Int32", "42", "42", false},
+		{"int32 fractional", "Edm.Int32", 4.2, "", true},
+		{"int32 from bool", "Edm.Int32", true, "", true},
+		{"decimal", "Edm.Decimal", 1234.5, "1234.5", false},
+		{"decimal invalid string", "Edm.Decimal", "abc", "", true},
+		{"boolean", "Edm.Boolean", true, "true", false},
+		{"boolean from string", "Edm.Boolean", "false", "false", false},
+		{"date", "Edm.Date", "2024-01-31", "2024-01-31", false},
+		{"invalid date", "Edm.Date", "31/01/2024", "", true},
+		{"datetimeoffset", "Edm.DateTimeOffset", "2024-01-31T10:00:00Z", "2024-01-31T10:00:00Z", false},
+		{"datetimeoffset from date", "Edm.DateTimeOffset", "2024-01-31", "2024-01-31T00:00:00Z", false},
+		{"enum", "NAV.Document_Type", "Order", "'Order'", false},
+		{"null", "Edm.String", nil, "null", false},
+		{"inferred string", "", "abc", "'abc'", false},
+		{"inferred number", "", float64(7), "7", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatLiteral(tt.edmType, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatLiteral() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FormatLiteral() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityKey(t *testing.T) {
+	single, err := EntityKey([]KeyPart{{Name: "No", Type: "Edm.String", Value: "10000"}})
+	if err != nil {
+		t.Fatalf("EntityKey() error = %v", err)
+	}
+	if single != "('10000')" {
+		t.Errorf("EntityKey() = %v, want ('10000')", single)
+	}
+
+	composite, err := EntityKey([]KeyPart{
+		{Name: "Document_Type", Type: "NAV.Document_Type", Value: "Order"},
+		{Name: "No", Type: "Edm.String", Value: "1001"},
+		{Name: "Line_No", Type: "Edm.Int32", Value: float64(10000)},
+	})
+	if err != nil {
+		t.Fatalf("EntityKey() error = %v", err)
+	}
+	if composite != "(Document_Type='Order',No='1001',Line_No=10000)" {
+		t.Errorf("EntityKey() = %v", composite)
+	}
+
+	if _, err := EntityKey(nil); err == nil {
+		t.Error("EntityKey(nil) error = nil, want error")
+	}
+	if _, err := EntityKey([]KeyPart{{Name: "Id", Type: "Edm.Guid", Value: "bad"}}); err == nil {
+		t.Error("EntityKey() with invalid GUID error = nil, want error")
+	}
+}
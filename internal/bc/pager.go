@@ -0,0 +1,414 @@
+package bc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Page is one page of results of a paginated OData query
+type Page struct {
+	Number   int
+	Value    []map[string]interface{}
+	NextLink string
+	Bytes    int // size of the response body
+}
+
+// PagingMode selects how a Pager moves from one page to the next
+type PagingMode int
+
+const (
+	// ServerPaging follows @odata.nextLink, which is authoritative: a page
+	// without one is the last page
+	ServerPaging PagingMode = iota
+	// SkipPaging requests pages with $top and $skip, for endpoints that do not
+	// return @odata.nextLink. The query must have an $orderby on the entity key
+	// so that pages neither overlap nor miss records.
+	SkipPaging
+)
+
+// PageOptions bounds how much a Pager reads. Zero values mean no limit.
+type PageOptions struct {
+	// MaxRecords stops pagination once this many records have been returned;
+	// the page that crosses the limit is trimmed
+	MaxRecords int
+	// MaxBytes stops pagination once the response bodies read add up to this
+	// many bytes. It is checked after each page, so the last page is returned whole.
+	MaxBytes int64
+	// Mode selects server-driven (default) or $skip pagination
+	Mode PagingMode
+	// PageSize is the $top of each page in SkipPaging mode. It defaults to the
+	// client's MaxPageSize, or defaultSkipPageSize.
+	PageSize int
+	// OnPage, when set, is called after each page is fetched
+	OnPage func(Progress)
+}
+
+// Progress reports how far a paginated read has got
+type Progress struct {
+	Page    int   // pages fetched so far
+	Records int   // records returned so far
+	Bytes   int64 // response bytes read so far
+}
+
+// pageRequestDelay is the pause between page requests, to stay below rate limits
+const pageRequestDelay = 200 * time.Millisecond
+
+// defaultSkipPageSize is the SkipPaging page size when none is configured
+const defaultSkipPageSize = 1000
+
+// Cursor marks where a paginated read stopped, so it can be resumed later
+// without re-running the query from the start
+type Cursor struct {
+	// Endpoint is the page to read next, relative to the base URL
+	Endpoint string `json:"endpoint"`
+	// Offset is the number of records of that page already returned
+	Offset int `json:"offset,omitempty"`
+	// PageSize, when set, continues a $top query with $skip in steps of
+	// PageSize once the service returns no @odata.nextLink
+	PageSize int `json:"page_size,omitempty"`
+}
+
+// Pager reads a paginated OData query one page at a time:
+//
+//	pager := client.Pages(query, bc.PageOptions{MaxRecords: 1000})
+//	for pager.HasMore() {
+//		page, err := pager.Next(ctx)
+//		...
+//	}
+type Pager struct {
+	client  *Client
+	options PageOptions
+	log     zerolog.Logger
+
+	endpoint   string // endpoint of the next page
+	offset     int    // records to drop from the next page (resumed cursors)
+	pageSize   int    // $skip step when the service returns no nextLink, see Cursor
+	linked     bool   // the server has been paging with @odata.nextLink
+	maxResults int    // $top of the query, -1 when unlimited
+	page       int
+	records    int
+	bytes      int64
+	done       bool
+	truncated  bool
+	resume     *Cursor // where to continue after the budget stopped the pager
+	err        error
+}
+
+// Pages returns a Pager over the results of query
+func (c *Client) Pages(query *Query, options PageOptions) *Pager {
+	top, hasTop := query.TopValue()
+
+	pageSize := 0
+	if options.Mode == SkipPaging {
+		if !query.HasOrderBy() {
+			p := c.newPager(query.EntitySet(), options)
+			p.err = fmt.Errorf("$skip pagination requires a stable $orderby on the entity key")
+			return p
+		}
+		pageSize = options.PageSize
+		if pageSize <= 0 {
+			pageSize = c.config.MaxPageSize
+		}
+		if pageSize <= 0 {
+			pageSize = defaultSkipPageSize
+		}
+		if hasTop && top < pageSize {
+			pageSize = top
+		}
+		query = query.Clone().Top(pageSize)
+	}
+
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		err = fmt.Errorf("invalid query: %w", err)
+	}
+	p := c.newPager(endpoint, options)
+	p.err = err
+	p.pageSize = pageSize
+	if hasTop {
+		p.maxResults = top
+	}
+	return p
+}
+
+// ResumePages returns a Pager that continues a read from a cursor returned by
+// Pager.Cursor. The cursor is followed as is: only @odata.nextLink, or $skip
+// steps of the cursor's PageSize, lead to further pages.
+func (c *Client) ResumePages(cursor Cursor, options PageOptions) *Pager {
+	p := c.newPager(cursor.Endpoint, options)
+	p.offset = cursor.Offset
+	p.pageSize = cursor.PageSize
+	if cursor.Endpoint == "" {
+		p.err = fmt.Errorf("cursor has no endpoint")
+	}
+	return p
+}
+
+func (c *Client) newPager(endpoint string, options PageOptions) *Pager {
+	return &Pager{
+		client:     c,
+		options:    options,
+		endpoint:   endpoint,
+		maxResults: -1,
+		log: log.With().
+			Str("component", "bc_client").
+			Str("endpoint", endpoint).
+			Logger(),
+	}
+}
+
+// HasMore reports whether Next may return another page
+func (p *Pager) HasMore() bool {
+	return !p.done && p.err == nil
+}
+
+// Truncated reports whether pagination stopped because of the PageOptions budget
+// while more results were available
+func (p *Pager) Truncated() bool {
+	return p.truncated
+}
+
+// Cursor returns the position of the first record not yet returned, when
+// more results may follow: before the pager is exhausted, or after the
+// PageOptions budget stopped it
+func (p *Pager) Cursor() (Cursor, bool) {
+	switch {
+	case p.err != nil:
+		return Cursor{}, false
+	case !p.done:
+		return Cursor{Endpoint: p.endpoint, Offset: p.offset, PageSize: p.pageSize}, true
+	case p.resume != nil:
+		return *p.resume, true
+	}
+	return Cursor{}, false
+}
+
+// Records returns the number of records returned so far
+func (p *Pager) Records() int {
+	return p.records
+}
+
+// Next fetches the next page. It returns an error when the request fails or
+// ctx is cancelled; after an error HasMore reports false.
+func (p *Pager) Next(ctx context.Context) (*Page, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.done {
+		return nil, fmt.Errorf("no more pages")
+	}
+
+	page, err := p.fetch(ctx)
+	if err != nil {
+		p.err = err
+		p.done = true
+		return nil, err
+	}
+	if p.options.OnPage != nil {
+		p.options.OnPage(Progress{Page: p.page, Records: p.records, Bytes: p.bytes})
+	}
+	return page, nil
+}
+
+// fetch requests the next page and works out where the following one starts
+func (p *Pager) fetch(ctx context.Context) (*Page, error) {
+	p.page++
+	p.log.Debug().
+		Int("page", p.page).
+		Str("next_endpoint", p.endpoint).
+		Msg("Fetching page")
+
+	// Add delay between requests to respect rate limits (except for first request)
+	if p.page > 1 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pageRequestDelay):
+		}
+	}
+
+	resp, err := p.client.Get(ctx, p.endpoint)
+	if err != nil {
+		p.log.Error().Err(err).Int("page", p.page).Msg("Failed to fetch page")
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		p.log.Error().Err(err).Int("page", p.page).Msg("Failed to read response body")
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newODataError(resp.StatusCode, body)
+	}
+
+	var odataResp ODataResponse
+	if err := json.Unmarshal(body, &odataResp); err != nil {
+		p.log.Error().Err(err).Int("page", p.page).Msg("Failed to parse OData response")
+		return nil, fmt.Errorf("failed to parse OData response: %w", err)
+	}
+
+	current, fetched := p.endpoint, len(odataResp.Value)
+	page := &Page{
+		Number:   p.page,
+		Value:    odataResp.Value,
+		NextLink: odataResp.NextLink,
+		Bytes:    len(body),
+	}
+	p.bytes += int64(len(body))
+
+	// Drop the records of a resumed page that were already returned
+	skipped := 0
+	if p.offset > 0 {
+		skipped = min(p.offset, len(page.Value))
+		page.Value = page.Value[skipped:]
+		p.offset = 0
+	}
+
+	// Trim the page to the $top limit or the record budget, whichever is lower
+	limit, byBudget := p.maxResults, false
+	if p.options.MaxRecords > 0 && (limit < 0 || p.options.MaxRecords < limit) {
+		limit, byBudget = p.options.MaxRecords, true
+	}
+	if limit >= 0 && p.records+len(page.Value) >= limit {
+		kept := limit - p.records
+		more := len(page.Value) > kept || page.NextLink != "" || (p.pageSize > 0 && fetched >= p.pageSize)
+		page.Value = page.Value[:kept]
+		p.records = limit
+		p.done = true
+		p.truncated = byBudget && more
+		if p.truncated {
+			p.resume = &Cursor{Endpoint: current, Offset: skipped + kept, PageSize: p.pageSize}
+		}
+		p.log.Debug().Int("limit", limit).Bool("budget", byBudget).Msg("Reached record limit, stopping pagination")
+		return page, nil
+	}
+	p.records += len(page.Value)
+
+	p.log.Debug().
+		Int("page", p.page).
+		Int("results_in_page", len(page.Value)).
+		Int("total_results", p.records).
+		Bool("has_next_link", page.NextLink != "").
+		Msg("Page fetched")
+
+	if err := p.advance(page, current, fetched); err != nil {
+		return nil, err
+	}
+
+	if !p.done && p.options.MaxBytes > 0 && p.bytes >= p.options.MaxBytes {
+		p.log.Debug().Int64("bytes", p.bytes).Int64("max_bytes", p.options.MaxBytes).Msg("Reached byte budget, stopping pagination")
+		p.done = true
+		p.truncated = true
+		p.resume = &Cursor{Endpoint: p.endpoint, PageSize: p.pageSize}
+	}
+	return page, nil
+}
+
+// advance sets the endpoint of the page after current, which returned fetched
+// records, or marks the pager done
+func (p *Pager) advance(page *Page, current string, fetched int) error {
+	if page.NextLink != "" {
+		endpoint, err := p.client.relativeEndpoint(page.NextLink)
+		if err != nil {
+			p.log.Error().Err(err).Str("next_link", page.NextLink).Msg("Failed to parse next link")
+			return err
+		}
+		p.endpoint = endpoint
+		p.linked = true
+		return nil
+	}
+
+	// Without $skip paging, or once the service has paged with nextLink, a page
+	// without nextLink is the last one
+	if p.pageSize == 0 || p.linked {
+		p.log.Debug().Int("page", p.page).Msg("No nextLink, pagination complete")
+		p.done = true
+		return nil
+	}
+
+	// $skip paging: a short page is the last one
+	if fetched < p.pageSize {
+		p.log.Debug().
+			Int("results_in_page", fetched).
+			Int("page_size", p.pageSize).
+			Msg("Short page, pagination complete")
+		p.done = true
+		return nil
+	}
+	p.endpoint = withSkipDelta(current, fetched)
+	return nil
+}
+
+// withSkipDelta returns endpoint with its $skip increased by delta, keeping
+// all other query options as they are
+func withSkipDelta(endpoint string, delta int) string {
+	path, rawQuery, _ := strings.Cut(endpoint, "?")
+	var params []string
+	if rawQuery != "" {
+		params = strings.Split(rawQuery, "&")
+	}
+	for i, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		if name == "$skip" || strings.EqualFold(name, "%24skip") {
+			skip, _ := strconv.Atoi(value)
+			params[i] = "$skip=" + strconv.Itoa(skip+delta)
+			return path + "?" + strings.Join(params, "&")
+		}
+	}
+	return path + "?" + strings.Join(append(params, "$skip="+strconv.Itoa(delta)), "&")
+}
+
+// ForEachPage calls fn for each page of query until the results or the budget
+// are exhausted, fn returns an error or ctx is cancelled. It reports whether
+// the budget cut the results short.
+func (c *Client) ForEachPage(ctx context.Context, query *Query, options PageOptions, fn func(*Page) error) (bool, error) {
+	pager := c.Pages(query, options)
+	for pager.HasMore() {
+		page, err := pager.Next(ctx)
+		if err != nil {
+			return false, err
+		}
+		if err := fn(page); err != nil {
+			return false, err
+		}
+	}
+	return pager.Truncated(), pager.err
+}
+
+// relativeEndpoint converts a link returned by the service (such as
+// @odata.nextLink) into an endpoint relative to the base URL. Links outside
+// the service root are rejected so the bearer token is never sent elsewhere.
+func (c *Client) relativeEndpoint(link string) (string, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse base URL: %w", err)
+	}
+	next, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse next link: %w", err)
+	}
+	next = base.ResolveReference(next)
+
+	// Endpoints are appended to the base URL as is, so strip exactly its path
+	endpoint := strings.TrimPrefix(next.Path, base.Path)
+	inRoot := strings.HasPrefix(next.Path, base.Path) &&
+		(strings.HasSuffix(base.Path, "/") || endpoint == "" || strings.HasPrefix(endpoint, "/"))
+	if next.Scheme != base.Scheme || next.Host != base.Host || !inRoot {
+		return "", fmt.Errorf("next link %s is outside the service root %s", link, c.baseURL)
+	}
+	if next.RawQuery != "" {
+		endpoint += "?" + next.RawQuery
+	}
+	return endpoint, nil
+}
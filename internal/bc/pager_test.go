@@ -0,0 +1,375 @@
+package bc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pagedHandler serves total records in pages of pageSize, linking pages with @odata.nextLink
+func pagedHandler(total, pageSize int, requests *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		start, _ := strconv.Atoi(r.URL.Query().Get("$skiptoken"))
+
+		var resp ODataResponse
+		for i := start; i < total && i < start+pageSize; i++ {
+			resp.Value = append(resp.Value, map[string]interface{}{"No": fmt.Sprintf("%03d", i)})
+		}
+		if start+pageSize < total {
+			resp.NextLink = fmt.Sprintf("http://%s/Items?$skiptoken=%d", r.Host, start+pageSize)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestPager_PageByPage(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(5, 2, &requests))
+
+	pager := client.Pages(NewQuery("Items"), PageOptions{})
+	var sizes []int
+	for pager.HasMore() {
+		page, err := pager.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		sizes = append(sizes, len(page.Value))
+	}
+
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("page sizes = %v, want [2 2 1]", sizes)
+	}
+	if pager.Records() != 5 || pager.Truncated() {
+		t.Errorf("Records() = %d, Truncated() = %v", pager.Records(), pager.Truncated())
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+}
+
+func TestPager_MaxRecords(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 4, &requests))
+
+	var records []map[string]interface{}
+	truncated, err := client.ForEachPage(context.Background(), NewQuery("Items"), PageOptions{MaxRecords: 6}, func(page *Page) error {
+		records = append(records, page.Value...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPage() error = %v", err)
+	}
+	if len(records) != 6 || !truncated {
+		t.Errorf("got %d records, truncated = %v; want 6, true", len(records), truncated)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func TestPager_OnPage(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(5, 2, &requests))
+
+	var progress []string
+	options := PageOptions{OnPage: func(p Progress) {
+		progress = append(progress, fmt.Sprintf("%d:%d", p.Page, p.Records))
+	}}
+	if _, err := client.ForEachPage(context.Background(), NewQuery("Items"), options, func(*Page) error { return nil }); err != nil {
+		t.Fatalf("ForEachPage() error = %v", err)
+	}
+	if fmt.Sprint(progress) != "[1:2 2:4 3:5]" {
+		t.Errorf("progress = %v, want [1:2 2:4 3:5]", progress)
+	}
+}
+
+func TestPager_MaxBytes(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 2, &requests))
+
+	pages := 0
+	truncated, err := client.ForEachPage(context.Background(), NewQuery("Items"), PageOptions{MaxBytes: 1}, func(page *Page) error {
+		pages++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPage() error = %v", err)
+	}
+	if pages != 1 || !truncated {
+		t.Errorf("pages = %d, truncated = %v; want 1, true", pages, truncated)
+	}
+}
+
+func TestPager_TopIsNotTruncation(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 4, &requests))
+
+	pager := client.Pages(NewQuery("Items").Top(3), PageOptions{MaxRecords: 100})
+	page, err := pager.Next(context.Background())
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if len(page.Value) != 3 || pager.HasMore() || pager.Truncated() {
+		t.Errorf("len = %d, HasMore = %v, Truncated = %v", len(page.Value), pager.HasMore(), pager.Truncated())
+	}
+}
+
+func TestPager_Cancellation(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 2, &requests))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := client.ForEachPage(ctx, NewQuery("Items"), PageOptions{}, func(page *Page) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("ForEachPage() error = %v, want context.Canceled", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestPager_CallbackError(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 2, &requests))
+
+	stop := errors.New("stop")
+	_, err := client.ForEachPage(context.Background(), NewQuery("Items"), PageOptions{}, func(page *Page) error {
+		return stop
+	})
+	if !errors.Is(err, stop) || requests != 1 {
+		t.Errorf("ForEachPage() error = %v after %d requests, want stop after 1", err, requests)
+	}
+}
+
+func TestPager_HTTPError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"code":"BadRequest","message":"Invalid filter"}}`))
+	})
+
+	pager := client.Pages(NewQuery("Items"), PageOptions{})
+	_, err := pager.Next(context.Background())
+	var odataErr *ODataError
+	if !errors.As(err, &odataErr) || odataErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Next() error = %v, want ODataError 400", err)
+	}
+	if pager.HasMore() {
+		t.Error("HasMore() = true after error")
+	}
+}
+
+func TestClient_relativeEndpoint(t *testing.T) {
+	client := &Client{baseURL: "https://api.example.com/v2.0/tenant/Production/ODataV4/Company('CRONUS')/"}
+
+	endpoint, err := client.relativeEndpoint("https://api.example.com/v2.0/tenant/Production/ODataV4/Company('CRONUS')/Customers?$skiptoken=abc")
+	if err != nil {
+		t.Fatalf("relativeEndpoint() error = %v", err)
+	}
+	if endpoint != "Customers?$skiptoken=abc" {
+		t.Errorf("relativeEndpoint() = %v", endpoint)
+	}
+
+	for _, link := range []string{
+		"https://evil.example.com/v2.0/tenant/Production/ODataV4/Company('CRONUS')/Customers",
+		"https://api.example.com/v2.0/other/Production/ODataV4/Company('CRONUS')/Customers",
+	} {
+		if _, err := client.relativeEndpoint(link); err == nil || !strings.Contains(err.Error(), "outside the service root") {
+			t.Errorf("relativeEndpoint(%q) error = %v, want outside service root", link, err)
+		}
+	}
+}
+
+func TestPager_CursorResumesMidPage(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, pagedHandler(10, 4, &requests))
+	ctx := context.Background()
+
+	var first []map[string]interface{}
+	pager := client.Pages(NewQuery("Items"), PageOptions{MaxRecords: 6})
+	for pager.HasMore() {
+		page, err := pager.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		first = append(first, page.Value...)
+	}
+	cursor, ok := pager.Cursor()
+	if !ok || cursor.Endpoint != "Items?$skiptoken=4" || cursor.Offset != 2 {
+		t.Fatalf("Cursor() = %+v, %v; want Items?$skiptoken=4 at offset 2", cursor, ok)
+	}
+
+	var rest []map[string]interface{}
+	resumed := client.ResumePages(cursor, PageOptions{})
+	for resumed.HasMore() {
+		page, err := resumed.Next(ctx)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		rest = append(rest, page.Value...)
+	}
+
+	all := append(first, rest...)
+	if len(all) != 10 {
+		t.Fatalf("read %d records, want 10", len(all))
+	}
+	for i, record := range all {
+		if record["No"] != fmt.Sprintf("%03d", i) {
+			t.Errorf("record %d = %v, want %03d", i, record["No"], i)
+		}
+	}
+	if _, ok := resumed.Cursor(); ok {
+		t.Error("Cursor() ok after the last page")
+	}
+}
+
+func TestPager_CursorPageSize(t *testing.T) {
+	var skips []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		skips = append(skips, r.URL.Query().Get("$skip"))
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		var resp ODataResponse
+		for i := skip; i < 5 && i < skip+2; i++ {
+			resp.Value = append(resp.Value, map[string]interface{}{"No": i})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	pager := client.ResumePages(Cursor{Endpoint: "Items?$top=2", PageSize: 2}, PageOptions{})
+	records := 0
+	for pager.HasMore() {
+		page, err := pager.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		records += len(page.Value)
+	}
+	if records != 5 || strings.Join(skips, ",") != ",2,4" {
+		t.Errorf("records = %d, skips = %q; want 5 records with skips ,2,4", records, skips)
+	}
+}
+
+func TestWithSkipDelta(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"Items", "Items?$skip=5"},
+		{"Items?$top=5", "Items?$top=5&$skip=5"},
+		{"Items?$filter=No%20eq%20%271%27&$skip=10&$count=true", "Items?$filter=No%20eq%20%271%27&$skip=15&$count=true"},
+	}
+	for _, tt := range tests {
+		if got := withSkipDelta(tt.endpoint, 5); got != tt.want {
+			t.Errorf("withSkipDelta(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestPager_PreferMaxPageSize(t *testing.T) {
+	var prefer string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		prefer = r.Header.Get("Prefer")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[]}`))
+	})
+	client.config.MaxPageSize = 250
+
+	if _, err := client.GetPaginated(context.Background(), NewQuery("Items")); err != nil {
+		t.Fatalf("GetPaginated() error = %v", err)
+	}
+	if prefer != "odata.maxpagesize=250" {
+		t.Errorf("Prefer = %q, want odata.maxpagesize=250", prefer)
+	}
+}
+
+// skipHandler serves total records ordered by No, honoring only $top and $skip
+func skipHandler(total int, requests *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RawQuery)
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		top, _ := strconv.Atoi(r.URL.Query().Get("$top"))
+		var resp ODataResponse
+		for i := skip; i < total && i < skip+top; i++ {
+			resp.Value = append(resp.Value, map[string]interface{}{"No": i})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestPager_SkipPaging(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, skipHandler(7, &requests))
+
+	var records []map[string]interface{}
+	_, err := client.ForEachPage(context.Background(), NewQuery("Items").OrderBy("No"), PageOptions{Mode: SkipPaging, PageSize: 3}, func(page *Page) error {
+		records = append(records, page.Value...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPage() error = %v", err)
+	}
+	if len(records) != 7 {
+		t.Errorf("got %d records, want 7", len(records))
+	}
+	want := []string{"$orderby=No&$top=3", "$orderby=No&$top=3&$skip=3", "$orderby=No&$top=3&$skip=6"}
+	if strings.Join(requests, " ") != strings.Join(want, " ") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestPager_SkipPagingExactMultiple(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, skipHandler(6, &requests))
+
+	truncated, err := client.ForEachPage(context.Background(), NewQuery("Items").OrderBy("No"), PageOptions{Mode: SkipPaging, PageSize: 3}, func(page *Page) error {
+		return nil
+	})
+	if err != nil || truncated {
+		t.Fatalf("ForEachPage() = %v, %v", truncated, err)
+	}
+	// Two full pages, then an empty one to confirm the end
+	if len(requests) != 3 {
+		t.Errorf("requests = %v, want 3", requests)
+	}
+}
+
+func TestPager_SkipPagingWithTop(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, skipHandler(100, &requests))
+
+	var records []map[string]interface{}
+	_, err := client.ForEachPage(context.Background(), NewQuery("Items").OrderBy("No").Top(5), PageOptions{Mode: SkipPaging, PageSize: 3}, func(page *Page) error {
+		records = append(records, page.Value...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachPage() error = %v", err)
+	}
+	if len(records) != 5 || len(requests) != 2 {
+		t.Errorf("got %d records in %d requests, want 5 in 2", len(records), len(requests))
+	}
+}
+
+func TestPager_SkipPagingRequiresOrderBy(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	})
+
+	_, err := client.ForEachPage(context.Background(), NewQuery("Items"), PageOptions{Mode: SkipPaging}, func(page *Page) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "$orderby") {
+		t.Errorf("ForEachPage() error = %v, want $orderby error", err)
+	}
+}
@@ -0,0 +1,310 @@
+package bc
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query builds a correctly encoded OData request for an entity set.
+// Methods modify the query in place and return it so calls can be chained:
+//
+//	q := bc.NewQuery("Customers").Filter(bc.Eq("No", "10000")).Select("No", "Name").Top(10)
+type Query struct {
+	entitySet string
+	key       []KeyPart
+	filter    Filter
+	selects   []string
+	expands   []expandItem
+	orderBy   []string
+	top       *int
+	skip      int
+	count     bool
+	apply     string
+	search    string
+}
+
+// expandItem is a navigation path to expand with optional nested query options
+type expandItem struct {
+	path    string
+	options *Query
+}
+
+// NewQuery creates a query for an entity set (or any resource path relative to the base URL)
+func NewQuery(entitySet string) *Query {
+	return &Query{entitySet: entitySet}
+}
+
+// EntitySet returns the resource path the query targets
+func (q *Query) EntitySet() string {
+	return q.entitySet
+}
+
+// Key addresses a single entity by its key properties
+func (q *Query) Key(parts ...KeyPart) *Query {
+	q.key = parts
+	return q
+}
+
+// Filter sets the $filter expression. Calling it again combines the filters with and.
+func (q *Query) Filter(filter Filter) *Query {
+	if filter == nil {
+		return q
+	}
+	if q.filter == nil {
+		q.filter = filter
+	} else {
+		q.filter = And(q.filter, filter)
+	}
+	return q
+}
+
+// FilterExpr returns the current filter tree, or nil when unfiltered
+func (q *Query) FilterExpr() Filter {
+	return q.filter
+}
+
+// Select adds fields to $select
+func (q *Query) Select(fields ...string) *Query {
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			q.selects = append(q.selects, f)
+		}
+	}
+	return q
+}
+
+// Expand adds a navigation property to $expand. options, when not nil, supplies
+// nested query options such as $select, $filter, $top or a further $expand.
+func (q *Query) Expand(path string, options *Query) *Query {
+	if path = strings.TrimSpace(path); path != "" {
+		q.expands = append(q.expands, expandItem{path: path, options: options})
+	}
+	return q
+}
+
+// OrderBy adds $orderby clauses such as "Document_Date desc"
+func (q *Query) OrderBy(clauses ...string) *Query {
+	for _, c := range clauses {
+		if c = strings.TrimSpace(c); c != "" {
+			q.orderBy = append(q.orderBy, c)
+		}
+	}
+	return q
+}
+
+// HasOrderBy reports whether any $orderby clause is set
+func (q *Query) HasOrderBy() bool {
+	return len(q.orderBy) > 0
+}
+
+// OrderByClauses returns the $orderby clauses
+func (q *Query) OrderByClauses() []string {
+	return append([]string(nil), q.orderBy...)
+}
+
+// Top limits the number of results
+func (q *Query) Top(n int) *Query {
+	q.top = &n
+	return q
+}
+
+// TopValue returns the $top limit and whether one is set
+func (q *Query) TopValue() (int, bool) {
+	if q.top == nil {
+		return 0, false
+	}
+	return *q.top, true
+}
+
+// Skip skips the first n results
+func (q *Query) Skip(n int) *Query {
+	q.skip = n
+	return q
+}
+
+// SkipValue returns the $skip offset
+func (q *Query) SkipValue() int {
+	return q.skip
+}
+
+// WithCount requests @odata.count in the response
+func (q *Query) WithCount() *Query {
+	q.count = true
+	return q
+}
+
+// Apply sets the $apply transformation (e.g. groupby((Status))/aggregate(Amount with sum as Total))
+func (q *Query) Apply(expr string) *Query {
+	q.apply = strings.TrimSpace(expr)
+	return q
+}
+
+// Search sets the $search expression
+func (q *Query) Search(expr string) *Query {
+	q.search = strings.TrimSpace(expr)
+	return q
+}
+
+// Clone returns a copy of the query that can be modified independently
+func (q *Query) Clone() *Query {
+	clone := *q
+	clone.key = append([]KeyPart(nil), q.key...)
+	clone.selects = append([]string(nil), q.selects...)
+	clone.expands = append([]expandItem(nil), q.expands...)
+	clone.orderBy = append([]string(nil), q.orderBy...)
+	if q.top != nil {
+		top := *q.top
+		clone.top = &top
+	}
+	return &clone
+}
+
+// Endpoint renders the resource path and encoded query string, relative to the base URL
+func (q *Query) Endpoint() (string, error) {
+	path, err := q.path()
+	if err != nil {
+		return "", err
+	}
+
+	params, err := q.params()
+	if err != nil {
+		return "", err
+	}
+	return joinQuery(path, params, "&", true), nil
+}
+
+// CountEndpoint renders the /$count request for the entities matched by the query.
+// Only $filter and $search apply to a count.
+func (q *Query) CountEndpoint() (string, error) {
+	path, err := q.path()
+	if err != nil {
+		return "", err
+	}
+
+	counted := &Query{filter: q.filter, search: q.search}
+	params, err := counted.params()
+	if err != nil {
+		return "", err
+	}
+	return joinQuery(path+"/$count", params, "&", true), nil
+}
+
+// String renders the endpoint, for logging
+func (q *Query) String() string {
+	endpoint, err := q.Endpoint()
+	if err != nil {
+		return fmt.Sprintf("%s (invalid: %s)", q.entitySet, err.Error())
+	}
+	return endpoint
+}
+
+// path renders the entity set and optional key predicate
+func (q *Query) path() (string, error) {
+	if q.entitySet == "" {
+		return "", fmt.Errorf("query requires an entity set")
+	}
+	if len(q.key) == 0 {
+		return q.entitySet, nil
+	}
+	predicate, err := EntityKey(q.key)
+	if err != nil {
+		return "", err
+	}
+	return q.entitySet + keyEscaper.Replace(predicate), nil
+}
+
+// keyEscaper escapes the characters that would otherwise end or corrupt the
+// key path segment, leaving quotes, parentheses and commas readable
+var keyEscaper = strings.NewReplacer("%", "%25", "/", "%2F", "?", "%3F", "#", "%23", " ", "%20")
+
+type queryParam struct {
+	name  string
+	value string
+}
+
+// params renders the system query options in a fixed order
+func (q *Query) params() ([]queryParam, error) {
+	var params []queryParam
+
+	if q.filter != nil {
+		filter, err := q.filter.Render()
+		if err != nil {
+			return nil, fmt.Errorf("invalid $filter: %w", err)
+		}
+		if filter != "" {
+			params = append(params, queryParam{"$filter", filter})
+		}
+	}
+	if len(q.selects) > 0 {
+		params = append(params, queryParam{"$select", strings.Join(q.selects, ",")})
+	}
+	if len(q.expands) > 0 {
+		expands := make([]string, 0, len(q.expands))
+		for _, e := range q.expands {
+			if e.options == nil {
+				expands = append(expands, e.path)
+				continue
+			}
+			nested, err := e.options.params()
+			if err != nil {
+				return nil, fmt.Errorf("invalid $expand options for '%s': %w", e.path, err)
+			}
+			if len(nested) == 0 {
+				expands = append(expands, e.path)
+				continue
+			}
+			// Nested options are separated by ';' and are not URL encoded
+			// individually; the whole $expand value is encoded once below
+			expands = append(expands, joinQuery(e.path+"(", nested, ";", false)+")")
+		}
+		params = append(params, queryParam{"$expand", strings.Join(expands, ",")})
+	}
+	if len(q.orderBy) > 0 {
+		params = append(params, queryParam{"$orderby", strings.Join(q.orderBy, ",")})
+	}
+	if q.top != nil {
+		params = append(params, queryParam{"$top", strconv.Itoa(*q.top)})
+	}
+	if q.skip > 0 {
+		params = append(params, queryParam{"$skip", strconv.Itoa(q.skip)})
+	}
+	if q.count {
+		params = append(params, queryParam{"$count", "true"})
+	}
+	if q.apply != "" {
+		params = append(params, queryParam{"$apply", q.apply})
+	}
+	if q.search != "" {
+		params = append(params, queryParam{"$search", q.search})
+	}
+
+	return params, nil
+}
+
+// joinQuery appends params to path using sep; the first param is introduced by
+// '?' unless the path ends with '(' (nested expand options)
+func joinQuery(path string, params []queryParam, sep string, encode bool) string {
+	if len(params) == 0 {
+		return path
+	}
+	parts := make([]string, 0, len(params))
+	for _, p := range params {
+		value := p.value
+		if encode {
+			value = encodeQueryValue(value)
+		}
+		parts = append(parts, p.name+"="+value)
+	}
+	if strings.HasSuffix(path, "(") {
+		return path + strings.Join(parts, sep)
+	}
+	return path + "?" + strings.Join(parts, sep)
+}
+
+// encodeQueryValue percent-encodes a query option value. Spaces become %20
+// rather than '+', which OData servers do not reliably decode as a space.
+func encodeQueryValue(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
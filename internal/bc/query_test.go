@@ -0,0 +1,138 @@
+package bc
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestQuery_Endpoint(t *testing.T) {
+	query := NewQuery("Customers").
+		Filter(Comparison{Field: "Balance_LCY", Op: "gt", Type: "Edm.Decimal", Value: 1000.0}).
+		Filter(FunctionCall{Name: "startswith", Field: "Name", Type: "Edm.String", Value: "O'Brien"}).
+		Select("No", "Name").
+		OrderBy("Name desc").
+		Top(10).
+		Skip(20).
+		WithCount()
+
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		t.Fatalf("Endpoint() error = %v", err)
+	}
+
+	want := "Customers?$filter=Balance_LCY%20gt%201000%20and%20startswith%28Name%2C%27O%27%27Brien%27%29" +
+		"&$select=No%2CName&$orderby=Name%20desc&$top=10&$skip=20&$count=true"
+	if endpoint != want {
+		t.Errorf("Endpoint() = %v\nwant %v", endpoint, want)
+	}
+
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if got := parsed.Query().Get("$filter"); got != "Balance_LCY gt 1000 and startswith(Name,'O''Brien')" {
+		t.Errorf("decoded $filter = %v", got)
+	}
+}
+
+func TestQuery_NestedExpand(t *testing.T) {
+	lines := NewQuery("").
+		Select("Line_No", "Amount").
+		Filter(Comparison{Field: "Amount", Op: "gt", Value: float64(0)}).
+		Top(5)
+	query := NewQuery("SalesOrders").Expand("SalesLines", lines).Expand("Customer", nil)
+
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		t.Fatalf("Endpoint() error = %v", err)
+	}
+	parsed, _ := url.Parse(endpoint)
+	want := "SalesLines($filter=Amount gt 0;$select=Line_No,Amount;$top=5),Customer"
+	if got := parsed.Query().Get("$expand"); got != want {
+		t.Errorf("$expand = %v, want %v", got, want)
+	}
+}
+
+func TestQuery_Key(t *testing.T) {
+	query := NewQuery("Items").Key(KeyPart{Name: "No", Type: "Edm.String", Value: "A/B 1"}).Select("No")
+
+	endpoint, err := query.Endpoint()
+	if err != nil {
+		t.Fatalf("Endpoint() error = %v", err)
+	}
+	if endpoint != "Items('A%2FB%201')?$select=No" {
+		t.Errorf("Endpoint() = %v", endpoint)
+	}
+
+	if _, err := NewQuery("Items").Key(KeyPart{Name: "Id", Type: "Edm.Guid", Value: "x"}).Endpoint(); err == nil {
+		t.Error("Endpoint() with invalid key error = nil, want error")
+	}
+}
+
+func TestQuery_CountEndpoint(t *testing.T) {
+	query := NewQuery("Customers").Filter(Eq("City", "Milano")).Select("No").Top(5).OrderBy("No")
+
+	endpoint, err := query.CountEndpoint()
+	if err != nil {
+		t.Fatalf("CountEndpoint() error = %v", err)
+	}
+	if endpoint != "Customers/$count?$filter=City%20eq%20%27Milano%27" {
+		t.Errorf("CountEndpoint() = %v", endpoint)
+	}
+}
+
+func TestQuery_Clone(t *testing.T) {
+	original := NewQuery("Customers").Select("No").Top(10)
+	clone := original.Clone().Select("Name").Skip(10).Top(5)
+
+	if original.String() != "Customers?$select=No&$top=10" {
+		t.Errorf("original modified: %v", original.String())
+	}
+	if clone.String() != "Customers?$select=No%2CName&$top=5&$skip=10" {
+		t.Errorf("clone = %v", clone.String())
+	}
+}
+
+func TestQuery_ApplyAndSearch(t *testing.T) {
+	query := NewQuery("BI_Invoices").Apply("groupby((Customer_No))/aggregate(Amount with sum as Total)").Search("blue")
+
+	parsed, _ := url.Parse(query.String())
+	if got := parsed.Query().Get("$apply"); got != "groupby((Customer_No))/aggregate(Amount with sum as Total)" {
+		t.Errorf("$apply = %v", got)
+	}
+	if got := parsed.Query().Get("$search"); got != "blue" {
+		t.Errorf("$search = %v", got)
+	}
+}
+
+func TestFilter_Render(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    string
+		wantErr bool
+	}{
+		{"eq", Eq("No", "10000"), "No eq '10000'", false},
+		{"and or nesting", And(Eq("A", "1"), Or(Eq("B", "2"), Eq("C", "3"))), "A eq '1' and (B eq '2' or C eq '3')", false},
+		{"not", Not(Eq("Blocked", true)), "not (Blocked eq true)", false},
+		{"in", In{Field: "Status", Values: []interface{}{"Open", "Released"}}, "Status eq 'Open' or Status eq 'Released'", false},
+		{"raw inside and", And(RawFilter("A eq 1 or B eq 2"), Eq("C", float64(3))), "(A eq 1 or B eq 2) and C eq 3", false},
+		{"typed date", Comparison{Field: "Posting_Date", Op: "GE", Type: "Edm.Date", Value: "2024-01-01"}, "Posting_Date ge 2024-01-01", false},
+		{"bad operator", Comparison{Field: "A", Op: "=", Value: "1"}, "", true},
+		{"bad function", FunctionCall{Name: "like", Field: "A", Value: "x"}, "", true},
+		{"bad typed value", Comparison{Field: "Amount", Op: "gt", Type: "Edm.Decimal", Value: "lots"}, "", true},
+		{"empty logical", And(), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.filter.Render()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
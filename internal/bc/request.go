@@ -0,0 +1,305 @@
+package bc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// request is one call made through the request pipeline
+type request struct {
+	method     string
+	endpoint   string // relative to the base URL
+	root       bool   // endpoint is relative to the service root instead, when known
+	body       []byte
+	header     map[string]string // Accept, If-Match, ...
+	maxRetries int
+}
+
+// ErrOutcomeUnknown is returned for a write that failed in a way that leaves
+// open whether the service applied it, such as a timeout or a 5xx answer. It
+// is not repeated; read the entity to find out.
+var ErrOutcomeUnknown = errors.New("the write may or may not have been applied")
+
+// idempotent reports whether a request can be repeated without changing its
+// outcome. OData PATCH sets absolute values, so like PUT and DELETE it is
+// repeated freely; a POST could create the entity twice. A write conditional
+// on If-Match is not repeated either: had the first attempt been applied, the
+// repeat would fail with 412 (or 404 for a DELETE) and report a write that
+// succeeded as failed.
+func (r request) idempotent() bool {
+	if r.method == http.MethodPost {
+		return false
+	}
+	_, conditional := r.header["If-Match"]
+	return !conditional
+}
+
+// notSent reports whether a transport error happened before the request
+// reached the server, so that repeating it is safe whatever the method
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// do executes a request with the retry policy shared by every verb:
+//   - 401 invalidates the OAuth token, refreshes it and repeats the request
+//   - 429 waits for Retry-After (or 5s, 10s, 20s...) and repeats it
+//   - 5xx and transport errors are repeated with exponential backoff, but only
+//     for idempotent requests; other writes are only repeated when they were
+//     not sent, otherwise they fail with ErrOutcomeUnknown
+//
+// Throttled and unauthorized requests are rejected before being processed, so
+// they are repeated for POST too. 2xx and other 4xx responses are returned
+// with their body buffered; the caller checks the status.
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	log := log.With().
+		Str("component", "bc_client").
+		Str("method", r.method).
+		Str("endpoint", r.endpoint).
+		Int("max_retries", r.maxRetries).
+		Logger()
+
+	// Construct and parse the full URL to ensure proper encoding
+	base := c.baseURL
+	if r.root && c.serviceRoot != "" {
+		base = c.serviceRoot
+	}
+	fullURL := base + r.endpoint
+	parsedURL, err := url.Parse(fullURL)
+	if err != nil {
+		log.Error().Err(err).Str("url", fullURL).Msg("Failed to parse URL")
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	fullURL = parsedURL.String()
+
+	var lastErr error
+
+	for attempt := 0; attempt < r.maxRetries; attempt++ {
+		if attempt > 0 {
+			// Exponential backoff for non-rate-limit errors: 2s, 4s, 8s by default
+			backoff := c.retryBackoff(attempt)
+			log.Warn().
+				Int("attempt", attempt+1).
+				Dur("backoff", backoff).
+				Err(lastErr).
+				Msg("Retrying API request after error")
+
+			select {
+			case <-ctx.Done():
+				log.Error().Err(ctx.Err()).Msg("Context cancelled during retry")
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		log.Debug().
+			Int("attempt", attempt+1).
+			Msg("Getting OAuth token")
+
+		token, err := c.auth.GetToken(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Error().Err(err).Msg("Failed to get OAuth token")
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+
+		log.Debug().
+			Str("url", fullURL).
+			Str("base_url", c.baseURL).
+			Msg("Sending HTTP request")
+
+		resp, err := c.send(ctx, r, fullURL, token)
+		if err != nil {
+			// A cancelled request is not a transient failure, so it is not retried
+			if ctx.Err() != nil {
+				log.Debug().Err(ctx.Err()).Msg("Request cancelled")
+				return nil, ctx.Err()
+			}
+			if !r.idempotent() && !notSent(err) {
+				log.Error().Err(err).Msg("HTTP request failed, not retrying a request that may have been applied")
+				return nil, fmt.Errorf("%w: %s request failed: %w", ErrOutcomeUnknown, r.method, err)
+			}
+			log.Warn().Err(err).Msg("HTTP request failed")
+			lastErr = err
+			continue
+		}
+
+		log.Debug().Int("status_code", resp.StatusCode).Msg("Received HTTP response")
+
+		// Check for unauthorized (401) - token may have expired, refresh and retry
+		if resp.StatusCode == http.StatusUnauthorized {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			log.Warn().
+				Int("status_code", resp.StatusCode).
+				Str("status", resp.Status).
+				Str("response_body", string(bodyBytes)).
+				Msg("Unauthorized (401) - token may have expired, refreshing token")
+
+			// Invalidate current token
+			c.auth.InvalidateToken()
+
+			// Refresh token and retry
+			newToken, err := c.auth.GetToken(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				log.Error().Err(err).Msg("Failed to refresh token after 401")
+				lastErr = fmt.Errorf("failed to refresh token: %w", err)
+				continue
+			}
+
+			log.Info().Msg("Token refreshed successfully, retrying request")
+
+			// Retry the request with new token
+			resp, err = c.send(ctx, r, fullURL, newToken)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				if !r.idempotent() && !notSent(err) {
+					log.Error().Err(err).Msg("HTTP request failed after token refresh, not retrying a request that may have been applied")
+					return nil, fmt.Errorf("%w: %s request failed: %w", ErrOutcomeUnknown, r.method, err)
+				}
+				log.Warn().Err(err).Msg("HTTP request failed after token refresh")
+				lastErr = err
+				continue
+			}
+
+			log.Debug().Int("status_code", resp.StatusCode).Msg("Received HTTP response after token refresh")
+		}
+
+		// Check for rate limiting (429) - needs special handling
+		if resp.StatusCode == http.StatusTooManyRequests {
+			retryAfter := resp.Header.Get("Retry-After")
+			backoffDuration := rateLimitBackoff(retryAfter, attempt)
+
+			log.Warn().
+				Int("status_code", resp.StatusCode).
+				Str("status", resp.Status).
+				Str("retry_after", retryAfter).
+				Dur("backoff", backoffDuration).
+				Int("attempt", attempt+1).
+				Msg("Rate limit exceeded (429), waiting before retry")
+
+			resp.Body.Close()
+			lastErr = fmt.Errorf("rate limit exceeded (429)")
+
+			// Wait before retrying
+			select {
+			case <-ctx.Done():
+				log.Error().Err(ctx.Err()).Msg("Context cancelled during rate limit wait")
+				return nil, ctx.Err()
+			case <-time.After(backoffDuration):
+			}
+			continue
+		}
+
+		// Check for other server errors (5xx); a write that is not idempotent may have been applied
+		if resp.StatusCode >= 500 {
+			if !r.idempotent() {
+				bodyBytes, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				log.Error().
+					Int("status_code", resp.StatusCode).
+					Str("status", resp.Status).
+					Str("response_body", string(bodyBytes)).
+					Msg("Server error, not retrying a request that may have been applied")
+				return nil, fmt.Errorf("%w: %w", ErrOutcomeUnknown, newODataError(resp.StatusCode, bodyBytes))
+			}
+			log.Warn().
+				Int("status_code", resp.StatusCode).
+				Str("status", resp.Status).
+				Msg("Server error, will retry")
+			resp.Body.Close()
+			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
+			continue
+		}
+
+		// Success
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			log.Debug().Int("status_code", resp.StatusCode).Msg("Request successful")
+			return resp, nil
+		}
+
+		// Client error (4xx) - read body for error details
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		log.Error().
+			Int("status_code", resp.StatusCode).
+			Str("status", resp.Status).
+			Str("response_body", string(bodyBytes)).
+			Str("url", fullURL).
+			Msg("Request failed, not retrying")
+
+		// Return the response with its body so the caller can parse the error
+		resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		return resp, nil
+	}
+
+	log.Error().
+		Int("attempts", r.maxRetries).
+		Err(lastErr).
+		Msg("Max retries exceeded")
+	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+}
+
+// send makes a single attempt of a request
+func (c *Client) send(ctx context.Context, r request, fullURL, token string) (*http.Response, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, fullURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if r.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.method == http.MethodGet && c.config.MaxPageSize > 0 {
+		// Ask for server-driven paging; the service continues with @odata.nextLink
+		req.Header.Set("Prefer", fmt.Sprintf("odata.maxpagesize=%d", c.config.MaxPageSize))
+	}
+	for name, value := range r.header {
+		req.Header.Set(name, value)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// rateLimitBackoff returns the wait after a 429: the Retry-After header in
+// seconds, otherwise an exponential backoff of 5s, 10s, 20s...
+func rateLimitBackoff(retryAfter string, attempt int) time.Duration {
+	if retryAfter == "" {
+		return time.Duration(1<<uint(attempt)) * 5 * time.Second
+	}
+	if secs, err := strconv.ParseFloat(retryAfter, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return time.Duration(attempt+1) * 5 * time.Second
+}
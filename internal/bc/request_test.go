@@ -0,0 +1,96 @@
+package bc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClient_WriteRetries(t *testing.T) {
+	var attempts []string
+	responses := []int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusCreated}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts = append(attempts, r.Method)
+		status := http.StatusInternalServerError
+		if len(responses) > 0 {
+			status, responses = responses[0], responses[1:]
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		if status == http.StatusCreated {
+			_, _ = w.Write([]byte(`{"No":"001"}`))
+		}
+	})
+	client.config.RetryBackoff = time.Millisecond
+	ctx := context.Background()
+
+	// 401 and 429 are rejected before processing, so even a POST is repeated
+	result, err := client.Post(ctx, "test", []byte(`{"Name":"Test"}`))
+	if err != nil || result["No"] != "001" {
+		t.Fatalf("Post() = %v, %v; want the entity after retrying", result, err)
+	}
+	if len(attempts) != 3 {
+		t.Errorf("POST attempts = %d, want 3", len(attempts))
+	}
+
+	// A POST that fails on the server may have been applied, so it is not repeated
+	attempts = nil
+	var odataErr *ODataError
+	_, err = client.Post(ctx, "test", []byte(`{}`))
+	if !errors.As(err, &odataErr) || odataErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Post() error = %v, want status 500", err)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("POST attempts after a 500 = %d, want 1", len(attempts))
+	}
+
+	if !errors.Is(err, ErrOutcomeUnknown) {
+		t.Errorf("Post() error = %v, want ErrOutcomeUnknown", err)
+	}
+
+	// A conditional write is not repeated either: a repeat of an applied
+	// write would fail with 412 and report it as a conflict
+	attempts = nil
+	_, err = client.Patch(ctx, "test('001')", []byte(`{}`), `W/"1"`)
+	var conflict *ConflictError
+	if !errors.Is(err, ErrOutcomeUnknown) || errors.As(err, &conflict) {
+		t.Errorf("Patch() error = %v, want ErrOutcomeUnknown", err)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("conditional PATCH attempts after a 500 = %d, want 1", len(attempts))
+	}
+
+	// DELETE is idempotent and is repeated until the retries run out
+	attempts = nil
+	if err := client.Delete(ctx, "test('001')", ""); err == nil || !strings.Contains(err.Error(), "max retries exceeded") {
+		t.Errorf("Delete() error = %v, want max retries exceeded", err)
+	}
+	if len(attempts) != defaultMaxRetries {
+		t.Errorf("DELETE attempts = %d, want %d", len(attempts), defaultMaxRetries)
+	}
+}
+
+func TestRateLimitBackoff(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		attempt    int
+		want       time.Duration
+	}{
+		{"", 0, 5 * time.Second},
+		{"", 2, 20 * time.Second},
+		{"3", 0, 3 * time.Second},
+		{"1.5", 0, 1500 * time.Millisecond},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, 0},
+		{"soon", 1, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := rateLimitBackoff(tt.retryAfter, tt.attempt); got != tt.want {
+			t.Errorf("rateLimitBackoff(%q, %d) = %v, want %v", tt.retryAfter, tt.attempt, got, tt.want)
+		}
+	}
+}
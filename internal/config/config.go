@@ -0,0 +1,520 @@
+// Package config loads the server configuration from an optional YAML, JSON
+// or TOML file and from environment variables, which override the file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"gopkg.in/yaml.v3"
+)
+
+// Business Central endpoints used for profiles that set a tenant or environment
+// without their own base path or token URL
+const (
+	serviceRootURL = "https://api.businesscentral.dynamics.com/v2.0/%s/%s/ODataV4/"
+	tokenURL       = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+)
+
+// Config is the complete server configuration
+type Config struct {
+	BusinessCentral Connection            `yaml:"business_central"`
+	Profiles        map[string]Connection `yaml:"profiles"`
+	Retry           Retry                 `yaml:"retry"`
+	Tools           Tools                 `yaml:"tools"`
+	Cache           Cache                 `yaml:"cache"`
+	Server          Server                `yaml:"server"`
+}
+
+// Connection holds the settings of a Business Central connection. In
+// profiles, unset settings are taken from business_central.
+type Connection struct {
+	GrantType    string `yaml:"grant_type"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	ScopeAPI     string `yaml:"scope_api"`
+	TokenURL     string `yaml:"token_url"`
+	ContentType  string `yaml:"content_type"`
+	BasePath     string `yaml:"base_path"`
+	TenantID     string `yaml:"tenant_id"`
+	Environment  string `yaml:"environment"`
+	Company      string `yaml:"company"`
+	APITimeout   int    `yaml:"api_timeout"`
+	MaxPageSize  *int   `yaml:"max_page_size"` // nil inherits, 0 leaves the page size to the service
+}
+
+// Retry is the retry policy of Business Central requests
+type Retry struct {
+	MaxRetries int           `yaml:"max_retries"`
+	Backoff    time.Duration `yaml:"backoff"`
+}
+
+// Tools restricts and limits tool calls
+type Tools struct {
+	ReadOnly   bool                       `yaml:"read_only"`
+	Allow      []string                   `yaml:"allow"` // empty allows every tool
+	Deny       []string                   `yaml:"deny"`
+	EntitySets map[string]EntitySetWrites `yaml:"entity_sets"` // "*" applies to unlisted entity sets
+	Timeout    time.Duration              `yaml:"timeout"`
+	Timeouts   map[string]time.Duration   `yaml:"timeouts"`
+}
+
+// EntitySetWrites lists the write operations permitted on an entity set:
+// create, update and delete, or POST, PATCH and DELETE
+type EntitySetWrites struct {
+	Allow   []string `yaml:"allow"` // empty allows every operation
+	Deny    []string `yaml:"deny"`
+	Confirm bool     `yaml:"confirm"` // writes need a dry run and its confirmation token
+}
+
+// Cache controls the caches of the server
+type Cache struct {
+	MetadataTTL time.Duration `yaml:"metadata_ttl"`
+}
+
+// Server holds the MCP transport settings
+type Server struct {
+	Transport      string   `yaml:"transport"`
+	HTTPAddr       string   `yaml:"http_addr"`
+	HTTPToken      string   `yaml:"http_token"`
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	MaxConcurrency int      `yaml:"max_concurrency"` // 0 removes the limit
+	PromptsFile    string   `yaml:"prompts_file"`
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Default returns the configuration used for the settings that neither the
+// file nor the environment set
+func Default() Config {
+	maxPageSize := 1000
+	return Config{
+		BusinessCentral: Connection{
+			GrantType:   "client_credentials",
+			ContentType: "application/x-www-form-urlencoded",
+			Environment: "Production",
+			APITimeout:  90,
+			MaxPageSize: &maxPageSize,
+		},
+		Retry: Retry{
+			MaxRetries: 5,
+			Backoff:    2 * time.Second,
+		},
+		Cache: Cache{
+			MetadataTTL: 30 * time.Minute,
+		},
+		Server: Server{
+			Transport:      "stdio",
+			HTTPAddr:       "127.0.0.1:8080",
+			MaxConcurrency: 8,
+		},
+	}
+}
+
+// Load reads the configuration file at path, when path is not empty, applies
+// the environment variable overrides and validates the result
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return cfg, err
+		}
+	}
+
+	var problems []string
+	problems = append(problems, cfg.applyEnv(os.Getenv)...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a configuration file over cfg. The format follows the file
+// extension: .yaml, .yml, .json or .toml.
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var document map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".json":
+		err = json.Unmarshal(data, &document)
+	case ".toml":
+		_, err = toml.Decode(string(data), &document)
+	default:
+		return fmt.Errorf("unsupported configuration file extension '%s' (use .yaml, .yml, .json or .toml)", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+
+	var missing []string
+	interpolated := interpolate(document, os.LookupEnv, &missing)
+	if len(missing) > 0 {
+		problems := make([]string, 0, len(missing))
+		for _, name := range missing {
+			problems = append(problems, fmt.Sprintf("environment variable %s referenced in %s is not set", name, path))
+		}
+		return &ValidationError{Problems: problems}
+	}
+
+	// Re-encode the document so every format is decoded with the same rules,
+	// rejecting unknown settings
+	normalized, err := yaml.Marshal(interpolated)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file %s: %w", path, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(normalized))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	return nil
+}
+
+// variablePattern matches ${VAR} and ${VAR:-default} references
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolate replaces ${VAR} references in the string values of a decoded
+// document. ${VAR:-default} uses default when VAR is unset or empty; other
+// unset variables are added to missing. A value with references becomes an
+// untagged YAML scalar, so that its type is resolved again from the
+// substituted text: max_page_size: ${PAGE} decodes as an integer.
+func interpolate(value interface{}, lookup func(string) (string, bool), missing *[]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if !variablePattern.MatchString(v) {
+			return v
+		}
+		substituted := variablePattern.ReplaceAllStringFunc(v, func(reference string) string {
+			match := variablePattern.FindStringSubmatch(reference)
+			if value, ok := lookup(match[1]); ok && value != "" {
+				return value
+			}
+			if strings.Contains(reference, ":-") {
+				return match[2]
+			}
+			*missing = append(*missing, match[1])
+			return ""
+		})
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: substituted}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = interpolate(item, lookup, missing)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = interpolate(item, lookup, missing)
+		}
+	}
+	return value
+}
+
+// applyEnv overrides the configuration with the environment variables that are
+// set, returning the ones with invalid values
+func (cfg *Config) applyEnv(getenv func(string) string) []string {
+	var problems []string
+	str := func(name string, target *string) {
+		if value := getenv(name); value != "" {
+			*target = value
+		}
+	}
+	integer := func(name string, target *int) {
+		if value := getenv(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be an integer, got %q", name, value))
+				return
+			}
+			*target = n
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value := getenv(name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s must be a duration such as 30s or 5m, got %q", name, value))
+				return
+			}
+			*target = d
+		}
+	}
+	connection := func(prefix string, c *Connection) {
+		str(prefix+"GRANT_TYPE", &c.GrantType)
+		str(prefix+"CLIENT_ID", &c.ClientID)
+		str(prefix+"CLIENT_SECRET", &c.ClientSecret)
+		str(prefix+"SCOPE_API", &c.ScopeAPI)
+		str(prefix+"TOKEN_URL", &c.TokenURL)
+		str(prefix+"CONTENT_TYPE", &c.ContentType)
+		str(prefix+"BASE_PATH", &c.BasePath)
+		str(prefix+"TENANT_ID", &c.TenantID)
+		str(prefix+"ENVIRONMENT", &c.Environment)
+		str(prefix+"COMPANY", &c.Company)
+		integer(prefix+"API_TIMEOUT", &c.APITimeout)
+		if value := getenv(prefix + "MAX_PAGE_SIZE"); value != "" {
+			size, err := strconv.Atoi(value)
+			switch {
+			case err != nil:
+				problems = append(problems, fmt.Sprintf("%sMAX_PAGE_SIZE must be an integer, got %q", prefix, value))
+			case size < 0:
+				problems = append(problems, fmt.Sprintf("%sMAX_PAGE_SIZE must not be negative, got %d", prefix, size))
+			default:
+				c.MaxPageSize = &size
+			}
+		}
+	}
+
+	connection("BC_", &cfg.BusinessCentral)
+	// BC_PROFILES adds profiles configured only through BC_PROFILE_<NAME>_<SETTING>
+	for _, name := range splitList(getenv("BC_PROFILES")) {
+		if _, ok := cfg.Profiles[name]; !ok {
+			if cfg.Profiles == nil {
+				cfg.Profiles = make(map[string]Connection)
+			}
+			cfg.Profiles[name] = Connection{}
+		}
+	}
+	for name, profile := range cfg.Profiles {
+		connection(ProfileEnvPrefix(name), &profile)
+		cfg.Profiles[name] = profile
+	}
+
+	integer("BC_MAX_RETRIES", &cfg.Retry.MaxRetries)
+	duration("BC_RETRY_BACKOFF", &cfg.Retry.Backoff)
+	duration("MCP_METADATA_CACHE_TTL", &cfg.Cache.MetadataTTL)
+
+	if value := getenv("MCP_READ_ONLY"); value != "" {
+		readOnly, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("MCP_READ_ONLY must be true or false, got %q", value))
+		}
+		cfg.Tools.ReadOnly = readOnly
+	}
+	if value := getenv("MCP_ALLOWED_TOOLS"); value != "" {
+		cfg.Tools.Allow = splitList(value)
+	}
+	if value := getenv("MCP_DENIED_TOOLS"); value != "" {
+		cfg.Tools.Deny = splitList(value)
+	}
+	duration("MCP_TOOL_TIMEOUT", &cfg.Tools.Timeout)
+	// MCP_TOOL_TIMEOUTS sets the deadline per tool, e.g. bc_odata_query=10m,bc_odata_count=30s
+	for _, item := range splitList(getenv("MCP_TOOL_TIMEOUTS")) {
+		tool, value, _ := strings.Cut(item, "=")
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("MCP_TOOL_TIMEOUTS has an invalid timeout %q for tool %s", value, tool))
+			continue
+		}
+		if cfg.Tools.Timeouts == nil {
+			cfg.Tools.Timeouts = make(map[string]time.Duration)
+		}
+		cfg.Tools.Timeouts[strings.TrimSpace(tool)] = timeout
+	}
+
+	str("MCP_TRANSPORT", &cfg.Server.Transport)
+	str("MCP_HTTP_ADDR", &cfg.Server.HTTPAddr)
+	str("MCP_HTTP_TOKEN", &cfg.Server.HTTPToken)
+	if value := getenv("MCP_HTTP_ALLOWED_ORIGINS"); value != "" {
+		cfg.Server.AllowedOrigins = splitList(value)
+	}
+	integer("MCP_MAX_CONCURRENCY", &cfg.Server.MaxConcurrency)
+	str("MCP_PROMPTS_FILE", &cfg.Server.PromptsFile)
+	return problems
+}
+
+// ProfileEnvPrefix returns the prefix of the environment variables of a
+// profile, e.g. BC_PROFILE_SANDBOX_ for sandbox
+func ProfileEnvPrefix(name string) string {
+	return "BC_PROFILE_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name)) + "_"
+}
+
+// validate returns every problem of the configuration
+func (cfg *Config) validate() []string {
+	var problems []string
+	required := func(section string, c Connection) {
+		for _, field := range []struct{ name, value string }{
+			{"client_id", c.ClientID},
+			{"client_secret", c.ClientSecret},
+			{"scope_api", c.ScopeAPI},
+			{"token_url", c.TokenURL},
+			{"base_path", c.BasePath},
+		} {
+			if field.value == "" {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", section, field.name))
+			}
+		}
+		if c.APITimeout < 0 {
+			problems = append(problems, fmt.Sprintf("%s.api_timeout must not be negative", section))
+		}
+		if c.MaxPageSize != nil && *c.MaxPageSize < 0 {
+			problems = append(problems, fmt.Sprintf("%s.max_page_size must not be negative", section))
+		}
+	}
+
+	required("business_central", cfg.BusinessCentral)
+	for _, name := range cfg.ProfileNames() {
+		if name == "default" {
+			problems = append(problems, "profiles.default is reserved for the business_central connection")
+			continue
+		}
+		profile, err := cfg.profile(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("profiles.%s: %v", name, err))
+			continue
+		}
+		required("profiles."+name, profile)
+	}
+
+	if cfg.Retry.MaxRetries < 1 {
+		problems = append(problems, "retry.max_retries must be at least 1")
+	}
+	if cfg.Retry.Backoff < 0 {
+		problems = append(problems, "retry.backoff must not be negative")
+	}
+	if cfg.Cache.MetadataTTL <= 0 {
+		problems = append(problems, "cache.metadata_ttl must be positive")
+	}
+	if cfg.Tools.Timeout < 0 {
+		problems = append(problems, "tools.timeout must not be negative")
+	}
+	for tool, timeout := range cfg.Tools.Timeouts {
+		if timeout < 0 {
+			problems = append(problems, fmt.Sprintf("tools.timeouts.%s must not be negative", tool))
+		}
+	}
+	if cfg.Server.Transport != "stdio" && cfg.Server.Transport != "http" {
+		problems = append(problems, fmt.Sprintf("server.transport must be stdio or http, got %q", cfg.Server.Transport))
+	}
+	if cfg.Server.MaxConcurrency < 0 {
+		problems = append(problems, "server.max_concurrency must not be negative")
+	}
+	return problems
+}
+
+// ProfileNames returns the names of the additional profiles, sorted
+func (cfg *Config) ProfileNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profile returns a profile with the unset settings taken from business_central.
+// A profile on another tenant or environment gets its own URLs.
+func (cfg *Config) profile(name string) (Connection, error) {
+	base, p := cfg.BusinessCentral, cfg.Profiles[name]
+	inherit := func(target *string, value string) {
+		if *target == "" {
+			*target = value
+		}
+	}
+	movedTenant := p.TenantID != "" && p.TenantID != base.TenantID
+	movedEnvironment := movedTenant || p.Environment != "" && p.Environment != base.Environment
+
+	inherit(&p.GrantType, base.GrantType)
+	inherit(&p.ClientID, base.ClientID)
+	inherit(&p.ClientSecret, base.ClientSecret)
+	inherit(&p.ScopeAPI, base.ScopeAPI)
+	inherit(&p.ContentType, base.ContentType)
+	inherit(&p.TenantID, base.TenantID)
+	inherit(&p.Environment, base.Environment)
+	inherit(&p.Company, base.Company)
+	if p.APITimeout == 0 {
+		p.APITimeout = base.APITimeout
+	}
+	if p.MaxPageSize == nil {
+		p.MaxPageSize = base.MaxPageSize
+	}
+	if p.BasePath == "" {
+		if movedEnvironment {
+			if p.TenantID == "" {
+				return p, fmt.Errorf("base_path or tenant_id is required for another environment")
+			}
+			p.BasePath = fmt.Sprintf(serviceRootURL, p.TenantID, p.Environment)
+		} else {
+			p.BasePath = base.BasePath
+		}
+	}
+	if p.TokenURL == "" {
+		if movedTenant {
+			p.TokenURL = fmt.Sprintf(tokenURL, p.TenantID)
+		} else {
+			p.TokenURL = base.TokenURL
+		}
+	}
+	return p, nil
+}
+
+// ClientConfig returns the client configuration of the business_central connection
+func (cfg *Config) ClientConfig() bc.Config {
+	return cfg.clientConfig(cfg.BusinessCentral)
+}
+
+// ProfileConfig returns the client configuration of a profile
+func (cfg *Config) ProfileConfig(name string) (bc.Config, error) {
+	profile, err := cfg.profile(name)
+	if err != nil {
+		return bc.Config{}, err
+	}
+	return cfg.clientConfig(profile), nil
+}
+
+func (cfg *Config) clientConfig(c Connection) bc.Config {
+	maxPageSize := 0
+	if c.MaxPageSize != nil {
+		maxPageSize = *c.MaxPageSize
+	}
+	return bc.Config{
+		GrantType:    c.GrantType,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		ScopeAPI:     c.ScopeAPI,
+		TokenURL:     c.TokenURL,
+		ContentType:  c.ContentType,
+		BasePath:     c.BasePath,
+		TenantID:     c.TenantID,
+		Environment:  c.Environment,
+		Company:      c.Company,
+		APITimeout:   c.APITimeout,
+		MaxPageSize:  maxPageSize,
+		MaxRetries:   cfg.Retry.MaxRetries,
+		RetryBackoff: cfg.Retry.Backoff,
+	}
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+business_central:
+  client_id: id
+  client_secret: ${TEST_BC_SECRET}
+  scope_api: https://api.businesscentral.dynamics.com/.default
+  token_url: https://login.microsoftonline.com/tenant/oauth2/v2.0/token
+  base_path: https://api.businesscentral.dynamics.com/v2.0/tenant/Production/ODataV4/
+  tenant_id: tenant
+  company: ${TEST_BC_COMPANY:-CRONUS}
+profiles:
+  sandbox:
+    environment: Sandbox
+retry:
+  max_retries: 3
+  backoff: 500ms
+tools:
+  allow: [bc_odata_query, bc_odata_count]
+  timeouts:
+    bc_odata_query: 10m
+cache:
+  metadata_ttl: 1h
+server:
+  transport: http
+`
+
+const jsonConfig = `{
+  "business_central": {
+    "client_id": "id",
+    "client_secret": "${TEST_BC_SECRET}",
+    "scope_api": "https://api.businesscentral.dynamics.com/.default",
+    "token_url": "https://login.microsoftonline.com/tenant/oauth2/v2.0/token",
+    "base_path": "https://api.businesscentral.dynamics.com/v2.0/tenant/Production/ODataV4/",
+    "tenant_id": "tenant",
+    "company": "${TEST_BC_COMPANY:-CRONUS}"
+  },
+  "profiles": {"sandbox": {"environment": "Sandbox"}},
+  "retry": {"max_retries": 3, "backoff": "500ms"},
+  "tools": {"allow": ["bc_odata_query", "bc_odata_count"], "timeouts": {"bc_odata_query": "10m"}},
+  "cache": {"metadata_ttl": "1h"},
+  "server": {"transport": "http"}
+}`
+
+const tomlConfig = `
+# Production tenant
+[business_central]
+client_id = "id"
+client_secret = "${TEST_BC_SECRET}"
+scope_api = "https://api.businesscentral.dynamics.com/.default"
+token_url = "https://login.microsoftonline.com/tenant/oauth2/v2.0/token"
+base_path = "https://api.businesscentral.dynamics.com/v2.0/tenant/Production/ODataV4/"
+tenant_id = "tenant"
+company = "${TEST_BC_COMPANY:-CRONUS}"
+
+[profiles.sandbox]
+environment = "Sandbox"
+
+[retry]
+max_retries = 3
+backoff = "500ms"
+
+[tools]
+allow = [
+  "bc_odata_query",
+  "bc_odata_count", # counting is cheap
+]
+timeouts = { bc_odata_query = "10m" }
+
+[cache]
+metadata_ttl = "1h"
+
+[server]
+transport = 'http'
+`
+
+// writeConfig writes a configuration file into a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoad_Formats(t *testing.T) {
+	t.Setenv("TEST_BC_SECRET", "secret")
+
+	for name, content := range map[string]string{
+		"config.yaml": yamlConfig,
+		"config.json": jsonConfig,
+		"config.toml": tomlConfig,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			client := cfg.ClientConfig()
+			if client.ClientSecret != "secret" || client.Company != "CRONUS" || client.MaxPageSize != 1000 {
+				t.Errorf("ClientConfig() = %+v, want interpolated secret, default company and page size", client)
+			}
+			if client.MaxRetries != 3 || client.RetryBackoff != 500*time.Millisecond {
+				t.Errorf("retry policy = %d, %s", client.MaxRetries, client.RetryBackoff)
+			}
+			if !reflect.DeepEqual(cfg.Tools.Allow, []string{"bc_odata_query", "bc_odata_count"}) || cfg.Tools.Timeouts["bc_odata_query"] != 10*time.Minute {
+				t.Errorf("tools = %+v", cfg.Tools)
+			}
+			if cfg.Cache.MetadataTTL != time.Hour || cfg.Server.Transport != "http" || cfg.Server.MaxConcurrency != 8 {
+				t.Errorf("cache = %+v, server = %+v", cfg.Cache, cfg.Server)
+			}
+
+			sandbox, err := cfg.ProfileConfig("sandbox")
+			if err != nil {
+				t.Fatalf("ProfileConfig() error = %v", err)
+			}
+			if sandbox.BasePath != "https://api.businesscentral.dynamics.com/v2.0/tenant/Sandbox/ODataV4/" || sandbox.ClientSecret != "secret" || sandbox.Company != "CRONUS" {
+				t.Errorf("sandbox profile = %+v, want the sandbox service root and inherited settings", sandbox)
+			}
+		})
+	}
+}
+
+func TestLoad_InterpolatedTypes(t *testing.T) {
+	t.Setenv("TEST_BC_PAGE", "250")
+	t.Setenv("TEST_BC_READ_ONLY", "true")
+	t.Setenv("TEST_BC_SECRET", "0012")
+
+	for name, content := range map[string]string{
+		"config.yaml": "business_central:\n  client_id: id\n  client_secret: ${TEST_BC_SECRET}\n  base_path: https://example.test/ODataV4/\n  scope_api: api\n  token_url: https://example.test/token\n  max_page_size: ${TEST_BC_PAGE}\ntools:\n  read_only: ${TEST_BC_READ_ONLY}\n",
+		"config.json": `{"business_central":{"client_id":"id","client_secret":"${TEST_BC_SECRET}","base_path":"https://example.test/ODataV4/","scope_api":"api","token_url":"https://example.test/token","max_page_size":"${TEST_BC_PAGE}"},"tools":{"read_only":"${TEST_BC_READ_ONLY}"}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if client := cfg.ClientConfig(); client.MaxPageSize != 250 || client.ClientSecret != "0012" {
+				t.Errorf("ClientConfig() = %+v, want page size 250 and the secret as written", client)
+			}
+			if !cfg.Tools.ReadOnly {
+				t.Error("tools.read_only = false, want true")
+			}
+		})
+	}
+}
+
+func TestLoad_EnvironmentOverrides(t *testing.T) {
+	t.Setenv("TEST_BC_SECRET", "secret")
+	t.Setenv("BC_COMPANY", "CRONUS Italia")
+	t.Setenv("BC_MAX_RETRIES", "7")
+	t.Setenv("BC_PROFILE_SANDBOX_COMPANY", "CRONUS Test")
+	t.Setenv("BC_PROFILES", "test")
+	t.Setenv("BC_PROFILE_TEST_BASE_PATH", "https://example.test/ODataV4/")
+	t.Setenv("MCP_TOOL_TIMEOUTS", "bc_odata_count=30s")
+
+	cfg, err := Load(writeConfig(t, "config.yaml", yamlConfig))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.BusinessCentral.Company != "CRONUS Italia" || cfg.Retry.MaxRetries != 7 {
+		t.Errorf("overrides not applied: company %q, retries %d", cfg.BusinessCentral.Company, cfg.Retry.MaxRetries)
+	}
+	if sandbox, _ := cfg.ProfileConfig("sandbox"); sandbox.Company != "CRONUS Test" {
+		t.Errorf("sandbox company = %q, want the profile override", sandbox.Company)
+	}
+	if names := cfg.ProfileNames(); !reflect.DeepEqual(names, []string{"sandbox", "test"}) {
+		t.Errorf("ProfileNames() = %v, want sandbox and test", names)
+	}
+	if test, _ := cfg.ProfileConfig("test"); test.BasePath != "https://example.test/ODataV4/" || test.ClientID != "id" {
+		t.Errorf("test profile = %+v", test)
+	}
+	if cfg.Tools.Timeouts["bc_odata_query"] != 10*time.Minute || cfg.Tools.Timeouts["bc_odata_count"] != 30*time.Second {
+		t.Errorf("tool timeouts = %v, want file and environment entries", cfg.Tools.Timeouts)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	t.Setenv("BC_MAX_RETRIES", "many")
+	t.Setenv("BC_MAX_PAGE_SIZE", "-5")
+
+	_, err := Load(writeConfig(t, "config.yaml", "server:\n  transport: grpc\nprofiles:\n  other:\n    environment: Sandbox\n"))
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Load() error = %v, want a ValidationError", err)
+	}
+	for _, want := range []string{
+		"BC_MAX_RETRIES must be an integer",
+		"BC_MAX_PAGE_SIZE must not be negative",
+		"business_central.client_id is required",
+		"business_central.base_path is required",
+		"profiles.other: base_path or tenant_id is required",
+		"server.transport must be stdio or http",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	tests := map[string]struct {
+		name, content, want string
+	}{
+		"unknown setting":   {"config.yaml", "business_central:\n  client_idd: id\n", "client_idd"},
+		"missing variable":  {"config.yaml", "business_central:\n  client_secret: ${TEST_BC_UNSET}\n", "TEST_BC_UNSET"},
+		"unknown extension": {"config.ini", "", "unsupported configuration file extension"},
+		"invalid toml":      {"config.toml", "[business_central]\nbase_path =\n", "business_central.base_path"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.name, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
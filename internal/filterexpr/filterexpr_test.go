@@ -0,0 +1,162 @@
+package filterexpr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+const testMetadata = `<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EnumType Name="Status">
+        <Member Name="Open" Value="0" />
+        <Member Name="Released" Value="1" />
+      </EnumType>
+      <ComplexType Name="Address">
+        <Property Name="City" Type="Edm.String" />
+      </ComplexType>
+      <EntityType Name="Customer">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+        <Property Name="Address" Type="NAV.Address" />
+      </EntityType>
+      <EntityType Name="Line">
+        <Key><PropertyRef Name="Line_No" /></Key>
+        <Property Name="Line_No" Type="Edm.Int32" Nullable="false" />
+        <Property Name="Quantity" Type="Edm.Decimal" />
+      </EntityType>
+      <EntityType Name="Invoice">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+        <Property Name="Posting_Date" Type="Edm.Date" />
+        <Property Name="Amount" Type="Edm.Decimal" />
+        <Property Name="Lines_Count" Type="Edm.Int32" />
+        <Property Name="Id" Type="Edm.Guid" />
+        <Property Name="Status" Type="NAV.Status" />
+        <Property Name="Closed" Type="Edm.Boolean" />
+        <NavigationProperty Name="Customer" Type="NAV.Customer" />
+        <NavigationProperty Name="Lines" Type="Collection(NAV.Line)" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="Invoices" EntityType="NAV.Invoice" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func loadInvoice(t *testing.T) (*metadata.Schema, *metadata.EntityType) {
+	t.Helper()
+	schema, err := metadata.Parse(strings.NewReader(testMetadata))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	et, err := schema.EntityTypeOf("Invoices")
+	if err != nil {
+		t.Fatalf("EntityTypeOf() error = %v", err)
+	}
+	return schema, et
+}
+
+func TestValidate_Valid(t *testing.T) {
+	schema, et := loadInvoice(t)
+
+	valid := []string{
+		"No eq '10000'",
+		"No eq 'O''Brien' and Amount gt 100.5",
+		"Posting_Date ge 2024-01-01 and Posting_Date lt 2024-02-01",
+		"Id eq 6f9619ff-8b86-d011-b42d-00c04fc964ff",
+		"Status eq 'Open' or Status eq NAV.Status'Released'",
+		"not (Closed eq true)",
+		"Closed",
+		"contains(No,'INV') and startswith(Customer/No,'C')",
+		"Customer/Address/City eq 'Milano'",
+		"Lines/any(l: l/Quantity gt 0)",
+		"Lines/all(l: l/Line_No ge 10000)",
+		"Lines/any()",
+		"Amount add 10 gt Lines_Count mul 2",
+		"year(Posting_Date) eq 2024",
+		"Status in ('Open', 'Released')",
+		"Amount ne null",
+		"Amount gt -5",
+	}
+	for _, expr := range valid {
+		if err := Validate(schema, et, expr); err != nil {
+			t.Errorf("Validate(%q) error = %v", expr, err)
+		}
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	schema, et := loadInvoice(t)
+
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"Amout gt 100", "Did you mean: Amount"},
+		{"no eq '1'", "use 'No' instead of 'no'"},
+		{"Amount gt '100'", "without quotes: 100"},
+		{"No eq 10000", "quoted string: '10000'"},
+		{"Posting_Date ge '2024-01-01'", "a date without quotes: 2024-01-01"},
+		{"Id eq 'abc'", "a GUID"},
+		{"No = '1'", "use 'eq'"},
+		{"No eq \"1\"", "single quotes"},
+		{"No eq 'O'Brien'", "must be doubled"},
+		{"No eq '1' AND Amount gt 1", "use 'and' instead of 'AND'"},
+		{"No equals '1'", "Did you mean 'eq'"},
+		{"contains(Amount,'1')", "must be a string"},
+		{"contans(No,'1')", "Did you mean: contains"},
+		{"Lines/Quantity gt 0", "is a collection"},
+		{"Customer/Foo eq 'x'", "does not exist on 'NAV.Customer'"},
+		{"Amount", "not a condition"},
+		{"No eq '1' and", "unexpected end of expression"},
+		{"(No eq '1'", "expected ')'"},
+		{"Amount and Closed", "must be conditions"},
+		{"", "empty expression"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			err := Validate(schema, et, tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate(%q) error = %v, want error containing %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestError_Position(t *testing.T) {
+	schema, et := loadInvoice(t)
+
+	err := Validate(schema, et, "No eq '1' and Amout gt 1")
+	filterErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Validate() error type = %T, want *Error", err)
+	}
+	if filterErr.Pos != 14 {
+		t.Errorf("Pos = %d, want 14", filterErr.Pos)
+	}
+	if !strings.HasPrefix(err.Error(), "invalid $filter at position 15:") {
+		t.Errorf("Error() = %v", err)
+	}
+}
+
+func TestParse_Tree(t *testing.T) {
+	node, err := Parse("a eq 1 or b eq 2 and not c")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	or, ok := node.(*BinaryExpr)
+	if !ok || or.Op != "or" {
+		t.Fatalf("root = %#v, want or", node)
+	}
+	and, ok := or.Right.(*BinaryExpr)
+	if !ok || and.Op != "and" {
+		t.Fatalf("right = %#v, want and (and binds tighter than or)", or.Right)
+	}
+	if _, ok := and.Right.(*NotExpr); !ok {
+		t.Errorf("and.Right = %#v, want not", and.Right)
+	}
+}
@@ -0,0 +1,220 @@
+// Package filterexpr parses OData $filter expressions and validates them
+// against the $metadata of an entity set, so mistakes are reported before a
+// request reaches Business Central.
+package filterexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tokenKind classifies a lexical token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokInt
+	tokDecimal
+	tokGUID
+	tokDate
+	tokDateTime
+	tokTime
+	tokDuration
+	tokEnum
+	tokLParen
+	tokRParen
+	tokComma
+	tokSlash
+	tokColon
+)
+
+// token is a lexical token with its byte offset in the expression
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// Error is a parse or validation error at a position of the expression
+type Error struct {
+	Pos     int // byte offset in the expression
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid $filter at position %d: %s", e.Pos+1, e.Message)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	reGUID     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	reDateTime = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2})`)
+	reDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	reTime     = regexp.MustCompile(`^\d{2}:\d{2}(:\d{2}(\.\d+)?)?`)
+	reNumber   = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?`)
+	reIdent    = regexp.MustCompile(`^[$A-Za-z_][A-Za-z0-9_.]*`)
+)
+
+// symbolHints maps operators from other languages to their OData equivalent
+var symbolHints = map[string]string{
+	"==": "eq", "!=": "ne", "<>": "ne", ">=": "ge", "<=": "le",
+	"&&": "and", "||": "or",
+	"=": "eq", ">": "gt", "<": "lt", "!": "not",
+}
+
+// lex splits a $filter expression into tokens
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(input) {
+		c := input[pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			pos++
+			continue
+		}
+		rest := input[pos:]
+
+		switch c {
+		case '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			pos++
+			continue
+		case ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			pos++
+			continue
+		case ',':
+			tokens = append(tokens, token{tokComma, ",", pos})
+			pos++
+			continue
+		case '/':
+			tokens = append(tokens, token{tokSlash, "/", pos})
+			pos++
+			continue
+		case ':':
+			tokens = append(tokens, token{tokColon, ":", pos})
+			pos++
+			continue
+		case '\'':
+			text, end, err := lexString(input, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokString, text, pos})
+			pos = end
+			continue
+		case '"':
+			return nil, errorf(pos, "string literals use single quotes, e.g. 'Contoso'")
+		}
+
+		for _, sym := range []string{"==", "!=", "<>", ">=", "<=", "&&", "||", "=", ">", "<", "!"} {
+			if strings.HasPrefix(rest, sym) {
+				return nil, errorf(pos, "'%s' is not an OData operator; use '%s'", sym, symbolHints[sym])
+			}
+		}
+
+		// Typed literals are tried longest first: a GUID or a date must not be read as a number
+		if m := reGUID.FindString(rest); m != "" && !isIdentChar(rest, len(m)) {
+			tokens = append(tokens, token{tokGUID, m, pos})
+			pos += len(m)
+			continue
+		}
+		if c == '-' || isDigit(c) {
+			kind, m := tokDecimal, ""
+			switch {
+			case reDateTime.MatchString(rest):
+				kind, m = tokDateTime, reDateTime.FindString(rest)
+			case reDate.MatchString(rest):
+				kind, m = tokDate, reDate.FindString(rest)
+			case reTime.MatchString(rest) && len(rest) > 2 && rest[2] == ':':
+				kind, m = tokTime, reTime.FindString(rest)
+			default:
+				m = reNumber.FindString(rest)
+				if m == "" {
+					return nil, errorf(pos, "unexpected '%c'; use the 'sub' operator for subtraction", c)
+				}
+				if !strings.ContainsAny(m, ".eE") {
+					kind = tokInt
+				}
+			}
+			if isIdentChar(rest, len(m)) {
+				return nil, errorf(pos, "invalid literal '%s'", readWord(rest))
+			}
+			tokens = append(tokens, token{kind, m, pos})
+			pos += len(m)
+			continue
+		}
+
+		if m := reIdent.FindString(rest); m != "" {
+			// A name directly followed by a quoted string is a typed literal:
+			// duration'P1D' or an enum member such as NAV.Status'Open'
+			if len(rest) > len(m) && rest[len(m)] == '\'' {
+				text, end, err := lexString(input, pos+len(m))
+				if err != nil {
+					return nil, err
+				}
+				kind := tokEnum
+				if strings.EqualFold(m, "duration") {
+					kind = tokDuration
+				}
+				tokens = append(tokens, token{kind, m + "'" + text + "'", pos})
+				pos = end
+				continue
+			}
+			tokens = append(tokens, token{tokIdent, m, pos})
+			pos += len(m)
+			continue
+		}
+
+		return nil, errorf(pos, "unexpected character '%c'", c)
+	}
+	tokens = append(tokens, token{tokEOF, "", len(input)})
+	return tokens, nil
+}
+
+// lexString reads a single-quoted string starting at start, where doubled
+// quotes escape a quote. It returns the unescaped text and the end offset.
+func lexString(input string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(input); i++ {
+		if input[i] != '\'' {
+			b.WriteByte(input[i])
+			continue
+		}
+		if i+1 < len(input) && input[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, errorf(start, "unterminated string literal; apostrophes inside a string must be doubled, e.g. 'O''Brien'")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentChar reports whether s has an identifier character at offset i
+func isIdentChar(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return isDigit(c) || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// readWord returns the run of non-separator characters at the start of s
+func readWord(s string) string {
+	end := strings.IndexAny(s, " \t\r\n(),")
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
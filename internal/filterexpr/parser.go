@@ -0,0 +1,349 @@
+package filterexpr
+
+import (
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+// Node is a node of a parsed $filter expression
+type Node interface {
+	// Pos returns the byte offset of the node in the expression
+	Pos() int
+}
+
+// BinaryExpr is a logical, comparison or arithmetic operation
+type BinaryExpr struct {
+	Op    string
+	Left  Node
+	Right Node
+	pos   int
+}
+
+// NotExpr negates a boolean expression
+type NotExpr struct {
+	Operand Node
+	pos     int
+}
+
+// InExpr tests a value for membership in a list
+type InExpr struct {
+	Value Node
+	List  []Node
+	pos   int
+}
+
+// MemberExpr is a property path such as Customer/City
+type MemberExpr struct {
+	Path []string
+	pos  int
+}
+
+// Literal is a constant value
+type Literal struct {
+	Kind  string // string, int, decimal, bool, null, guid, date, datetime, time, duration, enum
+	Value string
+	pos   int
+}
+
+// CallExpr is a function call such as contains(Name,'x')
+type CallExpr struct {
+	Name string
+	Args []Node
+	pos  int
+}
+
+// LambdaExpr is an any/all expression over a collection navigation property
+type LambdaExpr struct {
+	Collection *MemberExpr
+	Op         string // any or all
+	Variable   string
+	Body       Node // nil for the parameterless any()
+	pos        int
+}
+
+func (n *BinaryExpr) Pos() int { return n.pos }
+func (n *NotExpr) Pos() int    { return n.pos }
+func (n *InExpr) Pos() int     { return n.pos }
+func (n *MemberExpr) Pos() int { return n.pos }
+func (n *Literal) Pos() int    { return n.pos }
+func (n *CallExpr) Pos() int   { return n.pos }
+func (n *LambdaExpr) Pos() int { return n.pos }
+
+var (
+	comparisonOps = map[string]bool{"eq": true, "ne": true, "gt": true, "ge": true, "lt": true, "le": true, "has": true}
+	additiveOps   = map[string]bool{"add": true, "sub": true}
+	multiplyOps   = map[string]bool{"mul": true, "div": true, "divby": true, "mod": true}
+)
+
+// operatorKeywords are the binary operators, used to suggest corrections
+var operatorKeywords = []string{"and", "or", "eq", "ne", "gt", "ge", "lt", "le", "has", "in", "add", "sub", "mul", "div", "mod"}
+
+// literalKinds maps literal tokens to literal kinds
+var literalKinds = map[tokenKind]string{
+	tokString:   "string",
+	tokInt:      "int",
+	tokDecimal:  "decimal",
+	tokGUID:     "guid",
+	tokDate:     "date",
+	tokDateTime: "datetime",
+	tokTime:     "time",
+	tokDuration: "duration",
+	tokEnum:     "enum",
+}
+
+// Parse parses a $filter expression into a syntax tree
+func Parse(expr string) (Node, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, errorf(0, "empty expression")
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.unexpected(tok, "an operator such as and, or, eq")
+	}
+	return node, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword consumes the identifier token if it is one of the given keywords
+func (p *parser) keyword(keywords map[string]bool) (token, bool) {
+	tok := p.peek()
+	if tok.kind == tokIdent && keywords[tok.text] {
+		p.pos++
+		return tok, true
+	}
+	return tok, false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.peek()
+	if tok.kind != kind {
+		return tok, p.unexpected(tok, what)
+	}
+	return p.next(), nil
+}
+
+// unexpected describes an unexpected token, hinting at the intended keyword
+func (p *parser) unexpected(tok token, expected string) error {
+	if tok.kind == tokEOF {
+		return errorf(tok.pos, "unexpected end of expression, expected %s", expected)
+	}
+	if tok.kind == tokIdent {
+		lower := strings.ToLower(tok.text)
+		for _, kw := range operatorKeywords {
+			if lower == kw && tok.text != kw {
+				return errorf(tok.pos, "operators are lowercase: use '%s' instead of '%s'", kw, tok.text)
+			}
+		}
+		if suggestions := metadata.Suggest(tok.text, operatorKeywords, 1); len(suggestions) > 0 && len(tok.text) > 1 {
+			return errorf(tok.pos, "unexpected '%s', expected %s. Did you mean '%s'?", tok.text, expected, suggestions[0])
+		}
+	}
+	return errorf(tok.pos, "unexpected '%s', expected %s", tok.text, expected)
+}
+
+func (p *parser) parseOr() (Node, error) {
+	return p.parseBinary(map[string]bool{"or": true}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	return p.parseBinary(map[string]bool{"and": true}, p.parseNot)
+}
+
+func (p *parser) parseAdditive() (Node, error) {
+	return p.parseBinary(additiveOps, p.parseMultiplicative)
+}
+
+func (p *parser) parseMultiplicative() (Node, error) {
+	return p.parseBinary(multiplyOps, p.parsePrimary)
+}
+
+// parseBinary parses a left-associative chain of operators of the same precedence
+func (p *parser) parseBinary(ops map[string]bool, operand func() (Node, error)) (Node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.keyword(ops)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryExpr{Op: op.text, Left: left, Right: right, pos: op.pos}
+	}
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if tok, ok := p.keyword(map[string]bool{"not": true}); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Operand: operand, pos: tok.pos}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if op, ok := p.keyword(comparisonOps); ok {
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpr{Op: op.text, Left: left, Right: right, pos: op.pos}, nil
+	}
+
+	if op, ok := p.keyword(map[string]bool{"in": true}); ok {
+		if _, err := p.expect(tokLParen, "'(' starting the list of values"); err != nil {
+			return nil, err
+		}
+		list, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{Value: left, List: list, pos: op.pos}, nil
+	}
+	return left, nil
+}
+
+// parseArgs parses a comma separated list after '(' up to and including ')'
+func (p *parser) parseArgs() ([]Node, error) {
+	var args []Node
+	if p.peek().kind == tokRParen {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		tok := p.next()
+		switch tok.kind {
+		case tokComma:
+			continue
+		case tokRParen:
+			return args, nil
+		default:
+			return nil, p.unexpected(tok, "',' or ')'")
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokIdent:
+		switch tok.text {
+		case "true", "false":
+			return &Literal{Kind: "bool", Value: tok.text, pos: tok.pos}, nil
+		case "null":
+			return &Literal{Kind: "null", Value: tok.text, pos: tok.pos}, nil
+		}
+		if p.peek().kind == tokLParen {
+			p.next()
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return &CallExpr{Name: tok.text, Args: args, pos: tok.pos}, nil
+		}
+		return p.parseMember(tok)
+	default:
+		if kind, ok := literalKinds[tok.kind]; ok {
+			return &Literal{Kind: kind, Value: tok.text, pos: tok.pos}, nil
+		}
+		return nil, p.unexpected(tok, "a field, literal or function")
+	}
+}
+
+// parseMember parses a property path, ending in an any/all lambda when present
+func (p *parser) parseMember(first token) (Node, error) {
+	member := &MemberExpr{Path: []string{first.text}, pos: first.pos}
+	for p.peek().kind == tokSlash {
+		p.next()
+		seg, err := p.expect(tokIdent, "a property name after '/'")
+		if err != nil {
+			return nil, err
+		}
+		if (seg.text == "any" || seg.text == "all") && p.peek().kind == tokLParen {
+			p.next()
+			return p.parseLambda(member, seg)
+		}
+		member.Path = append(member.Path, seg.text)
+	}
+	return member, nil
+}
+
+// parseLambda parses the body of any(v: ...) or all(v: ...)
+func (p *parser) parseLambda(collection *MemberExpr, op token) (Node, error) {
+	lambda := &LambdaExpr{Collection: collection, Op: op.text, pos: op.pos}
+	if p.peek().kind == tokRParen {
+		p.next()
+		if op.text == "all" {
+			return nil, errorf(op.pos, "all() requires a lambda expression, e.g. all(l: l/Quantity gt 0)")
+		}
+		return lambda, nil
+	}
+
+	variable, err := p.expect(tokIdent, "a lambda variable, e.g. any(l: l/Quantity gt 0)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokColon, "':' after the lambda variable"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen, "')'"); err != nil {
+		return nil, err
+	}
+	lambda.Variable = variable.text
+	lambda.Body = body
+	return lambda, nil
+}
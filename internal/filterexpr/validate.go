@@ -0,0 +1,477 @@
+package filterexpr
+
+import (
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+// Expression kinds, shared with Literal.Kind. any is used when the type
+// cannot be determined and is compatible with everything.
+const (
+	kindAny      = "any"
+	kindString   = "string"
+	kindInt      = "int"
+	kindDecimal  = "decimal"
+	kindBool     = "bool"
+	kindNull     = "null"
+	kindGUID     = "guid"
+	kindDate     = "date"
+	kindDateTime = "datetime"
+	kindTime     = "time"
+	kindDuration = "duration"
+	kindEnum     = "enum"
+	kindEntity   = "entity"
+	kindComplex  = "complex"
+)
+
+// edmKinds maps EDM primitive types to expression kinds
+var edmKinds = map[string]string{
+	"Edm.String":         kindString,
+	"Edm.Byte":           kindInt,
+	"Edm.SByte":          kindInt,
+	"Edm.Int16":          kindInt,
+	"Edm.Int32":          kindInt,
+	"Edm.Int64":          kindInt,
+	"Edm.Decimal":        kindDecimal,
+	"Edm.Double":         kindDecimal,
+	"Edm.Single":         kindDecimal,
+	"Edm.Boolean":        kindBool,
+	"Edm.Guid":           kindGUID,
+	"Edm.Date":           kindDate,
+	"Edm.DateTimeOffset": kindDateTime,
+	"Edm.TimeOfDay":      kindTime,
+	"Edm.Duration":       kindDuration,
+}
+
+// function describes the signature of a built-in filter function
+type function struct {
+	args    []string // expected argument kinds; kindAny accepts anything
+	returns string
+}
+
+// functions are the canonical OData functions supported by Business Central
+var functions = map[string]function{
+	"contains":   {[]string{kindString, kindString}, kindBool},
+	"startswith": {[]string{kindString, kindString}, kindBool},
+	"endswith":   {[]string{kindString, kindString}, kindBool},
+	"length":     {[]string{kindString}, kindInt},
+	"indexof":    {[]string{kindString, kindString}, kindInt},
+	"substring":  {[]string{kindString, kindInt, kindInt}, kindString},
+	"tolower":    {[]string{kindString}, kindString},
+	"toupper":    {[]string{kindString}, kindString},
+	"trim":       {[]string{kindString}, kindString},
+	"concat":     {[]string{kindString, kindString}, kindString},
+	"year":       {[]string{kindAny}, kindInt},
+	"month":      {[]string{kindAny}, kindInt},
+	"day":        {[]string{kindAny}, kindInt},
+	"hour":       {[]string{kindAny}, kindInt},
+	"minute":     {[]string{kindAny}, kindInt},
+	"second":     {[]string{kindAny}, kindInt},
+	"date":       {[]string{kindDateTime}, kindDate},
+	"time":       {[]string{kindDateTime}, kindTime},
+	"now":        {nil, kindDateTime},
+	"round":      {[]string{kindDecimal}, kindDecimal},
+	"floor":      {[]string{kindDecimal}, kindDecimal},
+	"ceiling":    {[]string{kindDecimal}, kindDecimal},
+}
+
+// Validate parses expr and checks it against the entity type: every field
+// must exist, every comparison must use a literal of the field's type and the
+// whole expression must be boolean
+func Validate(schema *metadata.Schema, et *metadata.EntityType, expr string) error {
+	node, err := Parse(expr)
+	if err != nil {
+		return err
+	}
+	v := &validator{schema: schema, vars: map[string]*metadata.EntityType{}}
+	result, err := v.check(node, et)
+	if err != nil {
+		return err
+	}
+	if result.kind != kindBool && result.kind != kindAny {
+		return errorf(node.Pos(), "expression is not a condition; compare the value with eq, ne, gt, ge, lt or le")
+	}
+	return nil
+}
+
+// exprType is the inferred type of an expression
+type exprType struct {
+	kind string
+	edm  string // EDM type when the expression is a property
+	name string // property path when the expression is a property
+}
+
+type validator struct {
+	schema *metadata.Schema
+	vars   map[string]*metadata.EntityType // lambda variables in scope
+}
+
+func (v *validator) check(node Node, et *metadata.EntityType) (exprType, error) {
+	switch n := node.(type) {
+	case *Literal:
+		return exprType{kind: n.Kind}, nil
+	case *MemberExpr:
+		return v.resolveMember(n, et)
+	case *NotExpr:
+		operand, err := v.check(n.Operand, et)
+		if err != nil {
+			return exprType{}, err
+		}
+		if !isKind(operand, kindBool) {
+			return exprType{}, errorf(n.pos, "'not' requires a condition, got %s", describe(operand))
+		}
+		return exprType{kind: kindBool}, nil
+	case *BinaryExpr:
+		return v.checkBinary(n, et)
+	case *InExpr:
+		value, err := v.check(n.Value, et)
+		if err != nil {
+			return exprType{}, err
+		}
+		if len(n.List) == 0 {
+			return exprType{}, errorf(n.pos, "'in' requires at least one value")
+		}
+		for _, item := range n.List {
+			itemType, err := v.check(item, et)
+			if err != nil {
+				return exprType{}, err
+			}
+			if err := compatible(value, itemType, item); err != nil {
+				return exprType{}, err
+			}
+		}
+		return exprType{kind: kindBool}, nil
+	case *CallExpr:
+		return v.checkCall(n, et)
+	case *LambdaExpr:
+		return v.checkLambda(n, et)
+	}
+	return exprType{kind: kindAny}, nil
+}
+
+func (v *validator) checkBinary(n *BinaryExpr, et *metadata.EntityType) (exprType, error) {
+	left, err := v.check(n.Left, et)
+	if err != nil {
+		return exprType{}, err
+	}
+	right, err := v.check(n.Right, et)
+	if err != nil {
+		return exprType{}, err
+	}
+
+	switch {
+	case n.Op == "and" || n.Op == "or":
+		for _, side := range []struct {
+			t    exprType
+			node Node
+		}{{left, n.Left}, {right, n.Right}} {
+			if !isKind(side.t, kindBool) {
+				return exprType{}, errorf(side.node.Pos(), "operands of '%s' must be conditions, got %s", n.Op, describe(side.t))
+			}
+		}
+		return exprType{kind: kindBool}, nil
+	case n.Op == "has":
+		if !isKind(left, kindEnum) {
+			return exprType{}, errorf(n.pos, "'has' requires an enum field, got %s", describe(left))
+		}
+		return exprType{kind: kindBool}, nil
+	case comparisonOps[n.Op]:
+		// Report the literal, not the field, when a field is compared with a literal of the wrong type
+		if _, ok := n.Left.(*Literal); ok {
+			if err := compatible(right, left, n.Left); err != nil {
+				return exprType{}, err
+			}
+		} else if err := compatible(left, right, n.Right); err != nil {
+			return exprType{}, err
+		}
+		if n.Op != "eq" && n.Op != "ne" && left.kind == kindBool {
+			return exprType{}, errorf(n.pos, "'%s' cannot be applied to %s", n.Op, describe(left))
+		}
+		return exprType{kind: kindBool}, nil
+	default:
+		// Arithmetic: numbers, or dates and durations
+		for _, side := range []struct {
+			t    exprType
+			node Node
+		}{{left, n.Left}, {right, n.Right}} {
+			switch side.t.kind {
+			case kindAny, kindInt, kindDecimal, kindDate, kindDateTime, kindDuration:
+			default:
+				return exprType{}, errorf(side.node.Pos(), "'%s' requires numeric operands, got %s", n.Op, describe(side.t))
+			}
+		}
+		if left.kind == kindInt && right.kind == kindInt && n.Op != "divby" {
+			return exprType{kind: kindInt}, nil
+		}
+		if isNumeric(left.kind) && isNumeric(right.kind) {
+			return exprType{kind: kindDecimal}, nil
+		}
+		return exprType{kind: kindAny}, nil
+	}
+}
+
+func (v *validator) checkCall(n *CallExpr, et *metadata.EntityType) (exprType, error) {
+	fn, ok := functions[n.Name]
+	if !ok {
+		names := make([]string, 0, len(functions))
+		for name := range functions {
+			names = append(names, name)
+		}
+		if suggestions := metadata.Suggest(n.Name, names, 3); len(suggestions) > 0 {
+			return exprType{}, errorf(n.pos, "unknown function '%s'. Did you mean: %s?", n.Name, strings.Join(suggestions, ", "))
+		}
+		return exprType{}, errorf(n.pos, "unknown function '%s'", n.Name)
+	}
+	if len(n.Args) != len(fn.args) && !(n.Name == "substring" && len(n.Args) == 2) {
+		return exprType{}, errorf(n.pos, "%s expects %d argument(s), got %d", n.Name, len(fn.args), len(n.Args))
+	}
+
+	for i, arg := range n.Args {
+		argType, err := v.check(arg, et)
+		if err != nil {
+			return exprType{}, err
+		}
+		want := fn.args[i]
+		if want == kindAny || argType.kind == kindAny || argType.kind == kindNull || argType.kind == want ||
+			(want == kindDecimal && isNumeric(argType.kind)) ||
+			(want == kindDateTime && argType.kind == kindDate) ||
+			(want == kindString && argType.kind == kindEnum) {
+			continue
+		}
+		return exprType{}, errorf(arg.Pos(), "argument %d of %s must be %s, got %s", i+1, n.Name, kindNames[want], describe(argType))
+	}
+	return exprType{kind: fn.returns}, nil
+}
+
+func (v *validator) checkLambda(n *LambdaExpr, et *metadata.EntityType) (exprType, error) {
+	target, err := v.resolveCollection(n.Collection, et)
+	if err != nil {
+		return exprType{}, err
+	}
+	if n.Body == nil {
+		return exprType{kind: kindBool}, nil
+	}
+	if _, clash := v.vars[n.Variable]; clash {
+		return exprType{}, errorf(n.pos, "lambda variable '%s' is already in use", n.Variable)
+	}
+	v.vars[n.Variable] = target
+	defer delete(v.vars, n.Variable)
+
+	body, err := v.check(n.Body, et)
+	if err != nil {
+		return exprType{}, err
+	}
+	if !isKind(body, kindBool) {
+		return exprType{}, errorf(n.Body.Pos(), "%s() requires a condition, got %s", n.Op, describe(body))
+	}
+	return exprType{kind: kindBool}, nil
+}
+
+// resolveMember resolves a property path to the type of its last segment
+func (v *validator) resolveMember(n *MemberExpr, et *metadata.EntityType) (exprType, error) {
+	path := n.Path
+	if target, ok := v.vars[path[0]]; ok {
+		if len(path) == 1 {
+			return exprType{kind: kindEntity, name: path[0]}, nil
+		}
+		et, path = target, path[1:]
+	}
+
+	var properties []*metadata.Property
+	owner := et.QualifiedName
+	structural := et
+	for i, segment := range path {
+		last := i == len(path)-1
+
+		if structural != nil {
+			if nav, ok := structural.NavigationProperty(segment); ok {
+				if nav.IsCollection() {
+					return exprType{}, errorf(n.pos, "'%s' is a collection; use %s/any(x: ...) to filter on its items", nav.Name, strings.Join(n.Path[:i+1], "/"))
+				}
+				target, ok := v.schema.EntityType(nav.TargetType())
+				if !ok {
+					return exprType{kind: kindAny}, nil
+				}
+				if last {
+					return exprType{kind: kindEntity, name: strings.Join(n.Path, "/")}, nil
+				}
+				structural, owner = target, target.QualifiedName
+				continue
+			}
+			properties = structural.Properties
+		}
+
+		prop := findProperty(properties, segment)
+		if prop == nil {
+			return exprType{}, unknownField(n, segment, owner, properties, structural)
+		}
+		if last {
+			return v.propertyType(prop, strings.Join(n.Path, "/")), nil
+		}
+
+		complexType, ok := v.schema.ComplexType(prop.Type)
+		if !ok {
+			return exprType{}, errorf(n.pos, "'%s' is %s and has no properties", prop.Name, prop.Type)
+		}
+		structural, properties, owner = nil, complexType.Properties, complexType.QualifiedName
+	}
+	return exprType{kind: kindAny}, nil
+}
+
+// resolveCollection resolves the collection navigation property of a lambda
+func (v *validator) resolveCollection(n *MemberExpr, et *metadata.EntityType) (*metadata.EntityType, error) {
+	path := n.Path
+	if target, ok := v.vars[path[0]]; ok {
+		et, path = target, path[1:]
+	}
+	for i, segment := range path {
+		nav, ok := et.NavigationProperty(segment)
+		if !ok {
+			var names []string
+			for _, np := range et.NavigationProperties {
+				names = append(names, np.Name)
+			}
+			if suggestions := metadata.Suggest(segment, names, 3); len(suggestions) > 0 {
+				return nil, errorf(n.pos, "'%s' is not a navigation property of '%s'. Did you mean: %s?", segment, et.QualifiedName, strings.Join(suggestions, ", "))
+			}
+			return nil, errorf(n.pos, "'%s' is not a navigation property of '%s'", segment, et.QualifiedName)
+		}
+		last := i == len(path)-1
+		if last && !nav.IsCollection() {
+			return nil, errorf(n.pos, "any/all require a collection, but '%s' is a single entity", nav.Name)
+		}
+		target, ok := v.schema.EntityType(nav.TargetType())
+		if !ok {
+			return nil, errorf(n.pos, "target type '%s' of '%s' not found in metadata", nav.TargetType(), nav.Name)
+		}
+		et = target
+	}
+	return et, nil
+}
+
+// propertyType maps a property to its expression type
+func (v *validator) propertyType(prop *metadata.Property, name string) exprType {
+	t := exprType{edm: prop.Type, name: name}
+	if kind, ok := edmKinds[prop.Type]; ok {
+		t.kind = kind
+	} else if _, ok := v.schema.EnumType(prop.Type); ok {
+		t.kind = kindEnum
+	} else if _, ok := v.schema.ComplexType(prop.Type); ok {
+		t.kind = kindComplex
+	} else {
+		t.kind = kindAny
+	}
+	return t
+}
+
+// findProperty looks up a property by exact name
+func findProperty(properties []*metadata.Property, name string) *metadata.Property {
+	for _, p := range properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// unknownField reports a missing property with suggestions. Property names
+// are case-sensitive in OData, so a casing mismatch is reported as such.
+func unknownField(n *MemberExpr, segment, owner string, properties []*metadata.Property, et *metadata.EntityType) error {
+	names := make([]string, 0, len(properties))
+	for _, p := range properties {
+		if strings.EqualFold(p.Name, segment) {
+			return errorf(n.pos, "field names are case-sensitive: use '%s' instead of '%s'", p.Name, segment)
+		}
+		names = append(names, p.Name)
+	}
+	if et != nil {
+		for _, nav := range et.NavigationProperties {
+			names = append(names, nav.Name)
+		}
+	}
+
+	if suggestions := metadata.Suggest(segment, names, 3); len(suggestions) > 0 {
+		return errorf(n.pos, "field '%s' does not exist on '%s'. Did you mean: %s?", segment, owner, strings.Join(suggestions, ", "))
+	}
+	return errorf(n.pos, "field '%s' does not exist on '%s'", segment, owner)
+}
+
+// compatible checks that value can be compared with target. node is the
+// value expression, used to position the error and quote the literal.
+func compatible(target, value exprType, node Node) error {
+	if target.kind == kindAny || value.kind == kindAny || target.kind == kindNull || value.kind == kindNull {
+		return nil
+	}
+	if target.kind == value.kind || (isNumeric(target.kind) && isNumeric(value.kind)) {
+		return nil
+	}
+	switch {
+	case target.kind == kindEnum && (value.kind == kindString || value.kind == kindInt):
+		// Business Central accepts enum members as quoted names
+		return nil
+	case value.kind == kindEnum && target.kind == kindString:
+		return nil
+	case (target.kind == kindDate || target.kind == kindDateTime) && (value.kind == kindDate || value.kind == kindDateTime):
+		return nil
+	}
+
+	subject := describe(target)
+	literal, isLiteral := node.(*Literal)
+	if !isLiteral {
+		return errorf(node.Pos(), "cannot compare %s with %s", subject, describe(value))
+	}
+
+	switch {
+	case target.kind == kindString && value.kind != kindString:
+		return errorf(node.Pos(), "%s must be compared with a quoted string: '%s'", subject, literal.Value)
+	case value.kind == kindString && isQuotable(target.kind):
+		return errorf(node.Pos(), "%s must be compared with %s without quotes: %s", subject, kindNames[target.kind], literal.Value)
+	}
+	return errorf(node.Pos(), "cannot compare %s with %s literal %s", subject, kindNames[value.kind], literal.Value)
+}
+
+// isQuotable reports kinds whose literals are unquoted in OData but are often written quoted
+func isQuotable(kind string) bool {
+	switch kind {
+	case kindInt, kindDecimal, kindBool, kindGUID, kindDate, kindDateTime, kindTime:
+		return true
+	}
+	return false
+}
+
+var kindNames = map[string]string{
+	kindAny:      "a value",
+	kindString:   "a string",
+	kindInt:      "an integer",
+	kindDecimal:  "a number",
+	kindBool:     "a boolean",
+	kindNull:     "null",
+	kindGUID:     "a GUID",
+	kindDate:     "a date",
+	kindDateTime: "a date-time",
+	kindTime:     "a time of day",
+	kindDuration: "a duration",
+	kindEnum:     "an enum",
+	kindEntity:   "an entity",
+	kindComplex:  "a complex value",
+}
+
+// describe names an expression type for error messages
+func describe(t exprType) string {
+	if t.name != "" && t.edm != "" {
+		return "field '" + t.name + "' (" + t.edm + ")"
+	}
+	if t.name != "" {
+		return "'" + t.name + "'"
+	}
+	return kindNames[t.kind]
+}
+
+func isKind(t exprType, kind string) bool {
+	return t.kind == kind || t.kind == kindAny
+}
+
+func isNumeric(kind string) bool {
+	return kind == kindInt || kind == kindDecimal
+}
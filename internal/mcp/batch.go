@@ -0,0 +1,339 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+// operationRead is the batch operation that reads an entity or a collection
+const operationRead = "read"
+
+// batchMethods maps the operations of bc_odata_batch to their HTTP method
+var batchMethods = map[string]string{
+	operationRead:   http.MethodGet,
+	operationCreate: http.MethodPost,
+	operationUpdate: http.MethodPatch,
+	operationDelete: http.MethodDelete,
+}
+
+// atomicChangeSet names the change set of the write operations of an atomic batch
+const atomicChangeSet = "atomic"
+
+// batchOperation is one entry of the operations argument of bc_odata_batch
+type batchOperation struct {
+	id        string
+	operation string
+	endpoint  string // entity set, or $<id>/... to address the result of another operation
+	key       interface{}
+	data      map[string]interface{}
+	etag      string
+	changeSet string
+	dependsOn []string
+}
+
+// parseBatchOperations validates the operations argument of bc_odata_batch.
+// With atomic, write operations outside a change set share one.
+func parseBatchOperations(arg interface{}, atomic bool) ([]batchOperation, error) {
+	items, ok := arg.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("operations is required and must be a non-empty array")
+	}
+	if len(items) > bc.MaxBatchRequests {
+		return nil, fmt.Errorf("%d operations, a batch holds at most %d", len(items), bc.MaxBatchRequests)
+	}
+
+	operations := make([]batchOperation, 0, len(items))
+	known := make(map[string]bool, len(items)) // ids and change sets seen so far
+	for i, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation %d must be an object", i+1)
+		}
+		op := batchOperation{id: strconv.Itoa(i + 1), key: fields["key"]}
+		if id, ok := fields["id"].(string); ok && id != "" {
+			op.id = id
+		}
+		if known[op.id] {
+			return nil, fmt.Errorf("operation %d: id '%s' is used twice", i+1, op.id)
+		}
+		op.operation, _ = fields["operation"].(string)
+		op.operation = strings.ToLower(op.operation)
+		if _, ok := batchMethods[op.operation]; !ok {
+			return nil, fmt.Errorf("operation %d ('%s'): operation must be read, create, update or delete", i+1, op.id)
+		}
+		op.endpoint, _ = fields["endpoint"].(string)
+		if op.endpoint == "" {
+			return nil, fmt.Errorf("operation %d ('%s'): endpoint is required", i+1, op.id)
+		}
+		if data, ok := fields["data"].(map[string]interface{}); ok {
+			op.data = data
+		} else if op.operation == operationCreate || op.operation == operationUpdate {
+			return nil, fmt.Errorf("operation %d ('%s'): data is required and must be an object", i+1, op.id)
+		}
+		// A reference such as $1 already addresses a single entity
+		if op.key == nil && !strings.HasPrefix(op.endpoint, "$") && (op.operation == operationUpdate || op.operation == operationDelete) {
+			return nil, fmt.Errorf("operation %d ('%s'): key is required", i+1, op.id)
+		}
+		op.etag, _ = fields["etag"].(string)
+		op.changeSet, _ = fields["change_set"].(string)
+		if op.changeSet == "" && atomic && op.operation != operationRead {
+			op.changeSet = atomicChangeSet
+		}
+		if dependsOn, ok := fields["depends_on"].([]interface{}); ok {
+			for _, dep := range dependsOn {
+				name, _ := dep.(string)
+				if !known[name] {
+					return nil, fmt.Errorf("operation %d ('%s'): depends_on '%v' must name an earlier operation or change set", i+1, op.id, dep)
+				}
+				op.dependsOn = append(op.dependsOn, name)
+			}
+		}
+
+		known[op.id] = true
+		if op.changeSet != "" {
+			known[op.changeSet] = true
+		}
+		operations = append(operations, op)
+	}
+	return operations, nil
+}
+
+// batchEntitySet returns the entity set a batch operation addresses; for
+// $1/SalesLines it is the navigation property, named like its entity set in
+// Business Central, and for $1 the entity set of operation 1, looked up in
+// resolved. It returns "" when the entity set is unknown.
+func batchEntitySet(endpoint string, resolved map[string]string) string {
+	if strings.HasPrefix(endpoint, "$") {
+		ref, rest, nested := strings.Cut(endpoint[1:], "/")
+		if !nested {
+			return resolved[entitySetOf(ref)]
+		}
+		return entitySetOf(rest)
+	}
+	return entitySetOf(endpoint)
+}
+
+// batchURL renders the URL of a batch operation, with the key typed from
+// $metadata when the operation addresses a single entity
+func (s *Server) batchURL(ctx context.Context, op batchOperation) (string, error) {
+	if op.key == nil || strings.HasPrefix(op.endpoint, "$") {
+		return op.endpoint, nil
+	}
+	return s.keyedEndpoint(ctx, op.endpoint, op.key)
+}
+
+// handleBatch runs several operations in one OData $batch request
+func (s *Server) handleBatch(ctx context.Context, id interface{}, args map[string]interface{}) *JSONRPCResponse {
+	invalid := func(message, data string) *JSONRPCResponse {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: " + message,
+				Data:    data,
+			},
+		}
+	}
+
+	atomic, _ := args["atomic"].(bool)
+	operations, err := parseBatchOperations(args["operations"], atomic)
+	if err != nil {
+		return invalid("invalid operations", err.Error())
+	}
+
+	// Every write is checked against the policy before anything is sent
+	entitySets := make(map[string]string, len(operations)) // by operation id
+	for _, op := range operations {
+		entitySet := batchEntitySet(op.endpoint, entitySets)
+		entitySets[op.id] = entitySet
+		if op.operation == operationRead {
+			continue
+		}
+		if entitySet == "" {
+			return invalid("invalid operations", fmt.Sprintf("operation '%s': the entity set of endpoint '%s' is unknown; a reference such as $1 must name an earlier operation", op.id, op.endpoint))
+		}
+		tool := "bc_odata_" + op.operation
+		if !s.policy.toolAllowed(tool) {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Operation not permitted",
+					Data:    fmt.Sprintf("operation '%s': %s is not permitted by the tool policy", op.id, tool),
+				},
+			}
+		}
+		if err := s.policy.checkWrite(entitySet, op.operation); err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Operation not permitted",
+					Data:    fmt.Sprintf("operation '%s': %s", op.id, err.Error()),
+				},
+			}
+		}
+		confirm := []string{entitySet}
+		if op.operation == operationCreate {
+			nested, err := s.checkDeepInsert(ctx, entitySet, op.data)
+			if err != nil {
+				return &JSONRPCResponse{
+					JSONRPC: "2.0",
+					ID:      id,
+					Error: &JSONRPCError{
+						Code:    -32000,
+						Message: "Operation not permitted",
+						Data:    fmt.Sprintf("operation '%s': %s", op.id, err.Error()),
+					},
+				}
+			}
+			confirm = append(confirm, nested...)
+		}
+		for _, target := range confirm {
+			if s.policy.confirmationRequired(target) {
+				return invalid("confirmation required", fmt.Sprintf("Operation '%s': writes to '%s' must be previewed with dry_run and confirmed, which a batch cannot do; use bc_odata_%s instead", op.id, target, op.operation))
+			}
+		}
+	}
+
+	requests := make([]bc.BatchRequest, len(operations))
+	for i, op := range operations {
+		url, err := s.batchURL(ctx, op)
+		if err != nil {
+			return invalid("invalid key", fmt.Sprintf("operation '%s': %s", op.id, err.Error()))
+		}
+		requests[i] = bc.BatchRequest{
+			ID:             op.id,
+			Method:         batchMethods[op.operation],
+			URL:            url,
+			AtomicityGroup: op.changeSet,
+			DependsOn:      op.dependsOn,
+		}
+		if op.data != nil && op.operation != operationRead && op.operation != operationDelete {
+			requests[i].Body = op.data
+		}
+	}
+
+	client := s.clientFor(ctx)
+	if err := batchETags(ctx, client, operations, requests); err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Batch operation failed",
+				Data:    fmt.Sprintf("Failed to read the current ETags: %s", err.Error()),
+			},
+		}
+	}
+
+	responses, err := client.Batch(ctx, requests)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Batch operation failed",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	results := make([]map[string]interface{}, len(operations))
+	succeeded := 0
+	for i, op := range operations {
+		response := responses[i]
+		result := map[string]interface{}{
+			"id":        op.id,
+			"operation": op.operation,
+			"url":       requests[i].URL,
+			"status":    response.Status,
+			"success":   response.Status >= 200 && response.Status < 300,
+		}
+		var body interface{}
+		if len(response.Body) > 0 && json.Unmarshal(response.Body, &body) == nil {
+			if result["success"] == true {
+				result["result"] = body
+			} else {
+				result["error"] = body
+			}
+		}
+		switch {
+		case response.Status == 0:
+			result["error"] = "not executed: an earlier operation of its change set or one it depends on failed"
+		case response.Status == http.StatusPreconditionFailed:
+			result["conflict"] = true
+		}
+		if result["success"] == true {
+			succeeded++
+		}
+		results[i] = result
+	}
+
+	resultJSON, _ := json.Marshal(map[string]interface{}{
+		"operations": results,
+		"succeeded":  succeeded,
+		"failed":     len(operations) - succeeded,
+	})
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		},
+	}
+}
+
+// batchETags sets If-Match on the updates and deletes of a batch. Operations
+// without an etag get the current one, read for all of them in a single
+// $batch of GETs, so that concurrent changes fail instead of being overwritten.
+func batchETags(ctx context.Context, client *bc.Client, operations []batchOperation, requests []bc.BatchRequest) error {
+	var reads []bc.BatchRequest
+	for i, op := range operations {
+		if op.operation != operationUpdate && op.operation != operationDelete {
+			continue
+		}
+		if op.etag != "" {
+			requests[i].Headers = map[string]string{"If-Match": op.etag}
+			continue
+		}
+		// An entity created in the same batch cannot be read beforehand
+		if !strings.HasPrefix(requests[i].URL, "$") {
+			reads = append(reads, bc.BatchRequest{ID: strconv.Itoa(i), Method: http.MethodGet, URL: requests[i].URL})
+		}
+	}
+	if len(reads) == 0 {
+		return nil
+	}
+
+	responses, err := client.Batch(ctx, reads)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		// A failed read, e.g. 404, is reported by the operation itself
+		i, _ := strconv.Atoi(response.ID)
+		var entity struct {
+			ETag string `json:"@odata.etag"`
+		}
+		if response.Status >= 200 && response.Status < 300 && json.Unmarshal(response.Body, &entity) == nil && entity.ETag != "" {
+			requests[i].Headers = map[string]string{"If-Match": entity.ETag}
+		}
+	}
+	return nil
+}
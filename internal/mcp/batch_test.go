@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchOperations(t *testing.T) {
+	var operations []interface{}
+	_ = json.Unmarshal([]byte(`[
+		{"operation":"create","endpoint":"SalesOrders","data":{"Sell_to_Customer_No":"10000"}},
+		{"operation":"CREATE","endpoint":"$1/SalesLines","data":{"No":"1000"},"depends_on":["1"]},
+		{"id":"check","operation":"read","endpoint":"Customers","key":"10000","depends_on":["atomic"]}
+	]`), &operations)
+	parsed, err := parseBatchOperations(operations, true)
+	if err != nil {
+		t.Fatalf("parseBatchOperations() error = %v", err)
+	}
+	if parsed[1].id != "2" || parsed[1].operation != operationCreate || parsed[1].changeSet != atomicChangeSet || parsed[2].changeSet != "" || parsed[2].id != "check" {
+		t.Errorf("parsed = %+v", parsed)
+	}
+
+	for _, input := range []string{
+		`[]`,
+		`[{"operation":"upsert","endpoint":"Items"}]`,
+		`[{"operation":"create","endpoint":"Items"}]`,
+		`[{"operation":"delete","endpoint":"Items"}]`,
+		`[{"operation":"read","endpoint":"Items","depends_on":["2"]},{"operation":"read","endpoint":"Items"}]`,
+		`[{"id":"a","operation":"read","endpoint":"Items"},{"id":"a","operation":"read","endpoint":"Items"}]`,
+	} {
+		var arg interface{}
+		_ = json.Unmarshal([]byte(input), &arg)
+		if _, err := parseBatchOperations(arg, false); err == nil {
+			t.Errorf("parseBatchOperations(%s) error = nil", input)
+		}
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	var batches []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/$batch" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var body struct {
+			Requests []struct {
+				ID, Method, URL string
+				Headers         map[string]string
+				AtomicityGroup  string
+			}
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		var summary []string
+		for _, req := range body.Requests {
+			summary = append(summary, req.Method+" "+req.URL+" "+req.Headers["If-Match"]+" "+req.AtomicityGroup)
+		}
+		batches = append(batches, strings.Join(summary, "|"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if body.Requests[0].Method == http.MethodGet {
+			// ETag lookup of the delete
+			_, _ = w.Write([]byte(`{"responses":[{"id":"` + body.Requests[0].ID + `","status":200,"body":{"@odata.etag":"W/\"7\"","No":"OLD"}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"responses":[
+			{"id":"1","status":201,"body":{"No":"1001"}},
+			{"id":"2","status":400,"body":{"error":{"code":"BadRequest","message":"Invalid line"}}}
+		]}`))
+	})
+
+	call := func(arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_batch","arguments":`+arguments+`}}`))
+	}
+	text := resultText(t, call(`{"atomic":true,"operations":[
+		{"operation":"create","endpoint":"SalesOrders","data":{"Sell_to_Customer_No":"10000"}},
+		{"operation":"create","endpoint":"$1/SalesLines","data":{"No":"1000"},"depends_on":["1"]},
+		{"operation":"delete","endpoint":"Items","key":"OLD"}
+	]}`))
+
+	want := []string{
+		"GET Items('OLD')  ",
+		`POST SalesOrders  atomic|POST $1/SalesLines  atomic|DELETE Items('OLD') W/"7" atomic`,
+	}
+	if strings.Join(batches, "\n") != strings.Join(want, "\n") {
+		t.Errorf("batches =\n%s\nwant\n%s", strings.Join(batches, "\n"), strings.Join(want, "\n"))
+	}
+
+	var result struct {
+		Operations []struct {
+			ID      string
+			Status  int
+			Success bool
+			Error   interface{}
+		}
+		Succeeded, Failed int
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 2 || !result.Operations[0].Success || result.Operations[1].Status != 400 || result.Operations[2].Status != 0 || result.Operations[2].Error == nil {
+		t.Errorf("result = %s", text)
+	}
+
+	// Every write is checked against the policy before the batch is sent
+	if err := server.SetPolicy(Policy{EntitySets: map[string]EntitySetPermissions{"SalesLines": {Deny: []string{"create"}}}}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	batches = nil
+	response := call(`{"operations":[{"operation":"create","endpoint":"SalesOrders","data":{}},{"operation":"create","endpoint":"$1/SalesLines","data":{}}]}`)
+	if response.Error == nil || response.Error.Message != "Operation not permitted" || len(batches) != 0 {
+		t.Errorf("denied batch = %+v, sent %v", response.Error, batches)
+	}
+
+	// A write to $1 is checked against the entity set of operation 1, and every
+	// write against the tool of its operation
+	for _, tc := range []struct {
+		name   string
+		policy Policy
+	}{
+		{"entity set", Policy{EntitySets: map[string]EntitySetPermissions{"Customers": {Deny: []string{"delete"}}}}},
+		{"tool", Policy{DenyTools: []string{"bc_odata_delete"}}},
+		{"tool allow list", Policy{AllowTools: []string{"bc_odata_batch", "bc_odata_create"}}},
+	} {
+		if err := server.SetPolicy(tc.policy); err != nil {
+			t.Fatalf("%s: SetPolicy() error = %v", tc.name, err)
+		}
+		batches = nil
+		response := call(`{"operations":[{"operation":"read","endpoint":"Customers","key":"10000"},{"operation":"delete","endpoint":"$1"}]}`)
+		if response.Error == nil || response.Error.Message != "Operation not permitted" || len(batches) != 0 {
+			t.Errorf("%s: denied batch = %+v, sent %v", tc.name, response.Error, batches)
+		}
+	}
+
+	// A reference whose entity set cannot be resolved is rejected
+	if err := server.SetPolicy(Policy{}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	batches = nil
+	response = call(`{"operations":[{"operation":"delete","endpoint":"$9"}]}`)
+	if response.Error == nil || response.Error.Code != -32602 || len(batches) != 0 {
+		t.Errorf("unresolved reference = %+v, sent %v", response.Error, batches)
+	}
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+// companyArgSchema is the company argument accepted by every tool that reads
+// or writes company data
+var companyArgSchema = map[string]interface{}{
+	"type":        "string",
+	"description": "Business Central company to use (defaults to BC_COMPANY). Use bc_odata_list_companies to see the available companies.",
+}
+
+type clientContextKey struct{}
+
+// withClient returns ctx carrying the client of the company selected for a tool call
+func withClient(ctx context.Context, client *bc.Client) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// clientFor returns the client of the company selected for the current tool
+// call, or the client of the selected profile's default company
+func (s *Server) clientFor(ctx context.Context) *bc.Client {
+	if client, ok := ctx.Value(clientContextKey{}).(*bc.Client); ok {
+		return client
+	}
+	return s.profileFor(ctx).client
+}
+
+// selectCompany returns ctx carrying a client for the named company of the
+// selected profile; an empty name leaves ctx on the profile's default company
+func (s *Server) selectCompany(ctx context.Context, company string) (context.Context, error) {
+	if company == "" {
+		return ctx, nil
+	}
+	client, err := s.profileFor(ctx).client.ForCompany(company)
+	if err != nil {
+		return ctx, err
+	}
+	return withClient(ctx, client), nil
+}
+
+// handleListCompanies lists the companies of the selected profile's environment
+func (s *Server) handleListCompanies(ctx context.Context, id interface{}) *JSONRPCResponse {
+	client := s.profileFor(ctx).client
+	companies, err := client.ListCompanies(ctx)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Failed to list companies",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	resultJSON, _ := json.Marshal(map[string]interface{}{
+		"companies": companies,
+		"count":     len(companies),
+		"default":   client.Company(),
+	})
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		},
+	}
+}
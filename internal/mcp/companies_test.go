@@ -0,0 +1,60 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+func TestServer_CompanySelection(t *testing.T) {
+	var paths []string
+	server := newTestServerWithConfig(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/Companies"):
+			_, _ = w.Write([]byte(`{"value":[{"Name":"CRONUS"},{"Name":"O'Neil & Co"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"value":[{"No":"10000"}]}`))
+		}
+	}, func(cfg *bc.Config) {
+		cfg.BasePath += "ODataV4/"
+		cfg.Company = "CRONUS"
+	})
+
+	call := func(arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_query","arguments":`+arguments+`}}`))
+	}
+
+	resultText(t, call(`{"endpoint":"Customers"}`))
+	resultText(t, call(`{"endpoint":"Customers","company":"O'Neil & Co"}`))
+	want := []string{
+		"/ODataV4/Company('CRONUS')/Customers",
+		"/ODataV4/Company('O%27%27Neil%20&%20Co')/Customers",
+	}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("request paths = %v, want %v", paths, want)
+	}
+
+	text := resultText(t, server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"bc_odata_list_companies","arguments":{}}}`)))
+	if !strings.Contains(text, `"count":2`) || !strings.Contains(text, `"default":"CRONUS"`) {
+		t.Errorf("list companies = %s", text)
+	}
+	if last := paths[len(paths)-1]; last != "/ODataV4/Companies" {
+		t.Errorf("companies path = %s, want the service root", last)
+	}
+}
+
+func TestServer_CompanySelectionUnsupported(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers","company":"Other"}}}`))
+	if response.Error == nil || response.Error.Code != -32602 {
+		t.Errorf("response = %+v, want invalid params", response)
+	}
+}
@@ -0,0 +1,330 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+// confirmationTTL is how long a dry run's confirmation token can be redeemed
+const confirmationTTL = 5 * time.Minute
+
+// dryRunArgSchema and confirmationTokenArgSchema drive the two-phase write workflow
+var (
+	dryRunArgSchema = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Preview the write without executing it: validates data against metadata, returns the current record, the changes and a confirmation_token",
+	}
+	confirmationTokenArgSchema = map[string]interface{}{
+		"type":        "string",
+		"description": "confirmation_token returned by a dry run of this exact call. Required for entity sets configured to need confirmation.",
+	}
+)
+
+// pendingWrite is a previewed write waiting for its confirmation token
+type pendingWrite struct {
+	fingerprint string
+	etag        string // @odata.etag of the record shown in the preview
+	expires     time.Time
+}
+
+// confirmations holds the tokens issued by dry runs. A token is single use and
+// only confirms the write it previewed.
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]pendingWrite
+	now     func() time.Time
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{
+		pending: make(map[string]pendingWrite),
+		now:     time.Now,
+	}
+}
+
+// issue returns a new token for the write with the given fingerprint, which
+// previewed the record version etag ("" when there is none)
+func (c *confirmations) issue(fingerprint, etag string) (string, time.Time) {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	token := hex.EncodeToString(random[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, write := range c.pending {
+		if now.After(write.expires) {
+			delete(c.pending, key)
+		}
+	}
+	expires := now.Add(confirmationTTL)
+	c.pending[token] = pendingWrite{fingerprint: fingerprint, etag: etag, expires: expires}
+	return token, expires
+}
+
+// redeem consumes a token, checking it previewed the write with the given
+// fingerprint, and returns the ETag of the previewed record
+func (c *confirmations) redeem(token, fingerprint string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	write, ok := c.pending[token]
+	if !ok || c.now().After(write.expires) {
+		delete(c.pending, token)
+		return "", fmt.Errorf("the confirmation token is unknown, already used or expired; run the call with dry_run=true again")
+	}
+	if write.fingerprint != fingerprint {
+		return "", fmt.Errorf("the confirmation token was issued for a different call; pass the same arguments as the dry run")
+	}
+	delete(c.pending, token)
+	return write.etag, nil
+}
+
+// writeFingerprint identifies a write call by its tool, connection and
+// arguments, leaving out the dry-run arguments themselves
+func (s *Server) writeFingerprint(ctx context.Context, tool string, args map[string]interface{}) string {
+	call := map[string]interface{}{
+		"tool":  tool,
+		"scope": s.cursorScope(ctx),
+	}
+	for name, value := range args {
+		if name != "dry_run" && name != "confirmation_token" {
+			call["arg:"+name] = value
+		}
+	}
+	data, _ := json.Marshal(call)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// handleWriteTool runs a create, update or delete call: a dry run previews it,
+// otherwise the write executes once its confirmation token, if any, checks out
+func (s *Server) handleWriteTool(ctx context.Context, id interface{}, params ToolCallParams) *JSONRPCResponse {
+	args := params.Arguments
+	endpoint, _ := args["endpoint"].(string)
+	entitySets := []string{entitySetOf(endpoint)}
+	// A deep insert also creates entities in the entity sets of its nested entities
+	if data, ok := args["data"].(map[string]interface{}); ok && params.Name == "bc_odata_create" {
+		nested, err := s.checkDeepInsert(ctx, entitySetOf(endpoint), data)
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Operation not permitted",
+					Data:    err.Error(),
+				},
+			}
+		}
+		entitySets = append(entitySets, nested...)
+	}
+
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		return s.previewWrite(ctx, id, params.Name, args)
+	}
+
+	var confirm []string
+	for _, entitySet := range entitySets {
+		if s.policy.confirmationRequired(entitySet) {
+			confirm = append(confirm, entitySet)
+		}
+	}
+	if token, _ := args["confirmation_token"].(string); token != "" {
+		etag, err := s.confirmations.redeem(token, s.writeFingerprint(ctx, params.Name, args))
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32602,
+					Message: "Invalid params: invalid confirmation token",
+					Data:    err.Error(),
+				},
+			}
+		}
+		// The write applies to the version the user reviewed: a change made
+		// since the dry run is a conflict, not overwritten
+		if _, pinned := args["etag"].(string); !pinned && etag != "" {
+			confirmed := make(map[string]interface{}, len(args)+1)
+			for name, value := range args {
+				confirmed[name] = value
+			}
+			confirmed["etag"] = etag
+			args = confirmed
+		}
+	} else if len(confirm) > 0 {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: confirmation required",
+				Data:    fmt.Sprintf("Writes to '%s' must be previewed first: call %s with dry_run=true, review the changes, then repeat the call with the returned confirmation_token", strings.Join(confirm, "', '"), params.Name),
+			},
+		}
+	}
+
+	switch params.Name {
+	case "bc_odata_create":
+		return s.handleCreate(ctx, id, args)
+	case "bc_odata_update":
+		return s.handleUpdate(ctx, id, args)
+	default:
+		return s.handleDelete(ctx, id, args)
+	}
+}
+
+// previewWrite validates a write, reads the record it would change and
+// returns the changes with a confirmation token
+func (s *Server) previewWrite(ctx context.Context, id interface{}, tool string, args map[string]interface{}) *JSONRPCResponse {
+	operation := writeOperations[tool]
+	invalid := func(message, data string) *JSONRPCResponse {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: " + message,
+				Data:    data,
+			},
+		}
+	}
+
+	endpoint, ok := args["endpoint"].(string)
+	if !ok || endpoint == "" {
+		return invalid("endpoint is required", "")
+	}
+	data, hasData := args["data"].(map[string]interface{})
+	if operation != operationDelete && !hasData {
+		return invalid("data is required and must be an object", "")
+	}
+	key := args["key"]
+	hasKey := key != nil && key != ""
+	if operation != operationCreate && !hasKey {
+		return invalid("key is required", "")
+	}
+
+	preview := map[string]interface{}{
+		"dry_run":   true,
+		"operation": operation,
+		"endpoint":  endpoint,
+	}
+	var warnings []string
+
+	if hasData {
+		validate := (*metadata.Schema).ValidatePayload
+		if operation == operationUpdate {
+			validate = (*metadata.Schema).ValidateUpdate
+		}
+		schema, err := s.loadSchema(ctx)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("data was not validated, metadata is unavailable: %s", err.Error()))
+		} else if problems, err := validate(schema, endpoint, data); err != nil {
+			warnings = append(warnings, fmt.Sprintf("data was not validated: %s", err.Error()))
+		} else if len(problems) > 0 {
+			return invalid("data does not match the metadata", strings.Join(problems, "; "))
+		}
+	}
+
+	var current map[string]interface{}
+	if hasKey {
+		preview["key"] = key
+		// The same URL as the write, see keyedEndpoint
+		query, err := s.keyedQuery(ctx, endpoint, key)
+		if err != nil {
+			return invalid("invalid key", err.Error())
+		}
+		record, err := s.clientFor(ctx).GetEntity(ctx, query)
+		var odataErr *bc.ODataError
+		if errors.As(err, &odataErr) && odataErr.StatusCode == http.StatusNotFound {
+			return invalid("record not found", fmt.Sprintf("No record with key '%v' in '%s'", key, endpoint))
+		}
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Dry run failed",
+					Data:    fmt.Sprintf("Failed to read the current record '%v' from '%s': %s", key, endpoint, err.Error()),
+				},
+			}
+		}
+		current = record
+		preview["current"] = current
+		if etag, _ := record["@odata.etag"].(string); etag != "" {
+			preview["etag"] = etag
+		}
+	}
+
+	switch operation {
+	case operationDelete:
+		preview["summary"] = fmt.Sprintf("Deletes the record '%v' from '%s'", key, endpoint)
+	default:
+		changes := writeChanges(current, data)
+		preview["changes"] = changes
+		if operation == operationCreate {
+			preview["summary"] = fmt.Sprintf("Creates a record in '%s' with %d field(s)", endpoint, len(changes))
+		} else {
+			preview["summary"] = fmt.Sprintf("Changes %d field(s) of the record '%v' in '%s'", len(changes), key, endpoint)
+		}
+	}
+	if len(warnings) > 0 {
+		preview["warnings"] = warnings
+	}
+
+	etag, _ := current["@odata.etag"].(string)
+	token, expires := s.confirmations.issue(s.writeFingerprint(ctx, tool, args), etag)
+	preview["confirmation_token"] = token
+	preview["expires_at"] = expires.UTC().Format(time.RFC3339)
+	preview["next_step"] = fmt.Sprintf("To execute, call %s again with the same arguments, without dry_run and with confirmation_token", tool)
+
+	resultJSON, _ := json.Marshal(preview)
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		},
+	}
+}
+
+// writeChanges lists the fields a write sets with their current and new
+// values. Fields that already hold the new value are left out.
+func writeChanges(current, data map[string]interface{}) map[string]interface{} {
+	changes := make(map[string]interface{}, len(data))
+	for name, value := range data {
+		if strings.Contains(name, "@") {
+			continue
+		}
+		before, exists := current[name]
+		if exists && reflect.DeepEqual(before, value) {
+			continue
+		}
+		changes[name] = map[string]interface{}{
+			"from": before,
+			"to":   value,
+		}
+	}
+	return changes
+}
@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfirmMetadata = `<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="Customer">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" MaxLength="20" />
+        <Property Name="Name" Type="Edm.String" MaxLength="100" />
+        <Property Name="Credit_Limit_LCY" Type="Edm.Decimal" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="Customers" EntityType="NAV.Customer" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func TestServer_DryRunAndConfirm(t *testing.T) {
+	var writes []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testConfirmMetadata))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"@odata.etag":"W/\"1\"","No":"10000","Name":"Adatum","Credit_Limit_LCY":0}`))
+	})
+	if err := server.SetPolicy(Policy{EntitySets: map[string]EntitySetPermissions{"Customers": {RequireConfirmation: true}}}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	call := func(arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_update","arguments":`+arguments+`}}`))
+	}
+	const update = `"endpoint":"Customers","key":"10000","data":{"Name":"Adatum Corporation","Credit_Limit_LCY":0}`
+
+	if response := call(`{` + update + `}`); response.Error == nil || !strings.Contains(response.Error.Message, "confirmation required") {
+		t.Errorf("unconfirmed update = %+v, want confirmation required", response)
+	}
+
+	var preview struct {
+		Operation         string                            `json:"operation"`
+		Changes           map[string]map[string]interface{} `json:"changes"`
+		ConfirmationToken string                            `json:"confirmation_token"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, call(`{`+update+`,"dry_run":true}`))), &preview); err != nil {
+		t.Fatalf("dry run result: %v", err)
+	}
+	if preview.Operation != "update" || preview.ConfirmationToken == "" {
+		t.Errorf("preview = %+v", preview)
+	}
+	if len(preview.Changes) != 1 || preview.Changes["Name"]["from"] != "Adatum" || preview.Changes["Name"]["to"] != "Adatum Corporation" {
+		t.Errorf("changes = %v, want only Name", preview.Changes)
+	}
+	if len(writes) != 0 {
+		t.Fatalf("dry run wrote %v", writes)
+	}
+
+	if response := call(`{"endpoint":"Customers","key":"10000","data":{"Name":"Other"},"confirmation_token":"` + preview.ConfirmationToken + `"}`); response.Error == nil {
+		t.Error("a token must not confirm a different payload")
+	}
+	if response := call(`{` + update + `,"confirmation_token":"` + preview.ConfirmationToken + `"}`); response.Error != nil {
+		t.Errorf("confirmed update = %+v", response.Error)
+	}
+	if response := call(`{` + update + `,"confirmation_token":"` + preview.ConfirmationToken + `"}`); response.Error == nil {
+		t.Error("a token must be single use")
+	}
+	if len(writes) != 1 || writes[0] != "PATCH /Customers('10000')" {
+		t.Errorf("writes = %v, want the confirmed update only", writes)
+	}
+
+	if response := call(`{"endpoint":"Customers","key":"10000","data":{"Nmae":"Adatum"},"dry_run":true}`); response.Error == nil || !strings.Contains(response.Error.Data, "Did you mean: Name") {
+		t.Errorf("dry run with unknown field = %+v, want a metadata error", response)
+	}
+}
+
+func TestServer_ConfirmPreviewedVersion(t *testing.T) {
+	version := `W/"1"`
+	var ifMatch []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testConfirmMetadata))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+			if r.Header.Get("If-Match") != version {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				_, _ = w.Write([]byte(`{"error":{"code":"Request_EntityChanged","message":"Another user has already changed the record."}}`))
+				return
+			}
+		}
+		_, _ = w.Write([]byte(`{"@odata.etag":` + jsonString(version) + `,"No":"10000","Name":"Adatum","Credit_Limit_LCY":0}`))
+	})
+	if err := server.SetPolicy(Policy{EntitySets: map[string]EntitySetPermissions{"Customers": {RequireConfirmation: true}}}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	call := func(arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_update","arguments":`+arguments+`}}`))
+	}
+	const update = `"endpoint":"Customers","key":"10000","data":{"Name":"Adatum Corporation"}`
+
+	var preview struct {
+		ETag              string `json:"etag"`
+		ConfirmationToken string `json:"confirmation_token"`
+	}
+	if err := json.Unmarshal([]byte(resultText(t, call(`{`+update+`,"dry_run":true}`))), &preview); err != nil {
+		t.Fatalf("dry run result: %v", err)
+	}
+	if preview.ETag != `W/"1"` {
+		t.Errorf("preview etag = %q, want the version read", preview.ETag)
+	}
+
+	// Someone else changes the record between the dry run and the confirmation
+	version = `W/"2"`
+	response := call(`{` + update + `,"confirmation_token":"` + preview.ConfirmationToken + `"}`)
+	if response.Error == nil || response.Error.Code != -32003 {
+		t.Errorf("confirmed update of a changed record = %+v, want a conflict", response.Error)
+	}
+	if len(ifMatch) != 1 || ifMatch[0] != `W/"1"` {
+		t.Errorf("If-Match = %q, want the previewed version", ifMatch)
+	}
+}
+
+func TestConfirmations_Expiry(t *testing.T) {
+	store := newConfirmations()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	token, expires := store.issue("write", "")
+	if !expires.Equal(now.Add(confirmationTTL)) {
+		t.Errorf("expires = %v", expires)
+	}
+	now = now.Add(confirmationTTL + time.Second)
+	if _, err := store.redeem(token, "write"); err == nil {
+		t.Error("redeem() of an expired token error = nil")
+	}
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+// cursorScope is the connection a cursor was read from
+type cursorScope struct {
+	Profile string `json:"profile,omitempty"`
+	Company string `json:"company,omitempty"`
+}
+
+// cursorScope returns the profile and company selected for the current tool call
+func (s *Server) cursorScope(ctx context.Context) cursorScope {
+	return cursorScope{Profile: s.profileFor(ctx).name, Company: s.clientFor(ctx).Company()}
+}
+
+// cursorState is the content of a next_cursor string
+type cursorState struct {
+	bc.Cursor
+	cursorScope
+}
+
+// encodeCursor turns a pagination cursor into the opaque next_cursor string
+// returned to clients
+func encodeCursor(cursor bc.Cursor, scope cursorScope) string {
+	data, _ := json.Marshal(cursorState{Cursor: cursor, cursorScope: scope})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor argument and checks that it continues a query
+// on the given endpoint of the same profile and company
+func decodeCursor(value, endpoint string, scope cursorScope) (bc.Cursor, error) {
+	var state cursorState
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return state.Cursor, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &state); err != nil || state.Endpoint == "" || state.Offset < 0 || state.PageSize < 0 {
+		return state.Cursor, fmt.Errorf("invalid cursor")
+	}
+	cursor := state.Cursor
+	if state.cursorScope != scope {
+		return cursor, fmt.Errorf("cursor belongs to profile '%s', company '%s'", state.Profile, state.Company)
+	}
+
+	// The cursor must address the same entity set, and cannot climb out of the service root
+	path, _, _ := strings.Cut(cursor.Endpoint, "?")
+	if !strings.EqualFold(strings.Trim(path, "/"), strings.Trim(endpoint, "/")) || strings.Contains(path, "..") {
+		return cursor, fmt.Errorf("cursor does not belong to endpoint '%s'", endpoint)
+	}
+	return cursor, nil
+}
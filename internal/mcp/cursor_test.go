@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	valid := encodeCursor(bc.Cursor{Endpoint: "Customers?$skiptoken=abc", Offset: 2}, cursorScope{Profile: "default", Company: "CRONUS"})
+	cursor, err := decodeCursor(valid, "Customers", cursorScope{Profile: "default", Company: "CRONUS"})
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if cursor.Endpoint != "Customers?$skiptoken=abc" || cursor.Offset != 2 {
+		t.Errorf("decodeCursor() = %+v", cursor)
+	}
+
+	for name, value := range map[string]string{
+		"garbage":        "not a cursor!",
+		"other endpoint": encodeCursor(bc.Cursor{Endpoint: "Vendors?$skiptoken=abc"}, cursorScope{Profile: "default", Company: "CRONUS"}),
+		"path traversal": encodeCursor(bc.Cursor{Endpoint: "Customers/../../other"}, cursorScope{Profile: "default", Company: "CRONUS"}),
+		"other company":  encodeCursor(bc.Cursor{Endpoint: "Customers?$skiptoken=abc"}, cursorScope{Profile: "default", Company: "Other"}),
+	} {
+		if _, err := decodeCursor(value, "Customers", cursorScope{Profile: "default", Company: "CRONUS"}); err == nil {
+			t.Errorf("decodeCursor(%s) error = nil, want error", name)
+		}
+	}
+}
+
+func TestServer_handleODataQuery_Cursor(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testMetadata))
+			return
+		}
+		// Five customers, honoring $top and $skip without @odata.nextLink
+		skip, _ := strconv.Atoi(r.URL.Query().Get("$skip"))
+		top, _ := strconv.Atoi(r.URL.Query().Get("$top"))
+		var resp bc.ODataResponse
+		for i := skip; i < 5 && i < skip+top; i++ {
+			resp.Value = append(resp.Value, map[string]interface{}{"No": fmt.Sprint(i)})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+
+	type queryResult struct {
+		Results    []map[string]interface{} `json:"results"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	call := func(args map[string]interface{}) queryResult {
+		var result queryResult
+		text := resultText(t, server.handleODataQuery(context.Background(), 1, args))
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		return result
+	}
+
+	var seen []string
+	result := call(map[string]interface{}{"endpoint": "Customers", "top": float64(2)})
+	for pages := 1; ; pages++ {
+		for _, r := range result.Results {
+			seen = append(seen, r["No"].(string))
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		if pages > 5 {
+			t.Fatal("cursor did not terminate")
+		}
+		result = call(map[string]interface{}{"endpoint": "Customers", "cursor": result.NextCursor})
+	}
+
+	if strings.Join(seen, ",") != "0,1,2,3,4" {
+		t.Errorf("records = %v, want 0..4 in order", seen)
+	}
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+// nestedNavigation returns the navigation properties a create payload fills
+// with nested entities, i.e. the parts of a deep insert, sorted
+func nestedNavigation(et *metadata.EntityType, data map[string]interface{}) []string {
+	var names []string
+	for name, value := range data {
+		if _, ok := et.NavigationProperty(name); ok && value != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deepInsertTargets returns the entity sets a create payload writes through
+// nested entities, at any depth, sorted. A navigation property targets the
+// entity set of its NavigationPropertyBinding, or else the entity set named
+// like it, as in Business Central. Without metadata every nested object counts
+// as an insert into the entity set its field is named after.
+func (s *Server) deepInsertTargets(ctx context.Context, entitySet string, data map[string]interface{}) []string {
+	var et *metadata.EntityType
+	var bindings map[string]string
+	schema, err := s.loadSchema(ctx)
+	if err != nil {
+		schema = nil
+	} else {
+		et, bindings = navigationOf(schema, entitySet)
+	}
+
+	targets := make(map[string]bool)
+	collectDeepInsertTargets(schema, et, bindings, data, targets)
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// collectDeepInsertTargets adds the entity sets written by the nested entities
+// of one entity payload; et is nil when its type is unknown
+func collectDeepInsertTargets(schema *metadata.Schema, et *metadata.EntityType, bindings map[string]string, data map[string]interface{}, targets map[string]bool) {
+	for name, value := range data {
+		entities := nestedEntities(value)
+		if len(entities) == 0 {
+			continue
+		}
+		var nav *metadata.NavigationProperty
+		if et != nil {
+			var ok bool
+			if nav, ok = et.NavigationProperty(name); !ok {
+				continue
+			}
+		}
+
+		target := name
+		if bound, ok := bindings[name]; ok {
+			target = bound[strings.LastIndex(bound, "/")+1:]
+		}
+		targets[target] = true
+
+		var targetType *metadata.EntityType
+		var targetBindings map[string]string
+		if schema != nil {
+			targetType, targetBindings = navigationOf(schema, target)
+			if nav != nil {
+				targetType, _ = schema.EntityType(nav.TargetType())
+			}
+		}
+		for _, entity := range entities {
+			collectDeepInsertTargets(schema, targetType, targetBindings, entity, targets)
+		}
+	}
+}
+
+// navigationOf returns the entity type and navigation bindings of an entity set
+func navigationOf(schema *metadata.Schema, entitySet string) (*metadata.EntityType, map[string]string) {
+	var bindings map[string]string
+	if set, ok := schema.EntitySet(entitySet); ok {
+		bindings = set.NavigationBindings
+	}
+	et, err := schema.EntityTypeOf(entitySet)
+	if err != nil {
+		return nil, bindings
+	}
+	return et, bindings
+}
+
+// nestedEntities returns the entities in the value of a navigation property:
+// an object, or the objects of an array
+func nestedEntities(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var entities []map[string]interface{}
+		for _, item := range v {
+			if entity, ok := item.(map[string]interface{}); ok {
+				entities = append(entities, entity)
+			}
+		}
+		return entities
+	}
+	return nil
+}
+
+// checkDeepInsert applies the write policy to the entity sets a create payload
+// writes through nested entities. It returns the ones that need a confirmed
+// dry run.
+func (s *Server) checkDeepInsert(ctx context.Context, entitySet string, data map[string]interface{}) ([]string, error) {
+	var confirm []string
+	for _, target := range s.deepInsertTargets(ctx, entitySet, data) {
+		if err := s.policy.checkWrite(target, operationCreate); err != nil {
+			return nil, fmt.Errorf("nested entities in '%s': %w", entitySet, err)
+		}
+		if s.policy.confirmationRequired(target) {
+			confirm = append(confirm, target)
+		}
+	}
+	return confirm, nil
+}
+
+// expandCreated reads a deep-inserted entity back with its nested entities
+// expanded, since Business Central answers the POST with the header only. The
+// entity is addressed by the key values of the created header.
+func (s *Server) expandCreated(ctx context.Context, endpoint string, et *metadata.EntityType, created map[string]interface{}, navigation []string) (map[string]interface{}, error) {
+	key := make(map[string]interface{})
+	for _, prop := range et.KeyProperties() {
+		value, ok := created[prop.Name]
+		if !ok {
+			return nil, fmt.Errorf("the created entity has no value for key field '%s'", prop.Name)
+		}
+		key[prop.Name] = value
+	}
+
+	query, err := s.entityQuery(ctx, endpoint, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range navigation {
+		query.Expand(name, nil)
+	}
+	entity, err := s.clientFor(ctx).GetEntity(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// hasExpanded reports whether a response already includes every navigation property
+func hasExpanded(entity map[string]interface{}, navigation []string) bool {
+	for _, name := range navigation {
+		if _, ok := entity[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testDeepInsertMetadata = `<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="SalesOrder">
+        <Key><PropertyRef Name="Document_Type" /><PropertyRef Name="No" /></Key>
+        <Property Name="Document_Type" Type="Edm.String" Nullable="false" />
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+        <Property Name="Sell_to_Customer_No" Type="Edm.String" MaxLength="20" />
+        <NavigationProperty Name="SalesLines" Type="Collection(NAV.SalesLine)" ContainsTarget="true" />
+      </EntityType>
+      <EntityType Name="SalesLine">
+        <Key><PropertyRef Name="Line_No" /></Key>
+        <Property Name="Line_No" Type="Edm.Int32" Nullable="false" />
+        <Property Name="No" Type="Edm.String" />
+        <Property Name="Quantity" Type="Edm.Decimal" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="SalesOrders" EntityType="NAV.SalesOrder" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func TestServer_handleCreate_DeepInsert(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testDeepInsertMetadata))
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"Document_Type":"Order","No":"1001","Sell_to_Customer_No":"10000"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"Document_Type":"Order","No":"1001","Sell_to_Customer_No":"10000","SalesLines":[{"Line_No":10000,"No":"1000","Quantity":5}]}`))
+	})
+	ctx := context.Background()
+
+	text := resultText(t, server.handleCreate(ctx, 1, map[string]interface{}{
+		"endpoint": "SalesOrders",
+		"data": map[string]interface{}{
+			"Sell_to_Customer_No": "10000",
+			"SalesLines":          []interface{}{map[string]interface{}{"No": "1000", "Quantity": 5.0}},
+		},
+	}))
+	if !strings.Contains(text, `"SalesLines":[{"Line_No":10000`) {
+		t.Errorf("result = %s, want the header with its lines", text)
+	}
+	want := []string{
+		"POST /SalesOrders?",
+		"GET /SalesOrders(Document_Type='Order',No='1001')?$expand=SalesLines",
+	}
+	if strings.Join(requests, "|") != strings.Join(want, "|") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+
+	requests = nil
+	response := server.handleCreate(ctx, 2, map[string]interface{}{
+		"endpoint": "SalesOrders",
+		"data": map[string]interface{}{
+			"SalesLines": []interface{}{map[string]interface{}{"No": "1000", "Qty": 5.0}},
+		},
+	})
+	if response.Error == nil || response.Error.Code != -32602 || !strings.Contains(response.Error.Data, "SalesLines[0].Qty") {
+		t.Errorf("invalid line = %+v, want invalid params naming the line field", response.Error)
+	}
+	if len(requests) != 0 {
+		t.Errorf("invalid payload was sent: %v", requests)
+	}
+}
+
+func TestServer_DeepInsertPolicy(t *testing.T) {
+	var writes []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testDeepInsertMetadata))
+			return
+		}
+		if r.Method != http.MethodGet {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"Document_Type":"Order","No":"1001"}`))
+	})
+	err := server.SetPolicy(Policy{EntitySets: map[string]EntitySetPermissions{
+		"SalesOrders": {Allow: []string{"create", "update"}},
+		"SalesLines":  {Deny: []string{"create"}},
+	}})
+	if err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	call := func(name, arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+name+`","arguments":`+arguments+`}}`))
+	}
+	const lines = `"SalesLines":[{"No":"1000","Quantity":5}]`
+
+	for _, denied := range []struct{ name, arguments string }{
+		{"bc_odata_create", `{"endpoint":"SalesOrders","data":{` + lines + `}}`},
+		{"bc_odata_create", `{"endpoint":"SalesOrders","data":{` + lines + `},"dry_run":true}`},
+		{"bc_odata_batch", `{"operations":[{"operation":"create","endpoint":"SalesOrders","data":{` + lines + `}}]}`},
+	} {
+		response := call(denied.name, denied.arguments)
+		if response.Error == nil || !strings.Contains(response.Error.Data, "create is not permitted on entity set 'SalesLines'") {
+			t.Errorf("%s %s = %+v, want the nested create denied", denied.name, denied.arguments, response.Error)
+		}
+	}
+	if len(writes) != 0 {
+		t.Errorf("denied deep inserts were sent: %v", writes)
+	}
+
+	// Entity sets that need confirmation also cover nested entities
+	if err := server.SetPolicy(Policy{EntitySets: map[string]EntitySetPermissions{"SalesLines": {RequireConfirmation: true}}}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+	if response := call("bc_odata_create", `{"endpoint":"SalesOrders","data":{`+lines+`}}`); response.Error == nil || !strings.Contains(response.Error.Data, "'SalesLines' must be previewed") {
+		t.Errorf("unconfirmed deep insert = %+v, want confirmation required", response.Error)
+	}
+	if response := call("bc_odata_batch", `{"operations":[{"operation":"create","endpoint":"SalesOrders","data":{`+lines+`}}]}`); response.Error == nil || !strings.Contains(response.Error.Message, "confirmation required") {
+		t.Errorf("deep insert in a batch = %+v, want confirmation required", response.Error)
+	}
+
+	// An update cannot write nested entities, previewed or not
+	for _, dryRun := range []string{`,"dry_run":true`, ``} {
+		response := call("bc_odata_update", `{"endpoint":"SalesOrders","key":{"Document_Type":"Order","No":"1001"},"data":{`+lines+`}`+dryRun+`}`)
+		if response.Error == nil || response.Error.Code != -32602 || !strings.Contains(response.Error.Data, "cannot be set in an update") {
+			t.Errorf("update%s with lines = %+v, want invalid params", dryRun, response.Error)
+		}
+	}
+	if len(writes) != 0 {
+		t.Errorf("writes = %v, want none", writes)
+	}
+}
+
+func TestDeepInsertTargets_WithoutMetadata(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	targets := server.deepInsertTargets(context.Background(), "SalesOrders", map[string]interface{}{
+		"No":         "1001",
+		"SalesLines": []interface{}{map[string]interface{}{"No": "1000", "ItemTrackingLines": []interface{}{map[string]interface{}{"Serial_No": "S1"}}}},
+	})
+	if strings.Join(targets, ",") != "ItemTrackingLines,SalesLines" {
+		t.Errorf("deepInsertTargets() = %v, want every nested entity set", targets)
+	}
+}
@@ -0,0 +1,282 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/filterexpr"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+	"github.com/rs/zerolog/log"
+)
+
+// filterOperatorAliases maps the operators LLMs commonly produce to OData operators
+var filterOperatorAliases = map[string]string{
+	"=": "eq", "==": "eq", "!=": "ne", "<>": "ne",
+	">": "gt", ">=": "ge", "<": "lt", "<=": "le",
+}
+
+// filterSchemaDescription documents the structured filter form in tool schemas
+const filterSchemaDescription = "OData $filter, either as a string (e.g., \"No eq '12345'\") or as a JSON filter tree rendered with the correct literal types from $metadata: " +
+	"{\"field\":\"Posting_Date\",\"op\":\"ge\",\"value\":\"2024-01-01\"}, combined with {\"and\":[...]}, {\"or\":[...]} or {\"not\":{...}}. " +
+	"Operators: eq, ne, gt, ge, lt, le, contains, startswith, endswith, in (value is an array)."
+
+// filterArgSchema is the JSON schema of the filter argument shared by the query tools
+func filterArgSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "object"},
+		},
+		"description": description,
+	}
+}
+
+// queryFilter converts the filter argument of a tool call into a filter tree.
+// A string is passed through verbatim; an object is parsed as a filter tree whose
+// literals are typed from the metadata of the entity set. It returns nil when no
+// filter was supplied.
+func (s *Server) queryFilter(ctx context.Context, endpoint string, arg interface{}) (bc.Filter, error) {
+	switch filter := arg.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if strings.TrimSpace(filter) == "" {
+			return nil, nil
+		}
+		if err := s.validateFilter(ctx, endpoint, filter); err != nil {
+			return nil, err
+		}
+		return bc.RawFilter(filter), nil
+	case map[string]interface{}:
+		resolver := &filterResolver{}
+		if schema, err := s.loadSchema(ctx); err != nil {
+			// Without metadata the tree is still rendered, inferring literal types from the JSON values
+			log.Warn().Err(err).Str("endpoint", endpoint).Msg("Rendering filter tree without metadata")
+		} else {
+			et, err := schema.EntityTypeOf(endpoint)
+			if err != nil {
+				return nil, err
+			}
+			resolver.schema = schema
+			resolver.entityType = et
+		}
+		return resolver.parse(filter)
+	default:
+		return nil, fmt.Errorf("filter must be a string or an object, got %T", arg)
+	}
+}
+
+// validateFilter checks a $filter string against the metadata of the entity set,
+// so field and type mistakes are reported without a round trip to Business Central.
+// Validation is skipped when the metadata or the entity set is not available.
+func (s *Server) validateFilter(ctx context.Context, endpoint, filter string) error {
+	schema, err := s.loadSchema(ctx)
+	if err != nil {
+		log.Debug().Err(err).Str("endpoint", endpoint).Msg("Skipping $filter validation without metadata")
+		return nil
+	}
+	et, err := schema.EntityTypeOf(endpoint)
+	if err != nil {
+		log.Debug().Err(err).Str("endpoint", endpoint).Msg("Skipping $filter validation")
+		return nil
+	}
+	return filterexpr.Validate(schema, et, filter)
+}
+
+// filterResolver parses filter trees, resolving field types against an entity
+// type when metadata is available
+type filterResolver struct {
+	schema     *metadata.Schema
+	entityType *metadata.EntityType
+}
+
+// parse converts one node of a JSON filter tree
+func (r *filterResolver) parse(node map[string]interface{}) (bc.Filter, error) {
+	for _, op := range []string{"and", "or"} {
+		operands, ok := node[op]
+		if !ok {
+			continue
+		}
+		if len(node) != 1 {
+			return nil, fmt.Errorf("'%s' node must not have other keys", op)
+		}
+		list, ok := operands.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("'%s' requires a non-empty array of filters", op)
+		}
+		filters := make([]bc.Filter, 0, len(list))
+		for i, operand := range list {
+			child, ok := operand.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("'%s' operand %d must be an object", op, i)
+			}
+			filter, err := r.parse(child)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+		return bc.Logical{Op: op, Filters: filters}, nil
+	}
+
+	if operand, ok := node["not"]; ok {
+		if len(node) != 1 {
+			return nil, fmt.Errorf("'not' node must not have other keys")
+		}
+		child, ok := operand.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'not' requires a filter object")
+		}
+		filter, err := r.parse(child)
+		if err != nil {
+			return nil, err
+		}
+		return bc.Not(filter), nil
+	}
+
+	return r.parseComparison(node)
+}
+
+// parseComparison converts a {"field","op","value"} leaf
+func (r *filterResolver) parseComparison(node map[string]interface{}) (bc.Filter, error) {
+	field, _ := node["field"].(string)
+	if field == "" {
+		return nil, fmt.Errorf("filter node requires 'field' (or one of 'and', 'or', 'not'), got keys: %s", strings.Join(nodeKeys(node), ", "))
+	}
+	for key := range node {
+		if key != "field" && key != "op" && key != "value" {
+			return nil, fmt.Errorf("unknown key '%s' in filter on '%s'", key, field)
+		}
+	}
+
+	op := "eq"
+	if rawOp, ok := node["op"]; ok {
+		s, ok := rawOp.(string)
+		if !ok {
+			return nil, fmt.Errorf("'op' of filter on '%s' must be a string", field)
+		}
+		op = strings.ToLower(strings.TrimSpace(s))
+	}
+	if alias, ok := filterOperatorAliases[op]; ok {
+		op = alias
+	}
+
+	value, hasValue := node["value"]
+	if !hasValue {
+		return nil, fmt.Errorf("filter on '%s' requires 'value'", field)
+	}
+
+	path, edmType, err := r.resolveField(field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "eq", "ne", "gt", "ge", "lt", "le":
+		return bc.Comparison{Field: path, Op: op, Type: edmType, Value: value}, nil
+	case "contains", "startswith", "endswith":
+		if edmType != "" && edmType != "Edm.String" {
+			return nil, fmt.Errorf("%s requires a string field, but '%s' is %s", op, path, edmType)
+		}
+		return bc.FunctionCall{Name: op, Field: path, Type: edmType, Value: value}, nil
+	case "in":
+		values, ok := value.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("'in' on '%s' requires a non-empty array value", path)
+		}
+		return bc.In{Field: path, Type: edmType, Values: values}, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s' in filter on '%s' (use eq, ne, gt, ge, lt, le, contains, startswith, endswith, in)", op, field)
+	}
+}
+
+// resolveField validates a field (or a Navigation/Field path) against the entity
+// type and returns its canonical name and EDM type. Without metadata the field is
+// returned as given with an empty type.
+func (r *filterResolver) resolveField(field string) (string, string, error) {
+	if r.entityType == nil {
+		return field, "", nil
+	}
+
+	et := r.entityType
+	segments := strings.Split(field, "/")
+	resolved := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		if i == len(segments)-1 {
+			prop, ok := lookupProperty(et, segment)
+			if !ok {
+				return "", "", unknownFieldError(segment, et)
+			}
+			resolved = append(resolved, prop.Name)
+			return strings.Join(resolved, "/"), prop.Type, nil
+		}
+
+		nav, ok := lookupNavigationProperty(et, segment)
+		if !ok {
+			return "", "", fmt.Errorf("'%s' is not a navigation property of '%s'", segment, et.QualifiedName)
+		}
+		if nav.IsCollection() {
+			return "", "", fmt.Errorf("navigation property '%s' is a collection and cannot be used in a field path", nav.Name)
+		}
+		target, ok := r.schema.EntityType(nav.TargetType())
+		if !ok {
+			return "", "", fmt.Errorf("target type '%s' of '%s' not found in metadata", nav.TargetType(), nav.Name)
+		}
+		resolved = append(resolved, nav.Name)
+		et = target
+	}
+	return "", "", fmt.Errorf("empty field path")
+}
+
+// lookupProperty finds a structural property, tolerating casing differences
+func lookupProperty(et *metadata.EntityType, name string) (*metadata.Property, bool) {
+	if prop, ok := et.Property(name); ok {
+		return prop, true
+	}
+	for _, prop := range et.Properties {
+		if strings.EqualFold(prop.Name, name) {
+			return prop, true
+		}
+	}
+	return nil, false
+}
+
+// lookupNavigationProperty finds a navigation property, tolerating casing differences
+func lookupNavigationProperty(et *metadata.EntityType, name string) (*metadata.NavigationProperty, bool) {
+	if nav, ok := et.NavigationProperty(name); ok {
+		return nav, true
+	}
+	for _, nav := range et.NavigationProperties {
+		if strings.EqualFold(nav.Name, name) {
+			return nav, true
+		}
+	}
+	return nil, false
+}
+
+// unknownFieldError reports a field missing from the entity type, with suggestions
+func unknownFieldError(field string, et *metadata.EntityType) error {
+	names := make([]string, 0, len(et.Properties))
+	for _, prop := range et.Properties {
+		names = append(names, prop.Name)
+	}
+	msg := fmt.Sprintf("field '%s' does not exist on '%s'", field, et.QualifiedName)
+	if suggestions := metadata.Suggest(field, names, 3); len(suggestions) > 0 {
+		msg = fmt.Sprintf("%s. Did you mean: %s?", msg, strings.Join(suggestions, ", "))
+	}
+	return errors.New(msg)
+}
+
+// nodeKeys lists the keys of a filter node in sorted order, for error messages
+func nodeKeys(node map[string]interface{}) []string {
+	keys := make([]string, 0, len(node))
+	for k := range node {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
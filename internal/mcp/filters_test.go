@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+const testFilterMetadata = `<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EnumType Name="Status">
+        <Member Name="Open" Value="0" />
+        <Member Name="Released" Value="1" />
+      </EnumType>
+      <EntityType Name="Customer">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+        <Property Name="City" Type="Edm.String" />
+      </EntityType>
+      <EntityType Name="Invoice">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+        <Property Name="Posting_Date" Type="Edm.Date" />
+        <Property Name="Amount" Type="Edm.Decimal" />
+        <Property Name="Customer_Id" Type="Edm.Guid" />
+        <Property Name="Status" Type="NAV.Status" />
+        <Property Name="Closed" Type="Edm.Boolean" />
+        <NavigationProperty Name="Customer" Type="NAV.Customer" />
+        <NavigationProperty Name="Lines" Type="Collection(NAV.Customer)" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="Invoices" EntityType="NAV.Invoice" />
+        <EntitySet Name="Customers" EntityType="NAV.Customer" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func TestFilterResolver_Parse(t *testing.T) {
+	schema, err := metadata.Parse(strings.NewReader(testFilterMetadata))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	invoice, _ := schema.EntityTypeOf("Invoices")
+	resolver := &filterResolver{schema: schema, entityType: invoice}
+
+	tests := []struct {
+		name    string
+		node    map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			name: "typed and tree",
+			node: map[string]interface{}{"and": []interface{}{
+				map[string]interface{}{"field": "Posting_Date", "op": "ge", "value": "2024-01-01"},
+				map[string]interface{}{"field": "Amount", "op": ">", "value": "100.50"},
+				map[string]interface{}{"field": "no", "value": "O'Brien"},
+			}},
+			want: "Posting_Date ge 2024-01-01 and Amount gt 100.50 and No eq 'O''Brien'",
+		},
+		{
+			name: "or with not and in",
+			node: map[string]interface{}{"or": []interface{}{
+				map[string]interface{}{"not": map[string]interface{}{"field": "Closed", "op": "eq", "value": true}},
+				map[string]interface{}{"field": "Status", "op": "in", "value": []interface{}{"Open", "Released"}},
+			}},
+			want: "not (Closed eq true) or (Status eq 'Open' or Status eq 'Released')",
+		},
+		{
+			name: "guid and navigation path",
+			node: map[string]interface{}{"and": []interface{}{
+				map[string]interface{}{"field": "Customer_Id", "value": "{6F9619FF-8B86-D011-B42D-00C04FC964FF}"},
+				map[string]interface{}{"field": "customer/city", "op": "startswith", "value": "Mil"},
+			}},
+			want: "Customer_Id eq 6f9619ff-8b86-d011-b42d-00c04fc964ff and startswith(Customer/City,'Mil')",
+		},
+		{name: "unknown field", node: map[string]interface{}{"field": "Amout", "op": "gt", "value": 1}, wantErr: "Did you mean: Amount"},
+		{name: "decimal as text", node: map[string]interface{}{"field": "Amount", "op": "gt", "value": "lots"}, wantErr: "Amount"},
+		{name: "bad operator", node: map[string]interface{}{"field": "Amount", "op": "like", "value": 1}, wantErr: "unsupported operator"},
+		{name: "contains on number", node: map[string]interface{}{"field": "Amount", "op": "contains", "value": "1"}, wantErr: "string field"},
+		{name: "collection path", node: map[string]interface{}{"field": "Lines/City", "value": "x"}, wantErr: "collection"},
+		{name: "missing value", node: map[string]interface{}{"field": "Amount", "op": "gt"}, wantErr: "requires 'value'"},
+		{name: "mixed node", node: map[string]interface{}{"and": []interface{}{}, "field": "No"}, wantErr: "other keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := resolver.parse(tt.node)
+			var got string
+			if err == nil {
+				got, err = filter.Render()
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_handleODataQuery_FilterTree(t *testing.T) {
+	var rawQuery string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testFilterMetadata))
+			return
+		}
+		rawQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[]}`))
+	})
+
+	ctx := context.Background()
+	resultText(t, server.handleODataQuery(ctx, 1, map[string]interface{}{
+		"endpoint": "Invoices",
+		"filter":   map[string]interface{}{"field": "Amount", "op": "ge", "value": float64(10)},
+		"top":      float64(5),
+	}))
+
+	values, _ := url.ParseQuery(rawQuery)
+	if got := values.Get("$filter"); got != "Amount ge 10" {
+		t.Errorf("$filter = %q, want %q", got, "Amount ge 10")
+	}
+
+	response := server.handleCount(ctx, 2, map[string]interface{}{
+		"endpoint": "Invoices",
+		"filter":   map[string]interface{}{"field": "Posting_Dat", "value": "2024-01-01"},
+	})
+	if response.Error == nil || response.Error.Code != -32602 || !strings.Contains(response.Error.Data, "Posting_Date") {
+		t.Errorf("expected invalid params with suggestion, got %#v", response.Error)
+	}
+}
+
+func TestServer_handleODataQuery_InvalidRawFilter(t *testing.T) {
+	queried := false
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testFilterMetadata))
+			return
+		}
+		queried = true
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[]}`))
+	})
+
+	response := server.handleODataQuery(context.Background(), 1, map[string]interface{}{
+		"endpoint": "Invoices",
+		"filter":   "Amout gt '100'",
+	})
+	if response.Error == nil || response.Error.Code != -32602 || !strings.Contains(response.Error.Data, "Did you mean: Amount") {
+		t.Errorf("expected invalid params with suggestion, got %#v", response.Error)
+	}
+	if queried {
+		t.Error("invalid filter was sent to Business Central")
+	}
+}
@@ -0,0 +1,371 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// sessionHeader carries the session assigned on initialize
+const sessionHeader = "Mcp-Session-Id"
+
+const (
+	defaultHTTPPath       = "/mcp"
+	defaultSessionTimeout = 30 * time.Minute
+	maxRequestBodyBytes   = 4 << 20
+	shutdownTimeout       = 10 * time.Second
+)
+
+// HTTPOptions configures the Streamable HTTP transport
+type HTTPOptions struct {
+	Addr           string        // listen address, e.g. ":8080"
+	Path           string        // MCP endpoint, defaults to /mcp
+	AuthToken      string        // when set, requests must send Authorization: Bearer <token>
+	AllowedOrigins []string      // browser origins accepted besides the server's own host
+	SessionTimeout time.Duration // idle sessions expire after this, defaults to 30 minutes
+}
+
+// HTTPTransport serves MCP over Streamable HTTP: clients POST JSON-RPC messages
+// to a single endpoint and receive the responses as JSON or as an SSE stream
+type HTTPTransport struct {
+	options  HTTPOptions
+	mu       sync.Mutex
+	sessions map[string]time.Time // session ID -> last activity
+}
+
+// NewHTTPTransport creates a Streamable HTTP transport
+func NewHTTPTransport(options HTTPOptions) *HTTPTransport {
+	if options.Path == "" {
+		options.Path = defaultHTTPPath
+	}
+	if options.SessionTimeout <= 0 {
+		options.SessionTimeout = defaultSessionTimeout
+	}
+	return &HTTPTransport{
+		options:  options,
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// Serve listens on the configured address until ctx is cancelled
+func (t *HTTPTransport) Serve(ctx context.Context, handler Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(t.options.Path, t.Handler(handler))
+
+	// No write timeout: SSE responses stay open for as long as the request runs
+	server := &http.Server{
+		Addr:              t.options.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", t.options.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", t.options.Addr, err)
+	}
+	log.Info().Str("addr", listener.Addr().String()).Str("path", t.options.Path).Msg("MCP HTTP transport listening")
+	if t.options.AuthToken == "" {
+		log.Warn().Msg("No HTTP auth token configured: anyone who can reach the server can use its Business Central credentials")
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down HTTP server: %w", err)
+		}
+		return nil
+	}
+}
+
+// Handler returns the http.Handler for the MCP endpoint
+func (t *HTTPTransport) Handler(handler Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Guards against DNS rebinding from browsers
+		if !t.originAllowed(r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			t.handlePost(w, r, handler)
+		case http.MethodDelete:
+			t.handleDelete(w, r)
+		default:
+			// There are no server-initiated messages, so GET streams are not offered
+			w.Header().Set("Allow", "POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// httpMessage is the part of a JSON-RPC message needed for routing
+type httpMessage struct {
+	ID     interface{} `json:"id"`
+	Method string      `json:"method"`
+}
+
+// handlePost dispatches a message or a batch of messages
+func (t *HTTPTransport) handlePost(w http.ResponseWriter, r *http.Request, handler Handler) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if !json.Valid(body) {
+		http.Error(w, "request body is not valid JSON", http.StatusBadRequest)
+		return
+	}
+	batch := body[0] == '['
+	var messages []json.RawMessage
+	if batch {
+		if err := json.Unmarshal(body, &messages); err != nil || len(messages) == 0 {
+			http.Error(w, "invalid JSON-RPC batch", http.StatusBadRequest)
+			return
+		}
+	} else {
+		messages = []json.RawMessage{body}
+	}
+
+	initialize, hasRequests := false, false
+	for _, message := range messages {
+		var m httpMessage
+		_ = json.Unmarshal(message, &m)
+		if m.Method == "initialize" {
+			initialize = true
+		}
+		if m.ID != nil {
+			hasRequests = true
+		}
+	}
+
+	sessionID := r.Header.Get(sessionHeader)
+	if initialize {
+		if batch {
+			http.Error(w, "initialize must not be part of a batch", http.StatusBadRequest)
+			return
+		}
+		if sessionID, err = t.createSession(); err != nil {
+			http.Error(w, "failed to create session", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(sessionHeader, sessionID)
+	} else if status, msg := t.checkSession(r); status != http.StatusOK {
+		http.Error(w, msg, status)
+		return
+	}
+	// Request IDs are only unique within a session, which scopes cancellation
+	ctx := withSession(r.Context(), sessionID)
+
+	if !hasRequests {
+		dispatch(ctx, handler, messages, func(int, *JSONRPCResponse) {})
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if acceptsEventStream(r) {
+		t.streamResponses(ctx, w, handler, messages)
+		return
+	}
+
+	// Responses to a batch keep the order of its requests
+	ordered := make([]*JSONRPCResponse, len(messages))
+	dispatch(ctx, handler, messages, func(i int, response *JSONRPCResponse) {
+		ordered[i] = response
+	})
+	var responses []*JSONRPCResponse
+	for _, response := range ordered {
+		if response != nil {
+			responses = append(responses, response)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case batch:
+		_ = json.NewEncoder(w).Encode(responses)
+	case len(responses) == 1:
+		_ = json.NewEncoder(w).Encode(responses[0])
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// streamResponses sends each response, and the notifications sent while
+// handling the requests, as SSE events as soon as they are ready
+func (t *HTTPTransport) streamResponses(ctx context.Context, w http.ResponseWriter, handler Handler, messages []json.RawMessage) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var mu sync.Mutex
+	send := func(message interface{}) {
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to encode message")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	// Notifications about these requests, such as progress, go out on the same stream
+	ctx = withNotifier(ctx, func(notification *JSONRPCNotification) {
+		send(notification)
+	})
+	dispatch(ctx, handler, messages, func(_ int, response *JSONRPCResponse) {
+		send(response)
+	})
+}
+
+// dispatch handles the messages of a batch concurrently and passes each
+// response to emit, one at a time, in completion order along with the index
+// of the message it answers
+func dispatch(ctx context.Context, handler Handler, messages []json.RawMessage, emit func(int, *JSONRPCResponse)) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i, message := range messages {
+		wg.Add(1)
+		go func(i int, message json.RawMessage) {
+			defer wg.Done()
+			if response := handler.HandleMessage(ctx, message); response != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				emit(i, response)
+			}
+		}(i, message)
+	}
+	wg.Wait()
+}
+
+// handleDelete ends the session named in the request
+func (t *HTTPTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if status, msg := t.checkSession(r); status != http.StatusOK {
+		http.Error(w, msg, status)
+		return
+	}
+	t.mu.Lock()
+	delete(t.sessions, r.Header.Get(sessionHeader))
+	t.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createSession registers a new session with a random ID, dropping expired ones
+func (t *HTTPTransport) createSession() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for sessionID, lastSeen := range t.sessions {
+		if now.Sub(lastSeen) > t.options.SessionTimeout {
+			delete(t.sessions, sessionID)
+		}
+	}
+	t.sessions[id] = now
+	return id, nil
+}
+
+// checkSession validates the session header and records activity. It answers
+// 400 when the header is missing and 404 when the session is unknown or
+// expired, which tells clients to initialize again.
+func (t *HTTPTransport) checkSession(r *http.Request) (int, string) {
+	id := r.Header.Get(sessionHeader)
+	if id == "" {
+		return http.StatusBadRequest, "missing " + sessionHeader + " header"
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lastSeen, ok := t.sessions[id]
+	if !ok || time.Since(lastSeen) > t.options.SessionTimeout {
+		delete(t.sessions, id)
+		return http.StatusNotFound, "session not found"
+	}
+	t.sessions[id] = time.Now()
+	return http.StatusOK, ""
+}
+
+// authorized checks the bearer token when one is configured
+func (t *HTTPTransport) authorized(r *http.Request) bool {
+	if t.options.AuthToken == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(t.options.AuthToken)) == 1
+}
+
+// originAllowed accepts requests without an Origin, from the server's own
+// host, or from one of the configured origins
+func (t *HTTPTransport) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range t.options.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// acceptsEventStream reports whether the client can read an SSE response
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream") {
+				return true
+			}
+		}
+	}
+	return false
+}
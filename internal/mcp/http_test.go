@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const initializeRequest = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`
+
+// newHTTPTestServer serves a test MCP server over the HTTP transport
+func newHTTPTestServer(t *testing.T, options HTTPOptions) *httptest.Server {
+	t.Helper()
+	server := newTestServer(t, nil)
+	httpServer := httptest.NewServer(NewHTTPTransport(options).Handler(server))
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func postMCP(t *testing.T, url, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// initializeSession runs initialize and returns the assigned session ID
+func initializeSession(t *testing.T, url string, header map[string]string) string {
+	t.Helper()
+	resp := postMCP(t, url, initializeRequest, header)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want 200", resp.StatusCode)
+	}
+	sessionID := resp.Header.Get(sessionHeader)
+	if sessionID == "" {
+		t.Fatal("initialize did not return a session ID")
+	}
+	return sessionID
+}
+
+func TestHTTPTransport_StreamsResponses(t *testing.T) {
+	httpServer := newHTTPTestServer(t, HTTPOptions{})
+
+	resp := postMCP(t, httpServer.URL, initializeRequest, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	data, ok := strings.CutPrefix(strings.TrimSpace(string(body)), "event: message\ndata: ")
+	if !ok {
+		t.Fatalf("unexpected SSE body: %q", body)
+	}
+	var response struct {
+		Result InitializeResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		t.Fatalf("event data is not JSON: %v", err)
+	}
+	if response.Result.ProtocolVersion != "2025-03-26" {
+		t.Errorf("protocolVersion = %q, want 2025-03-26", response.Result.ProtocolVersion)
+	}
+}
+
+func TestHTTPTransport_Sessions(t *testing.T) {
+	httpServer := newHTTPTestServer(t, HTTPOptions{})
+	sessionID := initializeSession(t, httpServer.URL, nil)
+	toolsList := `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`
+
+	if resp := postMCP(t, httpServer.URL, toolsList, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("without session: status = %d, want 400", resp.StatusCode)
+	}
+	if resp := postMCP(t, httpServer.URL, toolsList, map[string]string{sessionHeader: "unknown"}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want 404", resp.StatusCode)
+	}
+
+	// A notification is accepted without a body
+	notification := `{"jsonrpc":"2.0","method":"notifications/initialized"}`
+	if resp := postMCP(t, httpServer.URL, notification, map[string]string{sessionHeader: sessionID}); resp.StatusCode != http.StatusAccepted {
+		t.Errorf("notification: status = %d, want 202", resp.StatusCode)
+	}
+
+	// Clients that only accept JSON get a plain JSON response
+	req, _ := http.NewRequest(http.MethodPost, httpServer.URL, strings.NewReader(toolsList))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(sessionHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+	var response JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if response.Error != nil || response.Result == nil {
+		t.Errorf("tools/list response = %+v, want a result", response)
+	}
+
+	// Deleting the session ends it
+	req, _ = http.NewRequest(http.MethodDelete, httpServer.URL, nil)
+	req.Header.Set(sessionHeader, sessionID)
+	del, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE error = %v", err)
+	}
+	del.Body.Close()
+	if del.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", del.StatusCode)
+	}
+	if resp := postMCP(t, httpServer.URL, toolsList, map[string]string{sessionHeader: sessionID}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("after DELETE: status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHTTPTransport_Batch(t *testing.T) {
+	httpServer := newHTTPTestServer(t, HTTPOptions{})
+	sessionID := initializeSession(t, httpServer.URL, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, httpServer.URL, strings.NewReader(
+		`[{"jsonrpc":"2.0","id":2,"method":"tools/list"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":3,"method":"nope"}]`))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(sessionHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	var responses []JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		t.Fatalf("response is not a JSON array: %v", err)
+	}
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2", len(responses))
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != -32601 {
+		t.Errorf("second response = %+v, want method not found", responses[1])
+	}
+}
+
+func TestHTTPTransport_AuthAndOrigin(t *testing.T) {
+	httpServer := newHTTPTestServer(t, HTTPOptions{AuthToken: "secret", AllowedOrigins: []string{"https://allowed.example"}})
+
+	if resp := postMCP(t, httpServer.URL, initializeRequest, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d, want 401", resp.StatusCode)
+	}
+	if resp := postMCP(t, httpServer.URL, initializeRequest, map[string]string{"Authorization": "Bearer wrong"}); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want 401", resp.StatusCode)
+	}
+
+	auth := map[string]string{"Authorization": "Bearer secret", "Origin": "https://evil.example"}
+	if resp := postMCP(t, httpServer.URL, initializeRequest, auth); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("foreign origin: status = %d, want 403", resp.StatusCode)
+	}
+	auth["Origin"] = "https://allowed.example"
+	initializeSession(t, httpServer.URL, auth)
+}
+
+func TestHTTPTransport_GetNotAllowed(t *testing.T) {
+	httpServer := newHTTPTestServer(t, HTTPOptions{})
+
+	resp, err := http.Get(httpServer.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", resp.StatusCode)
+	}
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// inflightRequest is a request being processed that a client can still cancel
+type inflightRequest struct {
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// inflight tracks the requests being processed by session and JSON-RPC ID,
+// so that notifications/cancelled can stop the work behind them
+type inflight struct {
+	mu       sync.Mutex
+	requests map[string]*inflightRequest
+}
+
+func newInflight() *inflight {
+	return &inflight{requests: make(map[string]*inflightRequest)}
+}
+
+// requestKey identifies a request within the session carried by ctx. IDs are
+// compared in their JSON form, so 1 and "1" stay distinct.
+func requestKey(ctx context.Context, id interface{}) string {
+	data, _ := json.Marshal(id)
+	return sessionFromContext(ctx) + "\x00" + string(data)
+}
+
+// start registers a request and returns the context it must run with and a
+// function to call once it has finished
+func (f *inflight) start(ctx context.Context, id interface{}) (context.Context, *inflightRequest, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := requestKey(ctx, id)
+	request := &inflightRequest{cancel: cancel}
+
+	f.mu.Lock()
+	f.requests[key] = request
+	f.mu.Unlock()
+
+	return ctx, request, func() {
+		cancel()
+		f.mu.Lock()
+		// A later request may have reused the ID
+		if f.requests[key] == request {
+			delete(f.requests, key)
+		}
+		f.mu.Unlock()
+	}
+}
+
+// cancel stops the request with the given ID; unknown or finished requests are ignored
+func (f *inflight) cancel(ctx context.Context, id interface{}) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	request, ok := f.requests[requestKey(ctx, id)]
+	if !ok {
+		return false
+	}
+	request.cancelled = true
+	request.cancel()
+	return true
+}
+
+// wasCancelled reports whether the client cancelled the request
+func (f *inflight) wasCancelled(request *inflightRequest) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return request.cancelled
+}
+
+// sessionContextKey carries the transport session a message arrived on
+type sessionContextKey struct{}
+
+// withSession records the session a message belongs to
+func withSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, sessionID)
+}
+
+// sessionFromContext returns the session a message belongs to, or "" for
+// transports with a single client
+func sessionFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionContextKey{}).(string)
+	return sessionID
+}
@@ -0,0 +1,73 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// rateLimited answers every BC request with a long Retry-After, so calls only
+// finish when they are cancelled
+func rateLimited(requests chan<- struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- struct{}{}:
+		default:
+		}
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+}
+
+func TestServer_CancelledNotification(t *testing.T) {
+	requests := make(chan struct{}, 1)
+	server := newTestServer(t, rateLimited(requests))
+
+	responses := make(chan *JSONRPCResponse, 1)
+	go func() {
+		responses <- server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers"}}}`))
+	}()
+
+	select {
+	case <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request never reached Business Central")
+	}
+	// The same ID in another session must not be affected
+	if response := server.HandleMessage(withSession(context.Background(), "other"), []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)); response != nil {
+		t.Fatalf("notification got a response: %+v", response)
+	}
+	if response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7,"reason":"user aborted"}}`)); response != nil {
+		t.Fatalf("notification got a response: %+v", response)
+	}
+
+	select {
+	case response := <-responses:
+		if response != nil {
+			t.Errorf("cancelled request got a response: %+v", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request kept running after notifications/cancelled")
+	}
+}
+
+func TestServer_ToolTimeout(t *testing.T) {
+	server := newTestServer(t, rateLimited(nil))
+	server.SetToolTimeout(time.Hour)
+	if err := server.SetToolTimeoutFor("bc_odata_count", 50*time.Millisecond); err != nil {
+		t.Fatalf("SetToolTimeoutFor() error = %v", err)
+	}
+	if err := server.SetToolTimeoutFor("bc_odata_cuont", time.Minute); err == nil {
+		t.Error("SetToolTimeoutFor() of an unknown tool error = nil, want error")
+	}
+
+	start := time.Now()
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_count","arguments":{"endpoint":"Customers"}}}`))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("call returned after %v, want the per-tool deadline to apply", elapsed)
+	}
+	if response == nil || response.Error == nil || response.Error.Message != "Tool call timed out" {
+		t.Fatalf("response = %+v, want a timeout error", response)
+	}
+}
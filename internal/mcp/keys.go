@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+// errMetadataUnavailable signals that key types could not be looked up
+var errMetadataUnavailable = errors.New("metadata unavailable")
+
+// entityQuery addresses a single entity by its canonical URL, e.g.
+// SalesOrders(Document_Type='Order',No='1001'), using the key declared in $metadata.
+// key is either a scalar (single-key entity sets) or an object of key fields.
+func (s *Server) entityQuery(ctx context.Context, endpoint string, key interface{}) (*bc.Query, error) {
+	schema, err := s.loadSchema(ctx)
+	if err != nil {
+		// Without metadata we can still address an entity by an explicit key map,
+		// inferring literal types from the JSON values
+		if keyMap, ok := key.(map[string]interface{}); ok {
+			return inferredEntityQuery(endpoint, keyMap), nil
+		}
+		return nil, fmt.Errorf("%w: %s", errMetadataUnavailable, err.Error())
+	}
+
+	set, ok := schema.EntitySet(endpoint)
+	if !ok {
+		msg := fmt.Sprintf("entity set '%s' not found in metadata", endpoint)
+		if suggestions := metadata.Suggest(endpoint, schema.EntitySetNames(), 5); len(suggestions) > 0 {
+			msg = fmt.Sprintf("%s. Did you mean: %s?", msg, strings.Join(suggestions, ", "))
+		}
+		return nil, errors.New(msg)
+	}
+	et, err := schema.EntityTypeOf(set.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := keyParts(et, key)
+	if err != nil {
+		return nil, err
+	}
+	// Render the key now so invalid literals are reported as invalid params
+	if _, err := bc.EntityKey(parts); err != nil {
+		return nil, err
+	}
+	return bc.NewQuery(set.Name).Key(parts...), nil
+}
+
+// keyedQuery addresses the entity an update, delete or batch operation
+// changes. Without metadata a string key is sent as a string literal, the
+// form Business Central uses for most single-key entity sets.
+func (s *Server) keyedQuery(ctx context.Context, endpoint string, key interface{}) (*bc.Query, error) {
+	query, err := s.entityQuery(ctx, endpoint, key)
+	if errors.Is(err, errMetadataUnavailable) {
+		if key, ok := key.(string); ok {
+			return bc.NewQuery(endpoint).Key(bc.KeyPart{Name: "key", Type: "Edm.String", Value: key}), nil
+		}
+	}
+	return query, err
+}
+
+// keyedEndpoint renders the URL of keyedQuery, which the ETag read, the dry
+// run and the write of an entity all use
+func (s *Server) keyedEndpoint(ctx context.Context, endpoint string, key interface{}) (string, error) {
+	query, err := s.keyedQuery(ctx, endpoint, key)
+	if err != nil {
+		return "", err
+	}
+	return query.Endpoint()
+}
+
+// keyParts matches the supplied key against the key properties of the entity type
+func keyParts(et *metadata.EntityType, key interface{}) ([]bc.KeyPart, error) {
+	keyProps := et.KeyProperties()
+	if len(keyProps) == 0 {
+		return nil, fmt.Errorf("entity type '%s' declares no key", et.QualifiedName)
+	}
+
+	keyMap, isMap := key.(map[string]interface{})
+	if !isMap {
+		if len(keyProps) > 1 {
+			return nil, fmt.Errorf("entity type '%s' has a composite key (%s); pass key as an object with all key fields", et.QualifiedName, strings.Join(et.Key, ","))
+		}
+		return []bc.KeyPart{{Name: keyProps[0].Name, Type: keyProps[0].Type, Value: key}}, nil
+	}
+
+	parts := make([]bc.KeyPart, 0, len(keyProps))
+	used := make(map[string]bool, len(keyMap))
+	var missing []string
+	for _, prop := range keyProps {
+		name, value, ok := lookupKeyField(keyMap, prop.Name)
+		if !ok {
+			missing = append(missing, prop.Name)
+			continue
+		}
+		used[name] = true
+		parts = append(parts, bc.KeyPart{Name: prop.Name, Type: prop.Type, Value: value})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing key field(s) %s for entity type '%s' (key: %s)", strings.Join(missing, ", "), et.QualifiedName, strings.Join(et.Key, ","))
+	}
+
+	var unknown []string
+	for name := range keyMap {
+		if !used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("field(s) %s are not part of the key of '%s' (key: %s)", strings.Join(unknown, ", "), et.QualifiedName, strings.Join(et.Key, ","))
+	}
+
+	return parts, nil
+}
+
+// lookupKeyField finds a key field in the supplied map, tolerating casing differences
+func lookupKeyField(keyMap map[string]interface{}, name string) (string, interface{}, bool) {
+	if value, ok := keyMap[name]; ok {
+		return name, value, true
+	}
+	for k, value := range keyMap {
+		if strings.EqualFold(k, name) {
+			return k, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// inferredEntityQuery builds a key lookup from a key map without metadata,
+// inferring literal types from the JSON values
+func inferredEntityQuery(endpoint string, keyMap map[string]interface{}) *bc.Query {
+	names := make([]string, 0, len(keyMap))
+	for name := range keyMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]bc.KeyPart, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, bc.KeyPart{Name: name, Value: keyMap[name]})
+	}
+	return bc.NewQuery(endpoint).Key(parts...)
+}
+
+// stableOrderBy appends the entity key to the $orderby of query, so $skip
+// pagination sees every record exactly once. Key fields already ordered on are
+// left as they are.
+func (s *Server) stableOrderBy(ctx context.Context, endpoint string, query *bc.Query) error {
+	schema, err := s.loadSchema(ctx)
+	if err != nil {
+		if query.HasOrderBy() {
+			// Trust the caller's ordering when the key cannot be looked up
+			return nil
+		}
+		return fmt.Errorf("%w: $skip paging needs orderby on the entity key", errMetadataUnavailable)
+	}
+	et, err := schema.EntityTypeOf(endpoint)
+	if err != nil {
+		return err
+	}
+
+	ordered := make(map[string]bool)
+	for _, clause := range query.OrderByClauses() {
+		if fields := strings.Fields(clause); len(fields) > 0 {
+			ordered[strings.ToLower(fields[0])] = true
+		}
+	}
+	for _, name := range et.Key {
+		if !ordered[strings.ToLower(name)] {
+			query.OrderBy(name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
+)
+
+const testKeysMetadata = `<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="SalesOrder">
+        <Key><PropertyRef Name="Document_Type" /><PropertyRef Name="No" /></Key>
+        <Property Name="Document_Type" Type="NAV.Document_Type" Nullable="false" />
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+      </EntityType>
+      <EntityType Name="Item">
+        <Key><PropertyRef Name="SystemId" /></Key>
+        <Property Name="SystemId" Type="Edm.Guid" Nullable="false" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="SalesOrders" EntityType="NAV.SalesOrder" />
+        <EntitySet Name="Items" EntityType="NAV.Item" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func TestKeyParts(t *testing.T) {
+	schema, err := metadata.Parse(strings.NewReader(testKeysMetadata))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	salesOrder, _ := schema.EntityTypeOf("SalesOrders")
+
+	parts, err := keyParts(salesOrder, map[string]interface{}{"no": "1001", "Document_Type": "Order"})
+	if err != nil {
+		t.Fatalf("keyParts() error = %v", err)
+	}
+	if len(parts) != 2 || parts[0].Name != "Document_Type" || parts[1].Value != "1001" {
+		t.Errorf("keyParts() = %+v", parts)
+	}
+
+	if _, err := keyParts(salesOrder, "1001"); err == nil || !strings.Contains(err.Error(), "composite key") {
+		t.Errorf("keyParts() scalar on composite key error = %v, want composite key error", err)
+	}
+	if _, err := keyParts(salesOrder, map[string]interface{}{"No": "1001"}); err == nil || !strings.Contains(err.Error(), "Document_Type") {
+		t.Errorf("keyParts() missing field error = %v", err)
+	}
+	if _, err := keyParts(salesOrder, map[string]interface{}{"No": "1", "Document_Type": "Order", "Foo": 1}); err == nil || !strings.Contains(err.Error(), "Foo") {
+		t.Errorf("keyParts() unknown field error = %v", err)
+	}
+}
+
+func TestServer_handleGetEntity_CompositeKey(t *testing.T) {
+	var requested string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testKeysMetadata))
+			return
+		}
+		requested = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"Document_Type":"Order","No":"1001"}`))
+	})
+
+	ctx := context.Background()
+	text := resultText(t, server.handleGetEntity(ctx, 1, map[string]interface{}{
+		"endpoint": "SalesOrders",
+		"key":      map[string]interface{}{"Document_Type": "Order", "No": "1001"},
+	}))
+	if requested != "/SalesOrders(Document_Type='Order',No='1001')" {
+		t.Errorf("requested path = %s", requested)
+	}
+	if !strings.Contains(text, `"No":"1001"`) {
+		t.Errorf("unexpected result: %s", text)
+	}
+
+	text = resultText(t, server.handleGetEntity(ctx, 2, map[string]interface{}{
+		"endpoint": "Items",
+		"key":      "5A3B0C2D-1111-2222-3333-444455556666",
+	}))
+	if requested != "/Items(5a3b0c2d-1111-2222-3333-444455556666)" {
+		t.Errorf("requested path = %s", requested)
+	}
+
+	response := server.handleGetEntity(ctx, 3, map[string]interface{}{"endpoint": "SalesOrders", "key": "1001"})
+	if response.Error == nil || response.Error.Code != -32602 {
+		t.Errorf("expected invalid params for scalar composite key, got %#v", response.Error)
+	}
+}
+
+func TestServer_handleGetEntity_NotFound(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testKeysMetadata))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":"BadRequest_NotFound","message":"The record does not exist."}}`))
+	})
+
+	response := server.handleGetEntity(context.Background(), 1, map[string]interface{}{
+		"endpoint": "Items",
+		"key":      "5a3b0c2d-1111-2222-3333-444455556666",
+	})
+	if response.Error == nil || response.Error.Code != -32001 {
+		t.Errorf("expected entity not found, got %#v", response.Error)
+	}
+}
+
+func TestServer_WriteCompositeKey(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testKeysMetadata))
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"@odata.etag":"W/\"1\"","Document_Type":"Order","No":"1001"}`))
+	})
+	ctx := context.Background()
+	args := map[string]interface{}{
+		"endpoint": "SalesOrders",
+		"key":      map[string]interface{}{"Document_Type": "Order", "No": "1001"},
+		"data":     map[string]interface{}{"No": "1001"},
+	}
+
+	// The dry run reads the record the update writes, which its ETag comes from
+	_ = resultText(t, server.previewWrite(ctx, 1, "bc_odata_update", args))
+	_ = resultText(t, server.handleUpdate(ctx, 2, args))
+	const path = "/SalesOrders(Document_Type='Order',No='1001')"
+	want := []string{"GET " + path, "GET " + path, "PATCH " + path}
+	if strings.Join(requests, "|") != strings.Join(want, "|") {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+
+	response := server.handleDelete(ctx, 3, map[string]interface{}{"endpoint": "SalesOrders", "key": "1001"})
+	if response.Error == nil || response.Error.Code != -32602 {
+		t.Errorf("delete with a scalar composite key = %#v, want invalid params", response.Error)
+	}
+}
+
+func TestServer_WriteKeyWithoutMetadata(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			http.NotFound(w, r)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"@odata.etag":"W/\"1\"","No":"O'Brien"}`))
+	})
+
+	// A string key falls back to a quoted literal, escaped
+	_ = resultText(t, server.handleDelete(context.Background(), 1, map[string]interface{}{"endpoint": "Customers", "key": "O'Brien"}))
+	if len(requests) != 2 || requests[1] != "DELETE /Customers('O''Brien')" {
+		t.Errorf("requests = %v, want the escaped key", requests)
+	}
+}
@@ -0,0 +1,194 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Write operations governed by entity set permissions
+const (
+	operationCreate = "create"
+	operationUpdate = "update"
+	operationDelete = "delete"
+)
+
+// writeOperations maps the write tools to the operation they perform
+var writeOperations = map[string]string{
+	"bc_odata_create": operationCreate,
+	"bc_odata_update": operationUpdate,
+	"bc_odata_delete": operationDelete,
+}
+
+// anyEntitySet names the permissions applied to entity sets without their own
+const anyEntitySet = "*"
+
+// Policy restricts what connected agents may do. The zero value allows everything.
+type Policy struct {
+	// ReadOnly hides and rejects every write tool
+	ReadOnly bool
+	// AllowTools, when not empty, lists the only tools offered to clients
+	AllowTools []string
+	// DenyTools lists tools that are never offered, even when allowed
+	DenyTools []string
+	// EntitySets sets the write permissions of entity sets by name; the "*"
+	// entry applies to entity sets that are not listed
+	EntitySets map[string]EntitySetPermissions
+}
+
+// EntitySetPermissions lists the write operations (create, update, delete, or
+// the HTTP methods POST, PATCH, DELETE) permitted on an entity set
+type EntitySetPermissions struct {
+	// Allow, when not empty, lists the only permitted operations
+	Allow []string
+	// Deny lists operations that are never permitted
+	Deny []string
+	// RequireConfirmation only executes writes previewed with dry_run, whose
+	// confirmation token is passed back
+	RequireConfirmation bool
+}
+
+// policy is the validated form of Policy
+type policy struct {
+	readOnly   bool
+	allow      map[string]bool // nil allows every tool
+	deny       map[string]bool
+	entitySets map[string]operationRule // keyed by lower-case entity set name
+}
+
+// operationRule holds the normalized operations of EntitySetPermissions
+type operationRule struct {
+	allow   map[string]bool // nil allows every operation
+	deny    map[string]bool
+	confirm bool
+}
+
+// SetPolicy sets which tools and write operations clients may use. It must
+// be called before Serve.
+func (s *Server) SetPolicy(p Policy) error {
+	known := make(map[string]bool)
+	for _, tool := range toolDefinitions() {
+		known[tool.Name] = true
+	}
+	var problems []string
+	toolSet := func(names []string) map[string]bool {
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			if !known[name] {
+				problems = append(problems, fmt.Sprintf("unknown tool '%s'", name))
+			}
+			set[name] = true
+		}
+		return set
+	}
+	operationSet := func(entitySet string, operations []string) map[string]bool {
+		set := make(map[string]bool, len(operations))
+		for _, operation := range operations {
+			normalized, ok := normalizeOperation(operation)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("unknown operation '%s' for entity set '%s' (use create, update or delete)", operation, entitySet))
+			}
+			set[normalized] = true
+		}
+		return set
+	}
+
+	compiled := &policy{
+		readOnly:   p.ReadOnly,
+		deny:       toolSet(p.DenyTools),
+		entitySets: make(map[string]operationRule, len(p.EntitySets)),
+	}
+	if len(p.AllowTools) > 0 {
+		compiled.allow = toolSet(p.AllowTools)
+	}
+	for name, permissions := range p.EntitySets {
+		rule := operationRule{deny: operationSet(name, permissions.Deny), confirm: permissions.RequireConfirmation}
+		if len(permissions.Allow) > 0 {
+			rule.allow = operationSet(name, permissions.Allow)
+		}
+		compiled.entitySets[strings.ToLower(name)] = rule
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid policy: %s", strings.Join(problems, "; "))
+	}
+
+	s.policy = compiled
+	return nil
+}
+
+// normalizeOperation maps an operation or HTTP method to create, update or delete
+func normalizeOperation(operation string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(operation)) {
+	case operationCreate, "post":
+		return operationCreate, true
+	case operationUpdate, "patch", "put":
+		return operationUpdate, true
+	case operationDelete:
+		return operationDelete, true
+	}
+	return operation, false
+}
+
+// toolAllowed reports whether clients may list and call a tool. A write tool
+// is hidden in read-only mode and when no entity set permits its operation.
+func (p *policy) toolAllowed(name string) bool {
+	if p.deny[name] || p.allow != nil && !p.allow[name] {
+		return false
+	}
+	if operation, ok := writeOperations[name]; ok {
+		return !p.readOnly && p.operationPossible(operation)
+	}
+	return true
+}
+
+// operationPossible reports whether some entity set permits an operation
+func (p *policy) operationPossible(operation string) bool {
+	if p.rule(anyEntitySet).permits(operation) {
+		return true
+	}
+	for _, rule := range p.entitySets {
+		if rule.permits(operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWrite returns an error unless the operation is permitted on the entity set
+func (p *policy) checkWrite(entitySet, operation string) error {
+	if p.readOnly {
+		return fmt.Errorf("the server is in read-only mode")
+	}
+	if !p.rule(entitySet).permits(operation) {
+		return fmt.Errorf("%s is not permitted on entity set '%s'", operation, entitySet)
+	}
+	return nil
+}
+
+// confirmationRequired reports whether writes to the entity set need a dry run first
+func (p *policy) confirmationRequired(entitySet string) bool {
+	return p.rule(entitySet).confirm
+}
+
+// rule returns the permissions of an entity set, falling back to "*"
+func (p *policy) rule(entitySet string) operationRule {
+	if rule, ok := p.entitySets[strings.ToLower(entitySet)]; ok {
+		return rule
+	}
+	return p.entitySets[anyEntitySet]
+}
+
+func (r operationRule) permits(operation string) bool {
+	return !r.deny[operation] && (r.allow == nil || r.allow[operation])
+}
+
+// entitySetOf returns the entity set addressed by an endpoint such as
+// SalesOrders, SalesOrders('1001') or SalesOrders?$filter=...
+func entitySetOf(endpoint string) string {
+	endpoint = strings.Trim(endpoint, "/")
+	if i := strings.IndexAny(endpoint, "(?/"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	return endpoint
+}
@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// listedTools returns the names of the tools offered by tools/list
+func listedTools(t *testing.T, server *Server) map[string]bool {
+	t.Helper()
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	list, ok := response.Result.(ToolsListResult)
+	if !ok {
+		t.Fatalf("tools/list = %+v", response)
+	}
+	names := make(map[string]bool, len(list.Tools))
+	for _, tool := range list.Tools {
+		names[tool.Name] = true
+	}
+	return names
+}
+
+func TestServer_ReadOnlyPolicy(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	})
+	if err := server.SetPolicy(Policy{ReadOnly: true}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+
+	tools := listedTools(t, server)
+	if tools["bc_odata_create"] || tools["bc_odata_update"] || tools["bc_odata_delete"] || !tools["bc_odata_query"] {
+		t.Errorf("tools = %v, want read tools only", tools)
+	}
+
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"bc_odata_delete","arguments":{"endpoint":"Customers","key":"10000"}}}`))
+	if response.Error == nil || response.Error.Code != -32601 {
+		t.Errorf("delete in read-only mode = %+v, want tool not allowed", response)
+	}
+}
+
+func TestServer_EntitySetPolicy(t *testing.T) {
+	var requests []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			http.NotFound(w, r)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"No":"1001"}`))
+	})
+	err := server.SetPolicy(Policy{
+		DenyTools: []string{"bc_odata_check_order_status"},
+		EntitySets: map[string]EntitySetPermissions{
+			"SalesOrders": {Allow: []string{"PATCH"}},
+			"Customers":   {Deny: []string{"delete"}},
+			"*":           {Allow: []string{"update"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+
+	tools := listedTools(t, server)
+	// No entity set permits delete, Customers still permits create
+	if tools["bc_odata_delete"] || tools["bc_odata_check_order_status"] || !tools["bc_odata_update"] || !tools["bc_odata_create"] {
+		t.Errorf("tools = %v, want delete and the denied tool hidden", tools)
+	}
+
+	call := func(name, arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"`+name+`","arguments":`+arguments+`}}`))
+	}
+	if response := call("bc_odata_update", `{"endpoint":"SalesOrders","key":"1001","data":{"Status":"Open"}}`); response.Error != nil {
+		t.Errorf("update SalesOrders = %+v, want success", response.Error)
+	}
+	for _, denied := range []struct{ name, arguments string }{
+		{"bc_odata_delete", `{"endpoint":"Customers","key":"10000"}`},
+		{"bc_odata_create", `{"endpoint":"Items","data":{"No":"1"}}`},
+		{"bc_odata_create", `{"endpoint":"salesorders","data":{"No":"1002"}}`},
+	} {
+		if response := call(denied.name, denied.arguments); response.Error == nil {
+			t.Errorf("%s %s succeeded, want it denied", denied.name, denied.arguments)
+		}
+	}
+	// The update reads the current ETag before patching
+	if len(requests) != 2 || requests[1] != "PATCH /SalesOrders('1001')" {
+		t.Errorf("requests = %v, want only the permitted update", requests)
+	}
+}
+
+func TestServer_SetPolicyValidation(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	err := server.SetPolicy(Policy{
+		AllowTools: []string{"bc_odata_querry"},
+		EntitySets: map[string]EntitySetPermissions{"Customers": {Deny: []string{"truncate"}}},
+	})
+	if err == nil {
+		t.Fatal("SetPolicy() error = nil, want unknown tool and operation")
+	}
+	if !listedTools(t, server)["bc_odata_delete"] {
+		t.Error("an invalid policy must leave the previous one in place")
+	}
+}
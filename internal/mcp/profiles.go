@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+// defaultProfileName names the connection built from the configuration passed to NewServer
+const defaultProfileName = "default"
+
+// profile is a named Business Central connection with its own credentials,
+// tenant, environment and default company
+type profile struct {
+	name   string
+	config bc.Config
+	auth   *bc.Auth
+	client *bc.Client
+}
+
+// newProfile creates the authentication handler and client of a connection
+func newProfile(name string, cfg bc.Config) *profile {
+	auth := bc.NewAuth(cfg)
+	return &profile{
+		name:   name,
+		config: cfg,
+		auth:   auth,
+		client: bc.NewClient(cfg, auth),
+	}
+}
+
+// schemaKey identifies the service whose $metadata is cached. The base path
+// is part of it: profiles of one environment can point at different services,
+// such as ODataV4 web services and an API page group.
+func (p *profile) schemaKey() string {
+	return p.config.TenantID + "/" + p.config.Environment + " " + p.config.BasePath
+}
+
+// profileArgSchema and environmentArgSchema select the connection of a tool call
+var (
+	profileArgSchema = map[string]interface{}{
+		"type":        "string",
+		"description": "Connection profile to use (defaults to 'default'). Use bc_odata_list_profiles to see the configured profiles.",
+	}
+	environmentArgSchema = map[string]interface{}{
+		"type":        "string",
+		"description": "Business Central environment to use, e.g. Sandbox, when exactly one profile connects to it. Alternative to profile.",
+	}
+)
+
+// AddProfile registers a named connection that tool calls can select with the
+// profile argument. It must be called before Serve.
+func (s *Server) AddProfile(name string, cfg bc.Config) error {
+	if name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if _, exists := s.profiles[name]; exists {
+		return fmt.Errorf("profile '%s' is already configured", name)
+	}
+	s.profiles[name] = newProfile(name, cfg)
+	return nil
+}
+
+type profileContextKey struct{}
+
+// profileFor returns the profile selected for the current tool call, or the default profile
+func (s *Server) profileFor(ctx context.Context) *profile {
+	if p, ok := ctx.Value(profileContextKey{}).(*profile); ok {
+		return p
+	}
+	return s.profiles[defaultProfileName]
+}
+
+// selectProfile returns ctx carrying the profile named by the profile or
+// environment argument; without either ctx stays on the default profile
+func (s *Server) selectProfile(ctx context.Context, name, environment string) (context.Context, error) {
+	switch {
+	case name != "":
+		p, ok := s.profiles[name]
+		if !ok {
+			return ctx, fmt.Errorf("unknown profile '%s'", name)
+		}
+		if environment != "" && !strings.EqualFold(p.config.Environment, environment) {
+			return ctx, fmt.Errorf("profile '%s' connects to environment '%s', not '%s'", name, p.config.Environment, environment)
+		}
+		return context.WithValue(ctx, profileContextKey{}, p), nil
+	case environment != "":
+		var matches []*profile
+		for _, p := range s.profiles {
+			if strings.EqualFold(p.config.Environment, environment) {
+				matches = append(matches, p)
+			}
+		}
+		if len(matches) != 1 {
+			return ctx, fmt.Errorf("%d profiles connect to environment '%s'; select one with the profile argument", len(matches), environment)
+		}
+		return context.WithValue(ctx, profileContextKey{}, matches[0]), nil
+	}
+	return ctx, nil
+}
+
+// handleListProfiles lists the configured connections, without their credentials
+func (s *Server) handleListProfiles(id interface{}) *JSONRPCResponse {
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	profiles := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		p := s.profiles[name]
+		profiles = append(profiles, map[string]interface{}{
+			"name":        p.name,
+			"tenant_id":   p.config.TenantID,
+			"environment": p.config.Environment,
+			"company":     p.client.Company(),
+			"base_path":   p.config.BasePath,
+			"default":     name == defaultProfileName,
+		})
+	}
+
+	resultJSON, _ := json.Marshal(map[string]interface{}{
+		"profiles": profiles,
+		"count":    len(profiles),
+	})
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		},
+	}
+}
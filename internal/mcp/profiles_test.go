@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+func TestServer_Profiles(t *testing.T) {
+	var production, sandbox int
+	server := newTestServerWithConfig(t, func(w http.ResponseWriter, r *http.Request) {
+		production++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"No":"10000","Name":"Production"}]}`))
+	}, func(cfg *bc.Config) {
+		cfg.Environment = "Production"
+	})
+
+	sandboxServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sandbox++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"No":"10000","Name":"Sandbox"}]}`))
+	}))
+	t.Cleanup(sandboxServer.Close)
+	cfg := server.config
+	cfg.Environment = "Sandbox"
+	cfg.BasePath = sandboxServer.URL + "/"
+	if err := server.AddProfile("sandbox", cfg); err != nil {
+		t.Fatalf("AddProfile() error = %v", err)
+	}
+	if err := server.AddProfile("sandbox", cfg); err == nil {
+		t.Error("AddProfile() with a duplicate name error = nil, want error")
+	}
+
+	call := func(name, arguments string) *JSONRPCResponse {
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+name+`","arguments":`+arguments+`}}`))
+	}
+
+	if text := resultText(t, call("bc_odata_query", `{"endpoint":"Customers"}`)); !strings.Contains(text, "Production") {
+		t.Errorf("default profile result = %s", text)
+	}
+	if text := resultText(t, call("bc_odata_query", `{"endpoint":"Customers","profile":"sandbox"}`)); !strings.Contains(text, "Sandbox") {
+		t.Errorf("sandbox profile result = %s", text)
+	}
+	if text := resultText(t, call("bc_odata_query", `{"endpoint":"Customers","environment":"sandbox"}`)); !strings.Contains(text, "Sandbox") {
+		t.Errorf("sandbox environment result = %s", text)
+	}
+	if production != 1 || sandbox != 2 {
+		t.Errorf("requests: production %d, sandbox %d; want 1 and 2", production, sandbox)
+	}
+
+	for _, arguments := range []string{
+		`{"endpoint":"Customers","profile":"missing"}`,
+		`{"endpoint":"Customers","environment":"Test"}`,
+		`{"endpoint":"Customers","profile":"sandbox","environment":"Production"}`,
+	} {
+		if response := call("bc_odata_query", arguments); response.Error == nil || response.Error.Code != -32602 {
+			t.Errorf("%s: response = %+v, want invalid params", arguments, response)
+		}
+	}
+
+	text := resultText(t, call("bc_odata_list_profiles", `{}`))
+	if !strings.Contains(text, `"count":2`) || !strings.Contains(text, `"environment":"Sandbox"`) || strings.Contains(text, "test-client-secret") {
+		t.Errorf("list profiles = %s", text)
+	}
+}
+
+func TestProfile_SchemaKey(t *testing.T) {
+	webServices := newProfile("web", bc.Config{TenantID: "tenant", Environment: "Production", BasePath: "https://api.businesscentral.dynamics.com/v2.0/tenant/Production/ODataV4/"})
+	apiPages := newProfile("api", bc.Config{TenantID: "tenant", Environment: "Production", BasePath: "https://api.businesscentral.dynamics.com/v2.0/tenant/Production/api/v2.0/"})
+	if webServices.schemaKey() == apiPages.schemaKey() {
+		t.Errorf("schemaKey() = %q for both services, want distinct keys", webServices.schemaKey())
+	}
+}
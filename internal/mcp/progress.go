@@ -0,0 +1,56 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+// notifyFunc sends a server-initiated notification to the client that made the
+// request being handled
+type notifyFunc func(*JSONRPCNotification)
+
+type notifierContextKey struct{}
+
+// withNotifier lets handlers running under ctx send notifications to the client.
+// Transports that cannot deliver them leave the context without a notifier.
+func withNotifier(ctx context.Context, notify notifyFunc) context.Context {
+	return context.WithValue(ctx, notifierContextKey{}, notify)
+}
+
+// notifierFromContext returns the notifier for the current request, or nil
+func notifierFromContext(ctx context.Context) notifyFunc {
+	notify, _ := ctx.Value(notifierContextKey{}).(notifyFunc)
+	return notify
+}
+
+type progressTokenContextKey struct{}
+
+// withProgressToken records the progressToken the client sent in _meta
+func withProgressToken(ctx context.Context, token interface{}) context.Context {
+	return context.WithValue(ctx, progressTokenContextKey{}, token)
+}
+
+// pageProgress returns a callback reporting each fetched page as a
+// notifications/progress message, or nil when the client did not ask for
+// progress or the transport cannot deliver it
+func pageProgress(ctx context.Context) func(bc.Progress) {
+	token := ctx.Value(progressTokenContextKey{})
+	notify := notifierFromContext(ctx)
+	if token == nil || notify == nil {
+		return nil
+	}
+	return func(p bc.Progress) {
+		// The total is unknown until the last page, so only records so far are reported
+		notify(&JSONRPCNotification{
+			JSONRPC: "2.0",
+			Method:  "notifications/progress",
+			Params: ProgressParams{
+				ProgressToken: token,
+				Progress:      float64(p.Records),
+				Message:       fmt.Sprintf("Fetched page %d, %d records so far", p.Page, p.Records),
+			},
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStdioTransport_ProgressNotifications(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("$skiptoken") == "" {
+			_, _ = w.Write([]byte(`{"value":[{"No":"1"},{"No":"2"}],"@odata.nextLink":"http://` + r.Host + `/Customers?$skiptoken=2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"value":[{"No":"3"}]}`))
+	})
+
+	input := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers","paginate":true},"_meta":{"progressToken":"tok"}}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers","paginate":true}}}` + "\n"
+	var out bytes.Buffer
+	if err := NewStdioTransport(strings.NewReader(input), &out).Serve(context.Background(), server); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	var progress []ProgressParams
+	responses := 0
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var message struct {
+			Method string         `json:"method"`
+			Params ProgressParams `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("invalid message %q: %v", line, err)
+		}
+		switch message.Method {
+		case "notifications/progress":
+			progress = append(progress, message.Params)
+		case "":
+			responses++
+		default:
+			t.Errorf("unexpected message %s", line)
+		}
+	}
+
+	// Only the call that sent a progressToken gets notifications, one per page
+	if responses != 2 {
+		t.Errorf("got %d responses, want 2", responses)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("got %d progress notifications, want 2:\n%s", len(progress), out.String())
+	}
+	if progress[0].ProgressToken != "tok" || progress[0].Progress != 2 || progress[1].Progress != 3 {
+		t.Errorf("progress = %+v, want token tok with 2 then 3 records", progress)
+	}
+}
@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// promptTemplate is a prompt offered through prompts/list. Its text is a Go
+// template rendered with the prompt arguments, e.g. {{.order_no}}.
+type promptTemplate struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Arguments   []PromptArgument `yaml:"arguments"`
+	Template    string           `yaml:"template"`
+
+	parsed *template.Template
+}
+
+// promptFile is the layout of a prompts file
+type promptFile struct {
+	Prompts []*promptTemplate `yaml:"prompts"`
+}
+
+// builtinPrompts covers the requests users make most often
+var builtinPrompts = []*promptTemplate{
+	{
+		Name:        "check_order_status",
+		Description: "Check whether a sales order has been invoiced",
+		Arguments: []PromptArgument{
+			{Name: "order_no", Description: "The sales order number", Required: true},
+		},
+		Template: `Check the status of sales order {{.order_no}} in Business Central.
+
+Call bc_odata_check_order_status with order_no "{{.order_no}}". Report whether the order is still open, invoiced or not found, and summarize the order or invoice data it returns (customer, dates, amounts). If it is not found, suggest how the order number could be checked.`,
+	},
+	{
+		Name:        "customer_aging",
+		Description: "Summarize the open receivables of customers by due date",
+		Arguments: []PromptArgument{
+			{Name: "customer_no", Description: "Limit the summary to one customer (optional)"},
+			{Name: "as_of", Description: "Reference date in YYYY-MM-DD format (optional, defaults to today)"},
+		},
+		Template: `Summarize customer aging{{if .customer_no}} for customer {{.customer_no}}{{end}} as of {{if .as_of}}{{.as_of}}{{else}}today{{end}}.
+
+1. Use bc_odata_list_endpoints with search "Ledger" to find the customer ledger entries endpoint, and bc_odata_get_metadata to find its customer, due date, open and remaining amount fields.
+2. Use bc_odata_aggregate to sum the remaining amount of open entries{{if .customer_no}} of customer {{.customer_no}}{{else}} grouped by customer{{end}}, once per bucket with a filter on the due date: not yet due, 1-30, 31-60, 61-90 and over 90 days overdue.
+3. Present the buckets as a table with totals, and point out the largest overdue amounts.`,
+	},
+	{
+		Name:        "overdue_invoices",
+		Description: "Find the overdue invoices of a customer",
+		Arguments: []PromptArgument{
+			{Name: "customer_no", Description: "The customer number", Required: true},
+			{Name: "as_of", Description: "Reference date in YYYY-MM-DD format (optional, defaults to today)"},
+		},
+		Template: `Find the overdue invoices of customer {{.customer_no}} as of {{if .as_of}}{{.as_of}}{{else}}today{{end}}.
+
+1. Use bc_odata_get_metadata on the invoices or customer ledger entries endpoint to find the customer, due date and remaining amount fields.
+2. Use bc_odata_query with a filter tree selecting customer {{.customer_no}}, a due date before {{if .as_of}}{{.as_of}}{{else}}today{{end}} and a remaining amount greater than 0, ordered by due date.
+3. Use bc_odata_count with the same filter to report how many there are, then list each invoice with number, due date, days overdue and remaining amount, and the total.`,
+	},
+}
+
+// promptSet holds the prompts offered by the server, by name
+type promptSet struct {
+	prompts map[string]*promptTemplate
+}
+
+// newPromptSet returns the built-in prompts
+func newPromptSet() *promptSet {
+	set := &promptSet{prompts: make(map[string]*promptTemplate)}
+	for _, builtin := range builtinPrompts {
+		prompt := *builtin
+		if err := set.add(&prompt); err != nil {
+			panic(fmt.Sprintf("invalid built-in prompt: %v", err))
+		}
+	}
+	return set
+}
+
+// add validates a prompt and registers it, replacing any prompt with the same name
+func (p *promptSet) add(prompt *promptTemplate) error {
+	if prompt.Name == "" {
+		return fmt.Errorf("prompt without a name")
+	}
+	if strings.TrimSpace(prompt.Template) == "" {
+		return fmt.Errorf("prompt '%s' has no template", prompt.Name)
+	}
+	seen := make(map[string]bool, len(prompt.Arguments))
+	for _, arg := range prompt.Arguments {
+		if arg.Name == "" || seen[arg.Name] {
+			return fmt.Errorf("prompt '%s' has an unnamed or duplicate argument", prompt.Name)
+		}
+		seen[arg.Name] = true
+	}
+	parsed, err := template.New(prompt.Name).Option("missingkey=error").Parse(prompt.Template)
+	if err != nil {
+		return fmt.Errorf("prompt '%s': %w", prompt.Name, err)
+	}
+	prompt.parsed = parsed
+	p.prompts[prompt.Name] = prompt
+	return nil
+}
+
+// loadFile adds the prompts of a YAML (or JSON) file, overriding built-in
+// prompts with the same name
+func (p *promptSet) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read prompts file: %w", err)
+	}
+	var file promptFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse prompts file %s: %w", path, err)
+	}
+	for _, prompt := range file.Prompts {
+		if err := p.add(prompt); err != nil {
+			return fmt.Errorf("invalid prompt in %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// list returns the prompts sorted by name
+func (p *promptSet) list() []Prompt {
+	prompts := make([]Prompt, 0, len(p.prompts))
+	for _, prompt := range p.prompts {
+		prompts = append(prompts, Prompt{
+			Name:        prompt.Name,
+			Description: prompt.Description,
+			Arguments:   prompt.Arguments,
+		})
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	return prompts
+}
+
+// render fills in a prompt's template. Declared arguments that were not
+// supplied render as empty strings; unknown arguments are rejected.
+func (prompt *promptTemplate) render(args map[string]string) (string, error) {
+	values := make(map[string]string, len(prompt.Arguments))
+	var missing []string
+	for _, arg := range prompt.Arguments {
+		value := strings.TrimSpace(args[arg.Name])
+		if arg.Required && value == "" {
+			missing = append(missing, arg.Name)
+		}
+		values[arg.Name] = value
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing required argument(s) %s", strings.Join(missing, ", "))
+	}
+	for name := range args {
+		if _, ok := values[name]; !ok {
+			return "", fmt.Errorf("unknown argument '%s'", name)
+		}
+	}
+
+	var text bytes.Buffer
+	if err := prompt.parsed.Execute(&text, values); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// LoadPrompts adds the prompts defined in a YAML or JSON file to the built-in
+// ones. It must be called before Serve.
+func (s *Server) LoadPrompts(path string) error {
+	return s.prompts.loadFile(path)
+}
+
+// handlePromptsList returns the available prompts
+func (s *Server) handlePromptsList(request *JSONRPCRequest) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: PromptsListResult{
+			Prompts: s.prompts.list(),
+		},
+	}
+}
+
+// handlePromptsGet renders a prompt with the supplied arguments
+func (s *Server) handlePromptsGet(request *JSONRPCRequest) *JSONRPCResponse {
+	var params GetPromptParams
+	if err := json.Unmarshal(request.Params, &params); err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	prompt, ok := s.prompts.prompts[params.Name]
+	if !ok {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: prompt not found",
+				Data:    fmt.Sprintf("No prompt named '%s'", params.Name),
+			},
+		}
+	}
+
+	text, err := prompt.render(params.Arguments)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid prompt arguments",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: GetPromptResult{
+			Description: prompt.Description,
+			Messages: []PromptMessage{
+				{
+					Role: "user",
+					Content: Content{
+						Type: "text",
+						Text: text,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer_Prompts(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`))
+	list, ok := response.Result.(PromptsListResult)
+	if !ok || len(list.Prompts) != len(builtinPrompts) {
+		t.Fatalf("prompts/list = %+v, want the built-in prompts", response)
+	}
+
+	response = server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"check_order_status","arguments":{"order_no":"SO-1001"}}}`))
+	result, ok := response.Result.(GetPromptResult)
+	if !ok || len(result.Messages) != 1 {
+		t.Fatalf("prompts/get = %+v", response)
+	}
+	if text := result.Messages[0].Content.Text; !strings.Contains(text, `bc_odata_check_order_status with order_no "SO-1001"`) {
+		t.Errorf("prompt text = %q", text)
+	}
+
+	for _, params := range []string{
+		`{"name":"check_order_status"}`,
+		`{"name":"check_order_status","arguments":{"order_no":"1","extra":"x"}}`,
+		`{"name":"nope"}`,
+	} {
+		response = server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":`+params+`}`))
+		if response.Error == nil || response.Error.Code != -32602 {
+			t.Errorf("prompts/get %s = %+v, want invalid params", params, response)
+		}
+	}
+}
+
+func TestServer_LoadPrompts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompts.yaml")
+	content := `prompts:
+  - name: vendor_balance
+    description: Show the balance of a vendor
+    arguments:
+      - name: vendor_no
+        required: true
+    template: Use bc_odata_get_entity on Vendors with key {{.vendor_no}} and report its balance.
+  - name: check_order_status
+    arguments:
+      - name: order_no
+        required: true
+    template: Custom check for {{.order_no}}.
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newTestServer(t, nil)
+	if err := server.LoadPrompts(path); err != nil {
+		t.Fatalf("LoadPrompts() error = %v", err)
+	}
+	if len(server.prompts.list()) != len(builtinPrompts)+1 {
+		t.Errorf("got %d prompts, want the built-in ones plus vendor_balance", len(server.prompts.list()))
+	}
+	text, err := server.prompts.prompts["check_order_status"].render(map[string]string{"order_no": "42"})
+	if err != nil || text != "Custom check for 42." {
+		t.Errorf("overridden prompt = %q, %v", text, err)
+	}
+
+	if err := os.WriteFile(path, []byte("prompts:\n  - name: broken\n    template: \"{{.x\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.LoadPrompts(path); err == nil {
+		t.Error("LoadPrompts() error = nil for an invalid template")
+	}
+}
@@ -0,0 +1,315 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/rs/zerolog/log"
+)
+
+// resourceScheme prefixes the URIs of Business Central resources:
+// bc://{company}/{entitySet} and bc://{company}/{entitySet}({key})
+const resourceScheme = "bc://"
+
+// resourceSampleSize is the number of records included when reading an entity set
+const resourceSampleSize = 5
+
+// defaultCompany names the company in resource URIs when BC_COMPANY is not set
+const defaultCompany = "default"
+
+// resourceRef is a parsed resource URI
+type resourceRef struct {
+	Company   string
+	EntitySet string
+	Key       interface{} // nil for the entity set itself
+}
+
+// resourceCompany returns the company segment of the server's resource URIs
+func (s *Server) resourceCompany() string {
+	if company := s.client.Company(); company != "" {
+		return company
+	}
+	return defaultCompany
+}
+
+// entitySetURI returns the resource URI of an entity set
+func entitySetURI(company, entitySet string) string {
+	return resourceScheme + url.PathEscape(company) + "/" + url.PathEscape(entitySet)
+}
+
+// parseResourceURI splits a bc:// URI into company, entity set and optional key
+func parseResourceURI(uri string) (resourceRef, error) {
+	var ref resourceRef
+	rest, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return ref, fmt.Errorf("resource URI must start with %s", resourceScheme)
+	}
+	company, path, ok := strings.Cut(rest, "/")
+	if !ok || company == "" || path == "" {
+		return ref, fmt.Errorf("resource URI must look like %s{company}/{entitySet}", resourceScheme)
+	}
+	var err error
+	if ref.Company, err = url.PathUnescape(company); err != nil {
+		return ref, fmt.Errorf("invalid company in resource URI: %w", err)
+	}
+	if path, err = url.PathUnescape(path); err != nil {
+		return ref, fmt.Errorf("invalid entity set in resource URI: %w", err)
+	}
+
+	name, predicate, hasKey := strings.Cut(path, "(")
+	if strings.ContainsAny(name, "/?#") || name == "" {
+		return ref, fmt.Errorf("invalid entity set '%s' in resource URI", name)
+	}
+	ref.EntitySet = name
+	if hasKey {
+		predicate, ok = strings.CutSuffix(predicate, ")")
+		if !ok || predicate == "" {
+			return ref, fmt.Errorf("invalid key predicate in resource URI")
+		}
+		if ref.Key, err = parseKeyPredicate(predicate); err != nil {
+			return ref, err
+		}
+	}
+	return ref, nil
+}
+
+// parseKeyPredicate reads the inside of an OData key predicate: a single
+// value such as '10000', or Name=value pairs for composite keys. Values are
+// returned as strings; their types come from $metadata when the key is resolved.
+func parseKeyPredicate(predicate string) (interface{}, error) {
+	segments, err := splitKeySegments(predicate)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 1 && !strings.Contains(unquotedPrefix(segments[0]), "=") {
+		return keyLiteral(segments[0]), nil
+	}
+
+	key := make(map[string]interface{}, len(segments))
+	for _, segment := range segments {
+		name, value, ok := strings.Cut(segment, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid key segment '%s' in resource URI", segment)
+		}
+		key[name] = keyLiteral(value)
+	}
+	return key, nil
+}
+
+// splitKeySegments splits a key predicate on the commas outside string literals
+func splitKeySegments(predicate string) ([]string, error) {
+	var segments []string
+	start, quoted := 0, false
+	for i := 0; i < len(predicate); i++ {
+		switch predicate[i] {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				segments = append(segments, predicate[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated string in key predicate")
+	}
+	return append(segments, predicate[start:]), nil
+}
+
+// unquotedPrefix returns segment up to its first string literal
+func unquotedPrefix(segment string) string {
+	prefix, _, _ := strings.Cut(segment, "'")
+	return prefix
+}
+
+// keyLiteral unquotes an OData string literal; other literals are kept as written
+func keyLiteral(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+// handleResourcesList lists the published entity sets as resources, from the
+// service document or, when it cannot be read, from $metadata
+func (s *Server) handleResourcesList(ctx context.Context, request *JSONRPCRequest) *JSONRPCResponse {
+	var names []string
+	entries, err := s.clientFor(ctx).ServiceDocument(ctx)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.Kind == "EntitySet" {
+				names = append(names, entry.Name)
+			}
+		}
+	} else {
+		log.Warn().Err(err).Msg("Could not read OData service document, listing resources from metadata")
+		schema, schemaErr := s.loadSchema(ctx)
+		if schemaErr != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      request.ID,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Resource discovery failed",
+					Data:    fmt.Sprintf("Could not read service document: %s; could not load metadata: %s", err.Error(), schemaErr.Error()),
+				},
+			}
+		}
+		names = schema.EntitySetNames()
+	}
+	sort.Strings(names)
+
+	company := s.resourceCompany()
+	resources := make([]Resource, 0, len(names))
+	for _, name := range names {
+		resources = append(resources, Resource{
+			URI:         entitySetURI(company, name),
+			Name:        name,
+			Description: fmt.Sprintf("Schema and sample records of the Business Central entity set %s", name),
+			MimeType:    "application/json",
+		})
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: ResourcesListResult{
+			Resources: resources,
+		},
+	}
+}
+
+// handleResourceTemplatesList advertises the URI template for single records
+func (s *Server) handleResourceTemplatesList(request *JSONRPCRequest) *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: ResourceTemplatesListResult{
+			ResourceTemplates: []ResourceTemplate{
+				{
+					URITemplate: resourceScheme + "{company}/{entitySet}({key})",
+					Name:        "Business Central record",
+					Description: "A single record addressed by its key, e.g. " + entitySetURI(s.resourceCompany(), "Customers") + "('10000'). Composite keys use Name=value pairs, e.g. SalesOrders(Document_Type='Order',No='1001').",
+					MimeType:    "application/json",
+				},
+			},
+		},
+	}
+}
+
+// handleResourcesRead returns an entity set's schema and a few sample records,
+// or a single record when the URI carries a key
+func (s *Server) handleResourcesRead(ctx context.Context, request *JSONRPCRequest) *JSONRPCResponse {
+	var params ReadResourceParams
+	if err := json.Unmarshal(request.Params, &params); err != nil || params.URI == "" {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: uri is required",
+			},
+		}
+	}
+
+	ref, err := parseResourceURI(params.URI)
+	// "default" always names the configured company, as in the listed URIs
+	if err == nil && ref.Company != defaultCompany && ref.Company != s.resourceCompany() {
+		ctx, err = s.selectCompany(ctx, ref.Company)
+	}
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid resource URI",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	var content interface{}
+	if ref.Key != nil {
+		content, err = s.readRecord(ctx, ref)
+	} else {
+		content, err = s.readEntitySet(ctx, ref)
+	}
+	var odataErr *bc.ODataError
+	if errors.As(err, &odataErr) && odataErr.StatusCode == http.StatusNotFound {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32002,
+				Message: "Resource not found",
+				Data:    params.URI,
+			},
+		}
+	}
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Resource read failed",
+				Data:    fmt.Sprintf("Failed to read '%s': %s", params.URI, err.Error()),
+			},
+		}
+	}
+
+	contentJSON, _ := json.Marshal(content)
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: ReadResourceResult{
+			Contents: []ResourceContents{
+				{
+					URI:      params.URI,
+					MimeType: "application/json",
+					Text:     string(contentJSON),
+				},
+			},
+		},
+	}
+}
+
+// readEntitySet describes an entity set and attaches a few of its records.
+// The sample alone is returned when $metadata is unavailable.
+func (s *Server) readEntitySet(ctx context.Context, ref resourceRef) (interface{}, error) {
+	result := map[string]interface{}{
+		"entity_set": ref.EntitySet,
+	}
+	if schema, err := s.loadSchema(ctx); err != nil {
+		result["metadata_error"] = err.Error()
+	} else if description, err := schema.Describe(ref.EntitySet); err == nil {
+		result["schema"] = description
+	}
+
+	sample, err := s.clientFor(ctx).Query(ctx, bc.NewQuery(ref.EntitySet).Top(resourceSampleSize), false)
+	if err != nil {
+		return nil, err
+	}
+	result["sample"] = sample
+	return result, nil
+}
+
+// readRecord fetches the record addressed by a resource URI with a key
+func (s *Server) readRecord(ctx context.Context, ref resourceRef) (interface{}, error) {
+	query, err := s.entityQuery(ctx, ref.EntitySet, ref.Key)
+	if err != nil {
+		return nil, err
+	}
+	return s.clientFor(ctx).GetEntity(ctx, query)
+}
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+)
+
+func TestParseResourceURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    resourceRef
+		wantErr bool
+	}{
+		{uri: "bc://CRONUS%20Italia/Customers", want: resourceRef{Company: "CRONUS Italia", EntitySet: "Customers"}},
+		{uri: "bc://CRONUS/Customers('10000')", want: resourceRef{Company: "CRONUS", EntitySet: "Customers", Key: "10000"}},
+		{uri: "bc://CRONUS/Items(42)", want: resourceRef{Company: "CRONUS", EntitySet: "Items", Key: "42"}},
+		{uri: "bc://CRONUS/Customers('O''Brien, Ltd')", want: resourceRef{Company: "CRONUS", EntitySet: "Customers", Key: "O'Brien, Ltd"}},
+		{
+			uri:  "bc://CRONUS/SalesOrders(Document_Type='Order',No='1001')",
+			want: resourceRef{Company: "CRONUS", EntitySet: "SalesOrders", Key: map[string]interface{}{"Document_Type": "Order", "No": "1001"}},
+		},
+		{uri: "https://CRONUS/Customers", wantErr: true},
+		{uri: "bc://CRONUS", wantErr: true},
+		{uri: "bc://CRONUS/Customers('10000'", wantErr: true},
+		{uri: "bc://CRONUS/Customers('10000)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := parseResourceURI(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseResourceURI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseResourceURI() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Resources(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "$metadata"):
+			_, _ = w.Write([]byte(testMetadata))
+		case r.URL.Path == "/":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"value":[{"name":"SalesInvoices","url":"SalesInvoices"},{"name":"Customers","url":"Customers"},{"name":"CompanyInformation","kind":"Singleton","url":"CompanyInformation"}]}`))
+		case r.URL.Path == "/Customers('10000')":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"No":"10000","Name":"Adatum"}`))
+		case r.URL.Path == "/Customers":
+			if r.URL.Query().Get("$top") != "5" {
+				t.Errorf("sample $top = %q, want 5", r.URL.Query().Get("$top"))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"value":[{"No":"10000","Name":"Adatum"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`))
+	list, ok := response.Result.(ResourcesListResult)
+	if !ok {
+		t.Fatalf("resources/list result = %+v", response)
+	}
+	if len(list.Resources) != 2 || list.Resources[0].URI != "bc://default/Customers" || list.Resources[1].Name != "SalesInvoices" {
+		t.Errorf("resources = %+v, want the two entity sets in order", list.Resources)
+	}
+
+	read := func(uri string) *JSONRPCResponse {
+		params, _ := json.Marshal(ReadResourceParams{URI: uri})
+		return server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":`+string(params)+`}`))
+	}
+
+	response = read("bc://default/Customers")
+	result, ok := response.Result.(ReadResourceResult)
+	if !ok || len(result.Contents) != 1 {
+		t.Fatalf("resources/read result = %+v", response)
+	}
+	var entitySet struct {
+		Schema struct {
+			Keys []string `json:"keys"`
+		} `json:"schema"`
+		Sample []map[string]interface{} `json:"sample"`
+	}
+	if err := json.Unmarshal([]byte(result.Contents[0].Text), &entitySet); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if len(entitySet.Schema.Keys) != 1 || len(entitySet.Sample) != 1 {
+		t.Errorf("entity set content = %s, want schema and sample", result.Contents[0].Text)
+	}
+
+	response = read("bc://default/Customers('10000')")
+	if result, ok := response.Result.(ReadResourceResult); !ok || !strings.Contains(result.Contents[0].Text, "Adatum") {
+		t.Errorf("record read = %+v", response)
+	}
+
+	if response = read("bc://default/Customers('99999')"); response.Error == nil || response.Error.Code != -32002 {
+		t.Errorf("missing record = %+v, want resource not found", response)
+	}
+	if response = read("bc://Other/Customers"); response.Error == nil || response.Error.Code != -32602 {
+		t.Errorf("unknown company = %+v, want invalid params", response)
+	}
+}
+
+func TestServer_ResourcesDefaultCompany(t *testing.T) {
+	var paths []string
+	server := newTestServerWithConfig(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testMetadata))
+			return
+		}
+		paths = append(paths, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"No":"10000","Name":"Adatum"}`))
+	}, func(cfg *bc.Config) {
+		cfg.BasePath += "ODataV4/"
+		cfg.Company = "CRONUS"
+	})
+
+	params, _ := json.Marshal(ReadResourceParams{URI: "bc://default/Customers('10000')"})
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":`+string(params)+`}`))
+	if response.Error != nil {
+		t.Fatalf("resources/read error = %+v", response.Error)
+	}
+	if len(paths) != 1 || paths[0] != "/ODataV4/Company('CRONUS')/Customers('10000')" {
+		t.Errorf("paths = %v, want the configured company", paths)
+	}
+}
@@ -1,24 +1,51 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/url"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
+	"github.com/iafnetworkspa/bc-odata-mcp/internal/metadata"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// metadataCacheTTL controls how long a parsed $metadata document is reused
+const metadataCacheTTL = 30 * time.Minute
+
+// Budget for paginate=true, so a large ledger cannot exhaust memory or the client's context window
+const (
+	defaultMaxRecords = 5000
+	maxResponseBytes  = 50 << 20
+)
+
+// defaultMaxConcurrency bounds the tool calls running at the same time
+const defaultMaxConcurrency = 8
+
 // Server represents the MCP server
 type Server struct {
-	client *bc.Client
-	auth   *bc.Auth
-	config bc.Config
+	client   *bc.Client // client, auth and config of the default profile
+	auth     *bc.Auth
+	config   bc.Config
+	profiles map[string]*profile
+	schemas  *metadata.Cache
+	slots    chan struct{} // semaphore for concurrent requests, nil when unlimited
+	running  *inflight
+	prompts  *promptSet
+
+	toolTimeout  time.Duration            // deadline for tool calls, 0 for none
+	toolTimeouts map[string]time.Duration // per-tool deadlines overriding toolTimeout
+	policy       *policy                  // which tools and writes clients may use
+
+	confirmations *confirmations // tokens issued by dry runs of write tools
 }
 
 // NewServer creates a new MCP server instance
@@ -27,110 +54,186 @@ func NewServer(cfg bc.Config) (*Server, error) {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	auth := bc.NewAuth(cfg)
-	client := bc.NewClient(cfg, auth)
+	defaultProfile := newProfile(defaultProfileName, cfg)
 
 	return &Server{
-		client: client,
-		auth:   auth,
-		config: cfg,
+		client:   defaultProfile.client,
+		auth:     defaultProfile.auth,
+		config:   cfg,
+		profiles: map[string]*profile{defaultProfileName: defaultProfile},
+		schemas:  metadata.NewCache(metadataCacheTTL),
+		slots:    make(chan struct{}, defaultMaxConcurrency),
+		running:  newInflight(),
+		prompts:  newPromptSet(),
+		policy:   &policy{},
+
+		confirmations: newConfirmations(),
 	}, nil
 }
 
-// Run starts the MCP server and handles JSON-RPC requests
-func (s *Server) Run() error {
-	// Start handling requests
-	decoder := json.NewDecoder(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
-
-	for {
-		var rawRequest json.RawMessage
-		if err := decoder.Decode(&rawRequest); err != nil {
-			if err == io.EOF {
-				break
-			}
-			// For parse errors, try to extract ID from raw JSON if possible
-			// Otherwise, don't send a response (Cursor doesn't accept null ID)
-			var temp map[string]interface{}
-			if json.Unmarshal(rawRequest, &temp) == nil {
-				if id, ok := temp["id"]; ok && id != nil {
-					parseError := &JSONRPCResponse{
-						JSONRPC: "2.0",
-						ID:      id,
-						Error: &JSONRPCError{
-							Code:    -32700,
-							Message: "Parse error",
-							Data:    err.Error(),
-						},
-					}
-					_ = encoder.Encode(parseError)
-				}
-			}
-			continue
-		}
+// SetMaxConcurrency limits how many requests are processed at the same time;
+// 0 removes the limit. It must be called before Serve.
+func (s *Server) SetMaxConcurrency(limit int) {
+	if limit <= 0 {
+		s.slots = nil
+		return
+	}
+	s.slots = make(chan struct{}, limit)
+}
 
-		var request JSONRPCRequest
-		if err := json.Unmarshal(rawRequest, &request); err != nil {
-			// Try to extract ID from raw request
-			var temp map[string]interface{}
-			if json.Unmarshal(rawRequest, &temp) == nil {
-				if id, ok := temp["id"]; ok && id != nil {
-					parseError := &JSONRPCResponse{
-						JSONRPC: "2.0",
-						ID:      id,
-						Error: &JSONRPCError{
-							Code:    -32700,
-							Message: "Parse error",
-							Data:    err.Error(),
-						},
-					}
-					_ = encoder.Encode(parseError)
-				}
-			}
-			continue
+// SetToolTimeout sets the deadline for tool calls; 0 lets them run until the
+// client cancels. It must be called before Serve.
+func (s *Server) SetToolTimeout(timeout time.Duration) {
+	s.toolTimeout = timeout
+}
+
+// SetToolTimeoutFor sets the deadline for one tool, overriding SetToolTimeout;
+// 0 removes the deadline for that tool. It must be called before Serve.
+func (s *Server) SetToolTimeoutFor(tool string, timeout time.Duration) error {
+	known := false
+	for _, definition := range toolDefinitions() {
+		known = known || definition.Name == tool
+	}
+	if !known {
+		return fmt.Errorf("unknown tool '%s'", tool)
+	}
+	if s.toolTimeouts == nil {
+		s.toolTimeouts = make(map[string]time.Duration)
+	}
+	s.toolTimeouts[tool] = timeout
+	return nil
+}
+
+// SetMetadataCacheTTL sets how long a parsed $metadata document is reused. It
+// must be called before Serve.
+func (s *Server) SetMetadataCacheTTL(ttl time.Duration) {
+	s.schemas = metadata.NewCache(ttl)
+}
+
+// timeoutFor returns the deadline applied to a tool call, 0 for none
+func (s *Server) timeoutFor(tool string) time.Duration {
+	if timeout, ok := s.toolTimeouts[tool]; ok {
+		return timeout
+	}
+	return s.toolTimeout
+}
+
+// loadSchema returns the parsed $metadata for the environment of the selected profile
+func (s *Server) loadSchema(ctx context.Context) (*metadata.Schema, error) {
+	p := s.profileFor(ctx)
+	return s.schemas.Get(ctx, p.schemaKey(), func(ctx context.Context) (*metadata.Schema, error) {
+		body, err := p.client.Metadata(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+		}
+		schema, err := metadata.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metadata: %w", err)
 		}
+		log.Info().
+			Int("entity_sets", len(schema.EntitySets)).
+			Int("entity_types", len(schema.EntityTypes)).
+			Msg("Parsed OData metadata")
+		return schema, nil
+	})
+}
 
-		// Validate request
-		if request.JSONRPC != "2.0" {
-			if request.ID != nil {
-				response := &JSONRPCResponse{
+// Run serves JSON-RPC requests over stdin and stdout
+func (s *Server) Run() error {
+	return s.Serve(context.Background(), NewStdioTransport(os.Stdin, os.Stdout))
+}
+
+// Serve handles JSON-RPC requests arriving on the given transport
+func (s *Server) Serve(ctx context.Context, transport Transport) error {
+	return transport.Serve(ctx, s)
+}
+
+// HandleMessage decodes and dispatches a single JSON-RPC message. It is safe
+// for concurrent use; each request runs with its own context.
+func (s *Server) HandleMessage(ctx context.Context, message []byte) *JSONRPCResponse {
+	if run := s.Dispatch(ctx, message); run != nil {
+		return run()
+	}
+	return nil
+}
+
+// Dispatch decodes a JSON-RPC message and handles what must happen in arrival
+// order: notifications are processed and requests are registered, so that a
+// notifications/cancelled read after a request always finds it. The returned
+// function handles the request and may run concurrently; it is nil when there
+// is nothing left to do.
+func (s *Server) Dispatch(ctx context.Context, message []byte) func() *JSONRPCResponse {
+	respond := func(response *JSONRPCResponse) func() *JSONRPCResponse {
+		return func() *JSONRPCResponse { return response }
+	}
+
+	var request JSONRPCRequest
+	if err := json.Unmarshal(message, &request); err != nil {
+		// Only answer when an ID can be recovered (Cursor doesn't accept null ID)
+		var temp map[string]interface{}
+		if json.Unmarshal(message, &temp) == nil {
+			if id, ok := temp["id"]; ok && id != nil {
+				return respond(&JSONRPCResponse{
 					JSONRPC: "2.0",
-					ID:      request.ID,
+					ID:      id,
 					Error: &JSONRPCError{
-						Code:    -32600,
-						Message: "Invalid Request",
-						Data:    "jsonrpc must be '2.0'",
+						Code:    -32700,
+						Message: "Parse error",
+						Data:    err.Error(),
 					},
-				}
-				_ = encoder.Encode(response)
+				})
 			}
-			continue
 		}
+		return nil
+	}
 
-		// Handle notifications (requests without ID) - don't send response
+	// Validate request
+	if request.JSONRPC != "2.0" {
 		if request.ID == nil {
-			// This is a notification, process it but don't send a response
-			s.handleRequest(&request)
-			continue
+			return nil
 		}
+		return respond(&JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32600,
+				Message: "Invalid Request",
+				Data:    "jsonrpc must be '2.0'",
+			},
+		})
+	}
+
+	// Notifications are cheap and must not queue behind slow tool calls
+	if request.ID == nil {
+		s.handleRequest(ctx, &request)
+		return nil
+	}
 
-		response := s.handleRequest(&request)
+	// Registered before waiting for a slot, so queued requests can be cancelled too
+	ctx, running, done := s.running.start(ctx, request.ID)
+	return func() *JSONRPCResponse {
+		defer done()
 
-		// Only send response if it's not nil and has a valid ID
-		if response != nil && response.ID != nil {
-			if err := encoder.Encode(response); err != nil {
-				return fmt.Errorf("failed to encode response: %w", err)
+		if s.slots != nil {
+			select {
+			case s.slots <- struct{}{}:
+				defer func() { <-s.slots }()
+			case <-ctx.Done():
+				return nil
 			}
 		}
-	}
 
-	return nil
+		response := s.handleRequest(ctx, &request)
+		// Cancelled requests get no response
+		if response == nil || response.ID == nil || s.running.wasCancelled(running) {
+			return nil
+		}
+		return response
+	}
 }
 
 // handleRequest processes a JSON-RPC request
-func (s *Server) handleRequest(request *JSONRPCRequest) *JSONRPCResponse {
-	ctx := context.Background()
-
+func (s *Server) handleRequest(ctx context.Context, request *JSONRPCRequest) *JSONRPCResponse {
 	// Validate method is present
 	if request.Method == "" {
 		// Only return error if this is a request (has ID), not a notification
@@ -153,11 +256,24 @@ func (s *Server) handleRequest(request *JSONRPCRequest) *JSONRPCResponse {
 		return s.handleToolsList(request)
 	case "tools/call":
 		return s.handleToolCall(ctx, request)
+	case "resources/list":
+		return s.handleResourcesList(ctx, request)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(request)
+	case "resources/read":
+		return s.handleResourcesRead(ctx, request)
+	case "prompts/list":
+		return s.handlePromptsList(request)
+	case "prompts/get":
+		return s.handlePromptsGet(request)
 	case "initialize":
 		return s.handleInitialize(request)
 	case "initialized":
 		// This is a notification, return nil to indicate no response needed
 		return nil
+	case "notifications/cancelled":
+		s.handleCancelled(ctx, request)
+		return nil
 	default:
 		// Only return error if this is a request (has ID), not a notification
 		if request.ID != nil {
@@ -174,13 +290,40 @@ func (s *Server) handleRequest(request *JSONRPCRequest) *JSONRPCResponse {
 	}
 }
 
+// handleCancelled stops the request named in a notifications/cancelled message.
+// Requests that already finished are ignored, as the notification may race
+// with their response.
+func (s *Server) handleCancelled(ctx context.Context, request *JSONRPCRequest) {
+	var params CancelledParams
+	if err := json.Unmarshal(request.Params, &params); err != nil || params.RequestID == nil {
+		log.Warn().Str("params", string(request.Params)).Msg("Ignoring invalid cancellation notification")
+		return
+	}
+	if s.running.cancel(ctx, params.RequestID) {
+		log.Info().Interface("request_id", params.RequestID).Str("reason", params.Reason).Msg("Request cancelled by client")
+	}
+}
+
+// supportedProtocolVersions lists the MCP revisions the server speaks, newest first
+var supportedProtocolVersions = []string{"2025-03-26", "2024-11-05"}
+
 // handleInitialize handles the initialize request
 func (s *Server) handleInitialize(request *JSONRPCRequest) *JSONRPCResponse {
+	// Answer with the client's revision when supported, otherwise with the newest one
+	var params InitializeParams
+	_ = json.Unmarshal(request.Params, &params)
+	version := supportedProtocolVersions[0]
+	for _, v := range supportedProtocolVersions {
+		if v == params.ProtocolVersion {
+			version = v
+		}
+	}
+
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      request.ID,
 		Result: InitializeResult{
-			ProtocolVersion: "2024-11-05",
+			ProtocolVersion: version,
 			Capabilities: ServerCapabilities{
 				Tools: ToolCapabilities{
 					ListChanged: true,
@@ -196,10 +339,28 @@ func (s *Server) handleInitialize(request *JSONRPCRequest) *JSONRPCResponse {
 
 // handleToolsList returns the list of available tools
 func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
+	var allowed []Tool
+	for _, tool := range toolDefinitions() {
+		if s.policy.toolAllowed(tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      request.ID,
+		Result: ToolsListResult{
+			Tools: allowed,
+		},
+	}
+}
+
+// toolDefinitions returns every tool the server implements
+func toolDefinitions() []Tool {
 	tools := []Tool{
 		{
 			Name:        "bc_odata_query",
-			Description: "Execute an OData query against Business Central API. Supports filtering, sorting, and pagination.",
+			Description: "Execute an OData query against Business Central API. Supports filtering, sorting, and pagination. When more results are available the response includes next_cursor; pass it back as cursor to continue.",
 			InputSchema: ToolInputSchema{
 				Type: "object",
 				Properties: map[string]interface{}{
@@ -207,10 +368,7 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "string",
 						"description": "OData endpoint path (e.g., 'ODV_List', 'BI_Invoices', 'Customers')",
 					},
-					"filter": map[string]interface{}{
-						"type":        "string",
-						"description": "OData $filter expression (e.g., \"No eq '12345'\")",
-					},
+					"filter": filterArgSchema(filterSchemaDescription),
 					"select": map[string]interface{}{
 						"type":        "string",
 						"description": "OData $select expression to specify which fields to return",
@@ -232,6 +390,19 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"description": "Whether to automatically paginate through all results (default: false)",
 						"default":     false,
 					},
+					"paging": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"server", "skip"},
+						"description": "How paginate moves between pages: 'server' follows @odata.nextLink (default); 'skip' uses $top/$skip ordered by the entity key, for endpoints that don't return nextLink",
+					},
+					"cursor": map[string]interface{}{
+						"type":        "string",
+						"description": "next_cursor returned by a previous call, to fetch the following results. The other query arguments are taken from the cursor.",
+					},
+					"max_records": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of records returned when paginate is true (default: 5000). The response reports truncated=true when more results were available.",
+					},
 					"expand": map[string]interface{}{
 						"type":        "string",
 						"description": "OData $expand expression to include related entities (e.g., 'Customer,Items')",
@@ -242,7 +413,7 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 		},
 		{
 			Name:        "bc_odata_get_entity",
-			Description: "Get a specific entity by its key from Business Central API.",
+			Description: "Get a specific entity by its key from Business Central API. The key fields and their types are read from $metadata, so composite keys, GUIDs, integers and enums are all supported.",
 			InputSchema: ToolInputSchema{
 				Type: "object",
 				Properties: map[string]interface{}{
@@ -251,8 +422,12 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"description": "OData endpoint path (e.g., 'ODV_List', 'BI_Invoices')",
 					},
 					"key": map[string]interface{}{
-						"type":        "string",
-						"description": "The key value of the entity to retrieve (e.g., order number, invoice number)",
+						"anyOf": []interface{}{
+							map[string]interface{}{"type": "string"},
+							map[string]interface{}{"type": "number"},
+							map[string]interface{}{"type": "object"},
+						},
+						"description": "The key of the entity: a scalar for single-key entity sets (e.g., '10000' or a SystemId GUID), or an object with every key field for composite keys (e.g., {\"Document_Type\": \"Order\", \"No\": \"1001\"})",
 					},
 				},
 				Required: []string{"endpoint", "key"},
@@ -268,31 +443,38 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "string",
 						"description": "OData endpoint path (e.g., 'ODV_List', 'BI_Invoices')",
 					},
-					"filter": map[string]interface{}{
-						"type":        "string",
-						"description": "OData $filter expression (e.g., \"No eq '12345'\")",
-					},
+					"filter": filterArgSchema(filterSchemaDescription),
 				},
 				Required: []string{"endpoint"},
 			},
 		},
 		{
 			Name:        "bc_odata_list_endpoints",
-			Description: "List all available OData endpoints in Business Central. This helps discover available entities and APIs.",
+			Description: "List the OData endpoints actually published by Business Central (entity sets, singletons, function and action imports), read from the service document and $metadata.",
 			InputSchema: ToolInputSchema{
-				Type:       "object",
-				Properties: map[string]interface{}{},
+				Type: "object",
+				Properties: map[string]interface{}{
+					"search": map[string]interface{}{
+						"type":        "string",
+						"description": "Case-insensitive substring to filter endpoint names (e.g., 'Invoice')",
+					},
+					"kind": map[string]interface{}{
+						"type":        "string",
+						"description": "Only return endpoints of this kind",
+						"enum":        []string{"EntitySet", "Singleton", "FunctionImport", "ActionImport"},
+					},
+				},
 			},
 		},
 		{
 			Name:        "bc_odata_get_metadata",
-			Description: "Get OData metadata for a specific endpoint. Returns entity structure, properties, and relationships.",
+			Description: "Get OData metadata for a specific endpoint. Returns a compact JSON description of the entity set: fields, types, nullability, keys and navigation properties.",
 			InputSchema: ToolInputSchema{
 				Type: "object",
 				Properties: map[string]interface{}{
 					"endpoint": map[string]interface{}{
 						"type":        "string",
-						"description": "OData endpoint path (e.g., 'ODV_List', 'BI_Invoices'). Leave empty to get all metadata.",
+						"description": "OData entity set name (e.g., 'ODV_List', 'BI_Invoices'). Returns its fields, types, nullability, keys and navigation targets. Leave empty to get a summary of all entity sets.",
 					},
 				},
 			},
@@ -315,17 +497,14 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"type":        "string",
 						"description": "Fields to group by (e.g., 'Document_Type,Status')",
 					},
-					"filter": map[string]interface{}{
-						"type":        "string",
-						"description": "OData $filter expression to filter data before aggregation",
-					},
+					"filter": filterArgSchema("Filter applied before aggregation. " + filterSchemaDescription),
 				},
 				Required: []string{"endpoint", "aggregate"},
 			},
 		},
 		{
 			Name:        "bc_odata_create",
-			Description: "Create a new entity in Business Central. Supports POST operations for writable endpoints.",
+			Description: "Create a new entity in Business Central. Supports POST operations for writable endpoints. data may nest entities in navigation properties (deep insert), e.g. a sales order with its lines in one call; the result includes them.",
 			InputSchema: ToolInputSchema{
 				Type: "object",
 				Properties: map[string]interface{}{
@@ -335,7 +514,7 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 					},
 					"data": map[string]interface{}{
 						"type":        "object",
-						"description": "Entity data as key-value pairs",
+						"description": "Entity data as key-value pairs. Navigation properties take nested entities, e.g. {\"Sell_to_Customer_No\":\"10000\",\"SalesLines\":[{\"Type\":\"Item\",\"No\":\"1000\",\"Quantity\":5}]}",
 					},
 				},
 				Required: []string{"endpoint", "data"},
@@ -352,8 +531,12 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"description": "OData endpoint path",
 					},
 					"key": map[string]interface{}{
-						"type":        "string",
-						"description": "The key value of the entity to update",
+						"anyOf": []interface{}{
+							map[string]interface{}{"type": "string"},
+							map[string]interface{}{"type": "number"},
+							map[string]interface{}{"type": "object"},
+						},
+						"description": "The key of the entity to update: a scalar for single-key entity sets, or an object with every key field for composite keys (e.g., {\"Document_Type\": \"Order\", \"No\": \"1001\"})",
 					},
 					"data": map[string]interface{}{
 						"type":        "object",
@@ -361,7 +544,7 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 					},
 					"etag": map[string]interface{}{
 						"type":        "string",
-						"description": "ETag for optimistic concurrency control (optional)",
+						"description": "@odata.etag of the version being updated (optional). When omitted the current ETag is read first, so a concurrent change fails with a conflict; \"*\" overwrites any version.",
 					},
 				},
 				Required: []string{"endpoint", "key", "data"},
@@ -378,13 +561,82 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 						"description": "OData endpoint path",
 					},
 					"key": map[string]interface{}{
+						"anyOf": []interface{}{
+							map[string]interface{}{"type": "string"},
+							map[string]interface{}{"type": "number"},
+							map[string]interface{}{"type": "object"},
+						},
+						"description": "The key of the entity to delete: a scalar for single-key entity sets, or an object with every key field for composite keys (e.g., {\"Document_Type\": \"Order\", \"No\": \"1001\"})",
+					},
+					"etag": map[string]interface{}{
 						"type":        "string",
-						"description": "The key value of the entity to delete",
+						"description": "@odata.etag of the version being deleted (optional). When omitted the current ETag is read first; \"*\" deletes any version.",
 					},
 				},
 				Required: []string{"endpoint", "key"},
 			},
 		},
+		{
+			Name:        "bc_odata_batch",
+			Description: "Run up to 100 read/create/update/delete operations in a single OData $batch request, e.g. a sales order header and its lines. Operations in the same change_set (or all writes with atomic=true) succeed or fail together; an endpoint like $1/SalesLines addresses the entity created by operation 1. Returns the status and result of every operation.",
+			InputSchema: ToolInputSchema{
+				Type: "object",
+				Properties: map[string]interface{}{
+					"operations": map[string]interface{}{
+						"type":        "array",
+						"description": "Operations, run in order",
+						"items": map[string]interface{}{
+							"type": "object",
+							"properties": map[string]interface{}{
+								"id": map[string]interface{}{
+									"type":        "string",
+									"description": "Operation id, referenced by depends_on and $<id> endpoints (default: position, starting at 1)",
+								},
+								"operation": map[string]interface{}{
+									"type": "string",
+									"enum": []string{"read", "create", "update", "delete"},
+								},
+								"endpoint": map[string]interface{}{
+									"type":        "string",
+									"description": "Entity set (e.g. 'SalesOrders'), or $<id>/<navigation> to use the result of an earlier operation",
+								},
+								"key": map[string]interface{}{
+									"anyOf": []interface{}{
+										map[string]interface{}{"type": "string"},
+										map[string]interface{}{"type": "number"},
+										map[string]interface{}{"type": "object"},
+									},
+									"description": "Key of the entity to read, update or delete; an object for composite keys",
+								},
+								"data": map[string]interface{}{
+									"type":        "object",
+									"description": "Fields to create or update",
+								},
+								"etag": map[string]interface{}{
+									"type":        "string",
+									"description": "@odata.etag for updates and deletes; when omitted the current ETag is read first, \"*\" writes any version",
+								},
+								"change_set": map[string]interface{}{
+									"type":        "string",
+									"description": "Name of the change set: its operations succeed or fail together",
+								},
+								"depends_on": map[string]interface{}{
+									"type":        "array",
+									"items":       map[string]interface{}{"type": "string"},
+									"description": "Ids of earlier operations or change sets that must succeed first",
+								},
+							},
+							"required": []string{"operation", "endpoint"},
+						},
+					},
+					"atomic": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Put all write operations without a change_set in one change set (default: false)",
+					},
+				},
+				Required: []string{"operations"},
+			},
+		},
 		{
 			Name:        "bc_odata_check_order_status",
 			Description: "Intelligently check the status of a sales order. First checks ODV_List (if found, order is not invoiced). If not found in ODV_List, checks BI_Invoices or SalesInvoices by order_no (if found, order is invoiced). If not found in either, the order may be cancelled or the order number may be incorrect.",
@@ -400,14 +652,35 @@ func (s *Server) handleToolsList(request *JSONRPCRequest) *JSONRPCResponse {
 			},
 		},
 	}
-
-	return &JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      request.ID,
-		Result: ToolsListResult{
-			Tools: tools,
+	for _, tool := range tools {
+		if _, ok := writeOperations[tool.Name]; ok {
+			tool.InputSchema.Properties["dry_run"] = dryRunArgSchema
+			tool.InputSchema.Properties["confirmation_token"] = confirmationTokenArgSchema
+		}
+		tool.InputSchema.Properties["company"] = companyArgSchema
+		tool.InputSchema.Properties["profile"] = profileArgSchema
+		tool.InputSchema.Properties["environment"] = environmentArgSchema
+	}
+	tools = append(tools, Tool{
+		Name:        "bc_odata_list_companies",
+		Description: "List the companies of the Business Central environment. Pass a company name as the company argument of the other tools to work on that company.",
+		InputSchema: ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"profile":     profileArgSchema,
+				"environment": environmentArgSchema,
+			},
 		},
-	}
+	}, Tool{
+		Name:        "bc_odata_list_profiles",
+		Description: "List the configured connection profiles with their tenant, environment and default company. Pass a profile name as the profile argument of the other tools, e.g. to compare Sandbox and Production data.",
+		InputSchema: ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	})
+
+	return tools
 }
 
 // handleToolCall executes a tool call
@@ -425,31 +698,114 @@ func (s *Server) handleToolCall(ctx context.Context, request *JSONRPCRequest) *J
 		}
 	}
 
+	if !s.policy.toolAllowed(params.Name) {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32601,
+				Message: "Tool not allowed",
+				Data:    fmt.Sprintf("Tool '%s' is not enabled in the server configuration", params.Name),
+			},
+		}
+	}
+	if operation, ok := writeOperations[params.Name]; ok {
+		endpoint, _ := params.Arguments["endpoint"].(string)
+		if err := s.policy.checkWrite(entitySetOf(endpoint), operation); err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      request.ID,
+				Error: &JSONRPCError{
+					Code:    -32000,
+					Message: "Operation not permitted",
+					Data:    err.Error(),
+				},
+			}
+		}
+	}
+
+	if params.Meta != nil && params.Meta.ProgressToken != nil {
+		ctx = withProgressToken(ctx, params.Meta.ProgressToken)
+	}
+
+	profileName, _ := params.Arguments["profile"].(string)
+	environment, _ := params.Arguments["environment"].(string)
+	ctx, err := s.selectProfile(ctx, profileName, environment)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: cannot select profile",
+				Data:    err.Error(),
+			},
+		}
+	}
+	company, _ := params.Arguments["company"].(string)
+	ctx, err = s.selectCompany(ctx, company)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: cannot select company",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	if timeout := s.timeoutFor(params.Name); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	response := s.callTool(ctx, request.ID, params)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      request.ID,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Tool call timed out",
+				Data:    fmt.Sprintf("Tool '%s' did not complete within %s", params.Name, s.timeoutFor(params.Name)),
+			},
+		}
+	}
+	return response
+}
+
+// callTool dispatches a tool call to its handler
+func (s *Server) callTool(ctx context.Context, id interface{}, params ToolCallParams) *JSONRPCResponse {
 	switch params.Name {
 	case "bc_odata_query":
-		return s.handleODataQuery(ctx, request.ID, params.Arguments)
+		return s.handleODataQuery(ctx, id, params.Arguments)
 	case "bc_odata_get_entity":
-		return s.handleGetEntity(ctx, request.ID, params.Arguments)
+		return s.handleGetEntity(ctx, id, params.Arguments)
 	case "bc_odata_count":
-		return s.handleCount(ctx, request.ID, params.Arguments)
+		return s.handleCount(ctx, id, params.Arguments)
 	case "bc_odata_list_endpoints":
-		return s.handleListEndpoints(ctx, request.ID, params.Arguments)
+		return s.handleListEndpoints(ctx, id, params.Arguments)
 	case "bc_odata_get_metadata":
-		return s.handleGetMetadata(ctx, request.ID, params.Arguments)
+		return s.handleGetMetadata(ctx, id, params.Arguments)
 	case "bc_odata_aggregate":
-		return s.handleAggregate(ctx, request.ID, params.Arguments)
-	case "bc_odata_create":
-		return s.handleCreate(ctx, request.ID, params.Arguments)
-	case "bc_odata_update":
-		return s.handleUpdate(ctx, request.ID, params.Arguments)
-	case "bc_odata_delete":
-		return s.handleDelete(ctx, request.ID, params.Arguments)
+		return s.handleAggregate(ctx, id, params.Arguments)
+	case "bc_odata_create", "bc_odata_update", "bc_odata_delete":
+		return s.handleWriteTool(ctx, id, params)
+	case "bc_odata_batch":
+		return s.handleBatch(ctx, id, params.Arguments)
 	case "bc_odata_check_order_status":
-		return s.handleCheckOrderStatus(ctx, request.ID, params.Arguments)
+		return s.handleCheckOrderStatus(ctx, id, params.Arguments)
+	case "bc_odata_list_companies":
+		return s.handleListCompanies(ctx, id)
+	case "bc_odata_list_profiles":
+		return s.handleListProfiles(id)
 	default:
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
-			ID:      request.ID,
+			ID:      id,
 			Error: &JSONRPCError{
 				Code:    -32601,
 				Message: "Tool not found",
@@ -472,54 +828,105 @@ func (s *Server) handleODataQuery(ctx context.Context, id interface{}, args map[
 		}
 	}
 
-	// Build OData query string with proper URL encoding
-	queryParams := url.Values{}
-
-	if filter, ok := args["filter"].(string); ok && filter != "" {
-		queryParams.Set("$filter", filter)
-	}
-
-	if selectFields, ok := args["select"].(string); ok && selectFields != "" {
-		queryParams.Set("$select", selectFields)
-	}
-
-	if orderby, ok := args["orderby"].(string); ok && orderby != "" {
-		queryParams.Set("$orderby", orderby)
-	}
-
-	if top, ok := args["top"].(float64); ok && top > 0 {
-		queryParams.Set("$top", fmt.Sprintf("%.0f", top))
-	}
-
-	if skip, ok := args["skip"].(float64); ok && skip > 0 {
-		queryParams.Set("$skip", fmt.Sprintf("%.0f", skip))
+	paginate, _ := args["paginate"].(bool)
+	options := bc.PageOptions{}
+	if paginate {
+		options = bc.PageOptions{MaxRecords: defaultMaxRecords, MaxBytes: maxResponseBytes}
+		if maxRecords, ok := args["max_records"].(float64); ok && maxRecords > 0 {
+			options.MaxRecords = int(maxRecords)
+		}
+		switch paging, _ := args["paging"].(string); paging {
+		case "", "server":
+		case "skip":
+			options.Mode = bc.SkipPaging
+		default:
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32602,
+					Message: "Invalid params: paging must be 'server' or 'skip'",
+				},
+			}
+		}
 	}
 
-	if expand, ok := args["expand"].(string); ok && expand != "" {
-		queryParams.Set("$expand", expand)
+	if paginate {
+		options.OnPage = pageProgress(ctx)
 	}
 
-	queryString := queryParams.Encode()
-	fullEndpoint := endpoint
-	if queryString != "" {
-		fullEndpoint = endpoint + "?" + queryString
-	}
+	var pager *bc.Pager
+	if cursorArg, ok := args["cursor"].(string); ok && cursorArg != "" {
+		// A cursor continues a previous query; the other query arguments are ignored
+		cursor, err := decodeCursor(cursorArg, endpoint, s.cursorScope(ctx))
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32602,
+					Message: "Invalid params: invalid cursor",
+					Data:    err.Error(),
+				},
+			}
+		}
+		pager = s.clientFor(ctx).ResumePages(cursor, options)
+	} else {
+		var rendered string
+		query, err := s.buildQuery(ctx, endpoint, args)
+		if err == nil {
+			rendered, err = query.Endpoint()
+		}
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32602,
+					Message: "Invalid params: invalid filter",
+					Data:    err.Error(),
+				},
+			}
+		}
 
-	// Check if pagination is requested
-	// If $top is specified, don't use automatic pagination (respect the limit)
-	paginate := false
-	hasTop := false
-	if top, ok := args["top"].(float64); ok && top > 0 {
-		hasTop = true
+		if top, hasTop := query.TopValue(); hasTop {
+			// $top is respected as a limit rather than paginated through; the
+			// cursor returns the next $top results
+			paginate = false
+			pager = s.clientFor(ctx).ResumePages(bc.Cursor{Endpoint: rendered, PageSize: top}, options)
+		} else if paginate {
+			if options.Mode == bc.SkipPaging {
+				if err := s.stableOrderBy(ctx, endpoint, query); err != nil {
+					return &JSONRPCResponse{
+						JSONRPC: "2.0",
+						ID:      id,
+						Error: &JSONRPCError{
+							Code:    -32602,
+							Message: "Invalid params: cannot order by the entity key",
+							Data:    err.Error(),
+						},
+					}
+				}
+			}
+			pager = s.clientFor(ctx).Pages(query, options)
+		} else {
+			pager = s.clientFor(ctx).ResumePages(bc.Cursor{Endpoint: rendered}, options)
+		}
 	}
 
-	// Only use pagination if explicitly requested AND no $top limit is set
-	if p, ok := args["paginate"].(bool); ok && p && !hasTop {
-		paginate = p
+	// Read the first page, or with paginate every page within the record and byte budget
+	var results []map[string]interface{}
+	var err error
+	for pager.HasMore() {
+		var page *bc.Page
+		if page, err = pager.Next(ctx); err != nil {
+			break
+		}
+		results = append(results, page.Value...)
+		if !paginate {
+			break
+		}
 	}
-
-	// Execute query
-	results, err := s.client.Query(ctx, fullEndpoint, paginate)
 	if err != nil {
 		// Provide more descriptive error message
 		errorMsg := fmt.Sprintf("Failed to execute OData query on endpoint '%s': %s", endpoint, err.Error())
@@ -534,10 +941,17 @@ func (s *Server) handleODataQuery(ctx context.Context, id interface{}, args map[
 		}
 	}
 
-	resultJSON, _ := json.Marshal(map[string]interface{}{
+	result := map[string]interface{}{
 		"results": results,
 		"count":   len(results),
-	})
+	}
+	if pager.Truncated() {
+		result["truncated"] = true
+	}
+	if cursor, ok := pager.Cursor(); ok {
+		result["next_cursor"] = encodeCursor(cursor, s.cursorScope(ctx))
+	}
+	resultJSON, _ := json.Marshal(result)
 
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
@@ -553,6 +967,38 @@ func (s *Server) handleODataQuery(ctx context.Context, id interface{}, args map[
 	}
 }
 
+// buildQuery maps the common query tool arguments onto an OData query
+func (s *Server) buildQuery(ctx context.Context, endpoint string, args map[string]interface{}) (*bc.Query, error) {
+	filter, err := s.queryFilter(ctx, endpoint, args["filter"])
+	if err != nil {
+		return nil, err
+	}
+	query := bc.NewQuery(endpoint).Filter(filter)
+
+	if selectFields, ok := args["select"].(string); ok && selectFields != "" {
+		query.Select(strings.Split(selectFields, ",")...)
+	}
+
+	if orderby, ok := args["orderby"].(string); ok && orderby != "" {
+		query.OrderBy(strings.Split(orderby, ",")...)
+	}
+
+	if top, ok := args["top"].(float64); ok && top > 0 {
+		query.Top(int(top))
+	}
+
+	if skip, ok := args["skip"].(float64); ok && skip > 0 {
+		query.Skip(int(skip))
+	}
+
+	// $expand may contain nested options with commas, so it is passed through as one item
+	if expand, ok := args["expand"].(string); ok && expand != "" {
+		query.Expand(expand, nil)
+	}
+
+	return query, nil
+}
+
 // handleGetEntity handles getting a specific entity by key
 func (s *Server) handleGetEntity(ctx context.Context, id interface{}, args map[string]interface{}) *JSONRPCResponse {
 	endpoint, ok := args["endpoint"].(string)
@@ -567,8 +1013,8 @@ func (s *Server) handleGetEntity(ctx context.Context, id interface{}, args map[s
 		}
 	}
 
-	key, ok := args["key"].(string)
-	if !ok {
+	key, ok := args["key"]
+	if !ok || key == nil || key == "" {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
@@ -579,46 +1025,58 @@ func (s *Server) handleGetEntity(ctx context.Context, id interface{}, args map[s
 		}
 	}
 
-	// For endpoints with composite keys (like ODV_List), we use $filter instead of key syntax
-	// This is more reliable for Business Central endpoints
-	// Build OData query string with proper URL encoding
-	queryParams := url.Values{}
-	// Escape single quotes in the key value for OData filter
-	escapedKey := strings.ReplaceAll(key, "'", "''")
-	queryParams.Set("$filter", fmt.Sprintf("No eq '%s'", escapedKey))
-	queryParams.Set("$top", "1")
-	queryString := queryParams.Encode()
-	fullEndpoint := endpoint + "?" + queryString
+	var (
+		result interface{}
+		err    error
+	)
 
-	// Execute query using filter (more reliable for Business Central endpoints with composite keys)
-	results, err := s.client.Query(ctx, fullEndpoint, false)
-	if err != nil {
-		// Provide more descriptive error message
-		errorMsg := fmt.Sprintf("Failed to retrieve entity '%s' from endpoint '%s': %s", key, endpoint, err.Error())
+	query, queryErr := s.entityQuery(ctx, endpoint, key)
+	switch {
+	case queryErr == nil:
+		result, err = s.clientFor(ctx).GetEntity(ctx, query)
+	case errors.Is(queryErr, errMetadataUnavailable):
+		// Without metadata we don't know the key field, so fall back to filtering on No
+		log.Warn().Err(queryErr).Str("endpoint", endpoint).Msg("Key lookup without metadata, falling back to $filter on No")
+		result, err = s.getEntityByNo(ctx, endpoint, fmt.Sprint(key))
+	default:
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
 			Error: &JSONRPCError{
-				Code:    -32000,
-				Message: "Entity retrieval failed",
-				Data:    errorMsg,
+				Code:    -32602,
+				Message: "Invalid params: invalid key",
+				Data:    queryErr.Error(),
 			},
 		}
 	}
 
-	if len(results) == 0 {
+	var odataErr *bc.ODataError
+	if (errors.As(err, &odataErr) && odataErr.StatusCode == http.StatusNotFound) || (err == nil && result == nil) {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
 			Error: &JSONRPCError{
 				Code:    -32001,
 				Message: "Entity not found",
-				Data:    fmt.Sprintf("No entity found with key '%s' in endpoint '%s'", key, endpoint),
+				Data:    fmt.Sprintf("No entity found with key '%v' in endpoint '%s'", key, endpoint),
+			},
+		}
+	}
+	if err != nil {
+		// Provide more descriptive error message
+		errorMsg := fmt.Sprintf("Failed to retrieve entity '%v' from endpoint '%s': %s", key, endpoint, err.Error())
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Entity retrieval failed",
+				Data:    errorMsg,
 			},
 		}
 	}
 
-	resultJSON, _ := json.Marshal(results[0])
+	resultJSON, _ := json.Marshal(result)
 
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
@@ -634,6 +1092,18 @@ func (s *Server) handleGetEntity(ctx context.Context, id interface{}, args map[s
 	}
 }
 
+// getEntityByNo looks up an entity with $filter=No eq '<key>', which is what
+// most Business Central pages use as their primary key field
+func (s *Server) getEntityByNo(ctx context.Context, endpoint, key string) (interface{}, error) {
+	query := bc.NewQuery(endpoint).Filter(bc.Eq("No", key)).Top(1)
+
+	results, err := s.clientFor(ctx).Query(ctx, query, false)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return results[0], nil
+}
+
 // handleCount handles count requests
 func (s *Server) handleCount(ctx context.Context, id interface{}, args map[string]interface{}) *JSONRPCResponse {
 	endpoint, ok := args["endpoint"].(string)
@@ -648,18 +1118,22 @@ func (s *Server) handleCount(ctx context.Context, id interface{}, args map[strin
 		}
 	}
 
-	// Build OData query string with $count using proper URL encoding
-	queryParams := url.Values{}
-	queryParams.Set("$count", "true")
-	if filter, ok := args["filter"].(string); ok && filter != "" {
-		queryParams.Set("$filter", filter)
+	filter, err := s.queryFilter(ctx, endpoint, args["filter"])
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid filter",
+				Data:    err.Error(),
+			},
+		}
 	}
+	query := bc.NewQuery(endpoint).Filter(filter)
 
-	queryString := queryParams.Encode()
-	fullEndpoint := endpoint + "?" + queryString
-
-	// Execute query
-	results, err := s.client.Query(ctx, fullEndpoint, false)
+	// Ask the server for the total instead of counting the first page of results
+	count, err := s.clientFor(ctx).Count(ctx, query)
 	if err != nil {
 		// Provide more descriptive error message
 		errorMsg := fmt.Sprintf("Failed to count entities on endpoint '%s': %s", endpoint, err.Error())
@@ -675,7 +1149,7 @@ func (s *Server) handleCount(ctx context.Context, id interface{}, args map[strin
 	}
 
 	resultJSON, _ := json.Marshal(map[string]interface{}{
-		"count": len(results),
+		"count": count,
 	})
 
 	return &JSONRPCResponse{
@@ -692,104 +1166,102 @@ func (s *Server) handleCount(ctx context.Context, id interface{}, args map[strin
 	}
 }
 
-// handleListEndpoints lists all available OData endpoints
+// endpointInfo describes a discovered OData endpoint
+type endpointInfo struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	URL        string `json:"url"`
+	EntityType string `json:"entity_type,omitempty"`
+}
+
+// handleListEndpoints lists the OData endpoints actually published by the tenant.
+// It merges the service document (what the company root exposes) with the entity
+// container of the parsed $metadata, so each endpoint carries its kind and URL.
 func (s *Server) handleListEndpoints(ctx context.Context, id interface{}, args map[string]interface{}) *JSONRPCResponse {
-	// Business Central OData v4 structure:
-	// - Root endpoint returns Company info
-	// - To get entity list, we need to parse $metadata XML or try common endpoints
-	// - Common endpoints in BC: ODV_List, Customers, Items, SalesOrders, PurchaseOrders, etc.
-
-	// First, try to get metadata which contains all entity definitions
-	// Metadata is at tenant/environment level, not company level
-	// We'll need to construct the metadata URL differently
-
-	// For now, return a list of common Business Central endpoints
-	// and suggest using get_metadata to discover more
-	commonEndpoints := []string{
-		"ODV_List",
-		"Customers",
-		"Items",
-		"SalesOrders",
-		"PurchaseOrders",
-		"SalesInvoices",
-		"PurchaseInvoices",
-		"SalesQuotes",
-		"PurchaseQuotes",
-		"SalesCreditMemos",
-		"PurchaseCreditMemos",
-		"SalesShipments",
-		"PurchaseReceipts",
-		"Vendors",
-		"Employees",
-		"GLAccounts",
-		"Journals",
-		"JournalLines",
-		"BI_Invoices",
-		"BI_Customers",
-		"BI_Items",
-		"BI_Vendors",
-		"BI_GLAccounts",
-		"BI_SalesOrders",
-		"BI_PurchaseOrders",
-	}
-
-	// Try to get root endpoint to see what we get
-	resp, err := s.client.Get(ctx, "")
-	if err != nil {
-		// If root fails, just return common endpoints
-		resultJSON, _ := json.Marshal(map[string]interface{}{
-			"endpoints": commonEndpoints,
-			"count":     len(commonEndpoints),
-			"note":      "Common Business Central endpoints. Use bc_odata_get_metadata to discover all available endpoints.",
-			"error":     fmt.Sprintf("Could not query root endpoint: %s", err.Error()),
-		})
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      id,
-			Result: ToolCallResult{
-				Content: []Content{
-					{
-						Type: "text",
-						Text: string(resultJSON),
-					},
-				},
-			},
+	search, _ := args["search"].(string)
+	kind, _ := args["kind"].(string)
+
+	endpoints := make(map[string]*endpointInfo)
+	var warnings []string
+
+	serviceEntries, serviceErr := s.clientFor(ctx).ServiceDocument(ctx)
+	if serviceErr != nil {
+		log.Warn().Err(serviceErr).Msg("Could not read OData service document")
+		warnings = append(warnings, fmt.Sprintf("Could not read service document: %s", serviceErr.Error()))
+	}
+	for _, entry := range serviceEntries {
+		endpoints[entry.Name] = &endpointInfo{
+			Name: entry.Name,
+			Kind: entry.Kind,
+			URL:  entry.URL,
 		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		resultJSON, _ := json.Marshal(map[string]interface{}{
-			"endpoints": commonEndpoints,
-			"count":     len(commonEndpoints),
-			"note":      "Common Business Central endpoints. Use bc_odata_get_metadata to discover all available endpoints.",
-		})
+	schema, schemaErr := s.loadSchema(ctx)
+	if schemaErr != nil {
+		log.Warn().Err(schemaErr).Msg("Could not load OData metadata")
+		warnings = append(warnings, fmt.Sprintf("Could not load metadata: %s", schemaErr.Error()))
+	}
+	if schema != nil {
+		addEndpoint := func(name, kind, entityType string) {
+			if ep, ok := endpoints[name]; ok {
+				if ep.EntityType == "" {
+					ep.EntityType = entityType
+				}
+				return
+			}
+			endpoints[name] = &endpointInfo{Name: name, Kind: kind, URL: name, EntityType: entityType}
+		}
+		for _, set := range schema.EntitySets {
+			addEndpoint(set.Name, "EntitySet", set.EntityType)
+		}
+		for _, singleton := range schema.Singletons {
+			addEndpoint(singleton.Name, "Singleton", singleton.Type)
+		}
+		for _, fi := range schema.FunctionImports {
+			addEndpoint(fi.Name, "FunctionImport", "")
+		}
+		for _, ai := range schema.ActionImports {
+			addEndpoint(ai.Name, "ActionImport", "")
+		}
+	}
+
+	if serviceErr != nil && schemaErr != nil {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
-			Result: ToolCallResult{
-				Content: []Content{
-					{
-						Type: "text",
-						Text: string(resultJSON),
-					},
-				},
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Endpoint discovery failed",
+				Data:    strings.Join(warnings, "; "),
 			},
 		}
 	}
 
-	var rootResponse map[string]interface{}
-	_ = json.Unmarshal(body, &rootResponse) // Ignore error, root response is optional
-
-	resultJSON, _ := json.Marshal(map[string]interface{}{
-		"endpoints":     commonEndpoints,
-		"count":         len(commonEndpoints),
-		"root_response": rootResponse,
-		"note":          "Common Business Central endpoints. Use bc_odata_get_metadata to discover all available endpoints and their structure.",
-		"discovery_tip": "Try querying endpoints with $top=1 to see their structure, or use bc_odata_get_metadata for complete schema information.",
+	result := make([]*endpointInfo, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if search != "" && !strings.Contains(strings.ToLower(ep.Name), strings.ToLower(search)) {
+			continue
+		}
+		if kind != "" && !strings.EqualFold(ep.Kind, kind) {
+			continue
+		}
+		result = append(result, ep)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
 	})
 
+	response := map[string]interface{}{
+		"endpoints": result,
+		"count":     len(result),
+		"total":     len(endpoints),
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	resultJSON, _ := json.Marshal(response)
+
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
@@ -806,57 +1278,19 @@ func (s *Server) handleListEndpoints(ctx context.Context, id interface{}, args m
 
 // handleGetMetadata retrieves OData metadata for endpoints
 func (s *Server) handleGetMetadata(ctx context.Context, id interface{}, args map[string]interface{}) *JSONRPCResponse {
-	// Business Central $metadata is at tenant/environment level, not company level
-	// The baseURL includes company, so we need to construct metadata URL differently
-	// Metadata URL format: {base}/v2.0/{tenant}/{environment}/ODataV4/$metadata
+	endpoint, _ := args["endpoint"].(string)
 
-	// Try to get metadata - if it fails, try to get structure from a sample query
-	endpoint := "$metadata"
-
-	resp, err := s.client.Get(ctx, endpoint)
+	schema, err := s.loadSchema(ctx)
 	if err != nil {
-		// If metadata endpoint fails, try to get structure from a sample query
-		// Query a known endpoint with $top=1 to infer structure
-		sampleEndpoint := "ODV_List"
-		if ep, ok := args["endpoint"].(string); ok && ep != "" {
-			sampleEndpoint = ep
-		}
-
-		// Get sample data to infer structure
-		results, queryErr := s.client.Query(ctx, sampleEndpoint+"?$top=1", false)
-		if queryErr != nil {
-			errorMsg := fmt.Sprintf("Failed to retrieve metadata and sample query also failed. Metadata error: %s, Query error: %s", err.Error(), queryErr.Error())
-			return &JSONRPCResponse{
-				JSONRPC: "2.0",
-				ID:      id,
-				Error: &JSONRPCError{
-					Code:    -32000,
-					Message: "Failed to get metadata",
-					Data:    errorMsg,
-				},
-			}
-		}
-
-		// Return inferred structure from sample
-		var sampleFields []string
-		if len(results) > 0 {
-			for key := range results[0] {
-				sampleFields = append(sampleFields, key)
-			}
-		}
-
-		var sampleRecord interface{}
-		if len(results) > 0 {
-			sampleRecord = results[0]
-		}
+		// If the metadata endpoint fails, try to get structure from a sample query
+		return s.inferMetadataFromSample(ctx, id, endpoint, err)
+	}
 
+	// Without an endpoint, return a compact overview of the whole service
+	if endpoint == "" {
 		resultJSON, _ := json.Marshal(map[string]interface{}{
-			"endpoint":      sampleEndpoint,
-			"metadata_note": "Could not access $metadata endpoint (may require tenant-level access). Showing inferred structure from sample query.",
-			"sample_fields": sampleFields,
-			"field_count":   len(sampleFields),
-			"sample_record": sampleRecord,
-			"tip":           "Use bc_odata_query with $top=1 on any endpoint to see its structure. Metadata endpoint may require different authentication scope.",
+			"summary": schema.Summary(),
+			"tip":     "Call bc_odata_get_metadata with an endpoint to get fields, types, keys and navigation properties of a single entity set.",
 		})
 
 		return &JSONRPCResponse{
@@ -872,29 +1306,85 @@ func (s *Server) handleGetMetadata(ctx context.Context, id interface{}, args map
 			},
 		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	description, err := schema.Describe(endpoint)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Failed to read metadata response: %s", err.Error())
+		errorMsg := err.Error()
+		if suggestions := metadata.Suggest(endpoint, schema.EntitySetNames(), 5); len(suggestions) > 0 {
+			errorMsg = fmt.Sprintf("%s. Did you mean: %s?", errorMsg, strings.Join(suggestions, ", "))
+		}
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32001,
+				Message: "Entity set not found",
+				Data:    errorMsg,
+			},
+		}
+	}
+
+	resultJSON, _ := json.Marshal(description)
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result: ToolCallResult{
+			Content: []Content{
+				{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		},
+	}
+}
+
+// inferMetadataFromSample infers an endpoint structure from a single record when
+// $metadata cannot be retrieved or parsed
+func (s *Server) inferMetadataFromSample(ctx context.Context, id interface{}, endpoint string, metadataErr error) *JSONRPCResponse {
+	// Query a known endpoint with $top=1 to infer structure
+	sampleEndpoint := "ODV_List"
+	if endpoint != "" {
+		sampleEndpoint = endpoint
+	}
+
+	// Get sample data to infer structure
+	results, queryErr := s.clientFor(ctx).Query(ctx, bc.NewQuery(sampleEndpoint).Top(1), false)
+	if queryErr != nil {
+		errorMsg := fmt.Sprintf("Failed to retrieve metadata and sample query also failed. Metadata error: %s, Query error: %s", metadataErr.Error(), queryErr.Error())
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
 			Error: &JSONRPCError{
 				Code:    -32000,
-				Message: "Failed to read response",
+				Message: "Failed to get metadata",
 				Data:    errorMsg,
 			},
 		}
 	}
 
-	// Metadata is typically XML, but we'll return it as text
-	// The LLM can parse it or we could add XML parsing later
+	// Return inferred structure from sample
+	var sampleFields []string
+	if len(results) > 0 {
+		for key := range results[0] {
+			sampleFields = append(sampleFields, key)
+		}
+	}
+
+	var sampleRecord interface{}
+	if len(results) > 0 {
+		sampleRecord = results[0]
+	}
+
 	resultJSON, _ := json.Marshal(map[string]interface{}{
-		"metadata":     string(body),
-		"content_type": resp.Header.Get("Content-Type"),
-		"size_bytes":   len(body),
-		"note":         "Metadata is in XML format. Contains all entity type definitions, properties, and relationships.",
+		"endpoint":       sampleEndpoint,
+		"metadata_note":  "Could not access $metadata endpoint (may require tenant-level access). Showing inferred structure from sample query.",
+		"metadata_error": metadataErr.Error(),
+		"sample_fields":  sampleFields,
+		"field_count":    len(sampleFields),
+		"sample_record":  sampleRecord,
+		"tip":            "Use bc_odata_query with $top=1 on any endpoint to see its structure. Metadata endpoint may require different authentication scope.",
 	})
 
 	return &JSONRPCResponse{
@@ -937,9 +1427,6 @@ func (s *Server) handleAggregate(ctx context.Context, id interface{}, args map[s
 		}
 	}
 
-	// Build OData query string with $apply for aggregations using proper URL encoding
-	queryParams := url.Values{}
-
 	// Build $apply expression
 	applyParts := []string{}
 	if groupby, ok := args["groupby"].(string); ok && groupby != "" {
@@ -947,17 +1434,22 @@ func (s *Server) handleAggregate(ctx context.Context, id interface{}, args map[s
 	}
 	applyParts = append(applyParts, fmt.Sprintf("aggregate(%s)", aggregate))
 
-	queryParams.Set("$apply", strings.Join(applyParts, "/"))
-
-	if filter, ok := args["filter"].(string); ok && filter != "" {
-		queryParams.Set("$filter", filter)
+	filter, err := s.queryFilter(ctx, endpoint, args["filter"])
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid filter",
+				Data:    err.Error(),
+			},
+		}
 	}
-
-	queryString := queryParams.Encode()
-	fullEndpoint := endpoint + "?" + queryString
+	query := bc.NewQuery(endpoint).Apply(strings.Join(applyParts, "/")).Filter(filter)
 
 	// Execute query
-	results, err := s.client.Query(ctx, fullEndpoint, false)
+	results, err := s.clientFor(ctx).Query(ctx, query, false)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Failed to execute aggregation on endpoint '%s': %s", endpoint, err.Error())
 		return &JSONRPCResponse{
@@ -1030,8 +1522,31 @@ func (s *Server) handleCreate(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
+	// Check the payload, including the nested entities of a deep insert, when
+	// the metadata describes the entity set
+	var (
+		entityType *metadata.EntityType
+		navigation []string
+	)
+	if schema, err := s.loadSchema(ctx); err != nil {
+		log.Warn().Err(err).Str("endpoint", endpoint).Msg("Creating entity without metadata validation")
+	} else if et, err := schema.EntityTypeOf(endpoint); err == nil {
+		if problems, _ := schema.ValidatePayload(endpoint, data); len(problems) > 0 {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      id,
+				Error: &JSONRPCError{
+					Code:    -32602,
+					Message: "Invalid params: data does not match the metadata",
+					Data:    strings.Join(problems, "; "),
+				},
+			}
+		}
+		entityType, navigation = et, nestedNavigation(et, data)
+	}
+
 	// Create entity using POST
-	result, err := s.client.Post(ctx, endpoint, jsonData)
+	result, err := s.clientFor(ctx).Post(ctx, endpoint, jsonData)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Failed to create entity in endpoint '%s': %s", endpoint, err.Error())
 		return &JSONRPCResponse{
@@ -1045,6 +1560,16 @@ func (s *Server) handleCreate(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
+	// A deep insert returns the header only; read it back with its lines
+	if len(navigation) > 0 && !hasExpanded(result, navigation) {
+		expanded, err := s.expandCreated(ctx, endpoint, entityType, result, navigation)
+		if err != nil {
+			log.Warn().Err(err).Str("endpoint", endpoint).Strs("expand", navigation).Msg("Created entity could not be read back with its nested entities")
+		} else {
+			result = expanded
+		}
+	}
+
 	resultJSON, _ := json.Marshal(result)
 	return &JSONRPCResponse{
 		JSONRPC: "2.0",
@@ -1074,8 +1599,8 @@ func (s *Server) handleUpdate(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
-	key, ok := args["key"].(string)
-	if !ok {
+	key, ok := args["key"]
+	if !ok || key == nil || key == "" {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
@@ -1112,19 +1637,58 @@ func (s *Server) handleUpdate(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
-	// Build endpoint with key
-	fullEndpoint := fmt.Sprintf("%s('%s')", endpoint, key)
+	fullEndpoint, err := s.keyedEndpoint(ctx, endpoint, key)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid key",
+				Data:    err.Error(),
+			},
+		}
+	}
 
-	// Get ETag if provided for optimistic concurrency
-	var etag string
-	if e, ok := args["etag"].(string); ok {
-		etag = e
+	// Check the payload when the metadata describes the entity set; nested
+	// entities cannot be written with a PATCH
+	if schema, err := s.loadSchema(ctx); err != nil {
+		log.Warn().Err(err).Str("endpoint", endpoint).Msg("Updating entity without metadata validation")
+	} else if problems, _ := schema.ValidateUpdate(endpoint, data); len(problems) > 0 {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: data does not match the metadata",
+				Data:    strings.Join(problems, "; "),
+			},
+		}
+	}
+
+	// Without an ETag, update the version read now so that a concurrent change
+	// fails with a conflict instead of being overwritten
+	etag, err := s.writeETag(ctx, fullEndpoint, args)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Update operation failed",
+				Data:    fmt.Sprintf("Failed to read the current ETag of entity '%v' in endpoint '%s': %s", key, endpoint, err.Error()),
+			},
+		}
 	}
 
 	// Update entity using PATCH
-	result, err := s.client.Patch(ctx, fullEndpoint, jsonData, etag)
+	result, err := s.clientFor(ctx).Patch(ctx, fullEndpoint, jsonData, etag)
+	var conflict *bc.ConflictError
+	if errors.As(err, &conflict) {
+		return conflictResponse(id, conflict, key, endpoint)
+	}
 	if err != nil {
-		errorMsg := fmt.Sprintf("Failed to update entity '%s' in endpoint '%s': %s", key, endpoint, err.Error())
+		errorMsg := fmt.Sprintf("Failed to update entity '%v' in endpoint '%s': %s", key, endpoint, err.Error())
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
@@ -1165,8 +1729,8 @@ func (s *Server) handleDelete(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
-	key, ok := args["key"].(string)
-	if !ok {
+	key, ok := args["key"]
+	if !ok || key == nil || key == "" {
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
@@ -1177,13 +1741,40 @@ func (s *Server) handleDelete(ctx context.Context, id interface{}, args map[stri
 		}
 	}
 
-	// Build endpoint with key
-	fullEndpoint := fmt.Sprintf("%s('%s')", endpoint, key)
+	fullEndpoint, err := s.keyedEndpoint(ctx, endpoint, key)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32602,
+				Message: "Invalid params: invalid key",
+				Data:    err.Error(),
+			},
+		}
+	}
+
+	etag, err := s.writeETag(ctx, fullEndpoint, args)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Delete operation failed",
+				Data:    fmt.Sprintf("Failed to read the current ETag of entity '%v' in endpoint '%s': %s", key, endpoint, err.Error()),
+			},
+		}
+	}
 
 	// Delete entity using DELETE
-	err := s.client.Delete(ctx, fullEndpoint)
+	err = s.clientFor(ctx).Delete(ctx, fullEndpoint, etag)
+	var conflict *bc.ConflictError
+	if errors.As(err, &conflict) {
+		return conflictResponse(id, conflict, key, endpoint)
+	}
 	if err != nil {
-		errorMsg := fmt.Sprintf("Failed to delete entity '%s' from endpoint '%s': %s", key, endpoint, err.Error())
+		errorMsg := fmt.Sprintf("Failed to delete entity '%v' from endpoint '%s': %s", key, endpoint, err.Error())
 		return &JSONRPCResponse{
 			JSONRPC: "2.0",
 			ID:      id,
@@ -1197,7 +1788,7 @@ func (s *Server) handleDelete(ctx context.Context, id interface{}, args map[stri
 
 	resultJSON, _ := json.Marshal(map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Entity '%s' deleted successfully from endpoint '%s'", key, endpoint),
+		"message": fmt.Sprintf("Entity '%v' deleted successfully from endpoint '%s'", key, endpoint),
 	})
 
 	return &JSONRPCResponse{
@@ -1214,6 +1805,35 @@ func (s *Server) handleDelete(ctx context.Context, id interface{}, args map[stri
 	}
 }
 
+// writeETag returns the If-Match value of an update or delete: the etag
+// argument when given ("*" writes any version), otherwise the current ETag
+func (s *Server) writeETag(ctx context.Context, endpoint string, args map[string]interface{}) (string, error) {
+	if etag, _ := args["etag"].(string); etag != "" {
+		return etag, nil
+	}
+	etag, _, err := s.clientFor(ctx).Version(ctx, endpoint)
+	return etag, err
+}
+
+// conflictResponse reports a write rejected because the entity changed,
+// with the current version so the agent can review it and retry
+func conflictResponse(id interface{}, conflict *bc.ConflictError, key interface{}, endpoint string) *JSONRPCResponse {
+	data, _ := json.Marshal(map[string]interface{}{
+		"message":      fmt.Sprintf("Entity '%v' in endpoint '%s' was changed by someone else since its ETag was read. Review the current version and retry with current_etag, or with etag \"*\" to overwrite it.", key, endpoint),
+		"current_etag": conflict.ETag,
+		"current":      conflict.Current,
+	})
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &JSONRPCError{
+			Code:    -32003,
+			Message: "Conflict: the entity was modified",
+			Data:    string(data),
+		},
+	}
+}
+
 // handleCheckOrderStatus intelligently checks the status of a sales order
 // Logic:
 // 1. Check ODV_List first - if found, order is NOT invoiced
@@ -1235,13 +1855,9 @@ func (s *Server) handleCheckOrderStatus(ctx context.Context, id interface{}, arg
 
 	// Step 1: Check ODV_List first
 	// If order is found in ODV_List, it means it's NOT invoiced
-	queryParams := url.Values{}
-	escapedOrderNo := strings.ReplaceAll(orderNo, "'", "''")
-	queryParams.Set("$filter", fmt.Sprintf("No eq '%s'", escapedOrderNo))
-	queryParams.Set("$top", "1")
-	odvEndpoint := "ODV_List?" + queryParams.Encode()
+	odvQuery := bc.NewQuery("ODV_List").Filter(bc.Eq("No", orderNo)).Top(1)
 
-	odvResults, err := s.client.Query(ctx, odvEndpoint, false)
+	odvResults, err := s.clientFor(ctx).Query(ctx, odvQuery, false)
 	if err != nil {
 		// If ODV_List query fails, we'll still try invoices
 		// Log the error but continue
@@ -1275,19 +1891,13 @@ func (s *Server) handleCheckOrderStatus(ctx context.Context, id interface{}, arg
 
 	// Step 2: Order not found in ODV_List, check invoices
 	// Try BI_Invoices first (Business Intelligence endpoint)
-	queryParams = url.Values{}
-	queryParams.Set("$filter", fmt.Sprintf("Order_No eq '%s'", escapedOrderNo))
-	queryParams.Set("$top", "1")
-	invoiceEndpoint := "BI_Invoices?" + queryParams.Encode()
+	invoiceQuery := bc.NewQuery("BI_Invoices").Filter(bc.Eq("Order_No", orderNo)).Top(1)
 
-	invoiceResults, err := s.client.Query(ctx, invoiceEndpoint, false)
+	invoiceResults, err := s.clientFor(ctx).Query(ctx, invoiceQuery, false)
 	if err != nil || len(invoiceResults) == 0 {
 		// If BI_Invoices fails or returns nothing, try SalesInvoices
-		queryParams = url.Values{}
-		queryParams.Set("$filter", fmt.Sprintf("Order_No eq '%s'", escapedOrderNo))
-		queryParams.Set("$top", "1")
-		invoiceEndpoint = "SalesInvoices?" + queryParams.Encode()
-		invoiceResults, _ = s.client.Query(ctx, invoiceEndpoint, false)
+		invoiceQuery = bc.NewQuery("SalesInvoices").Filter(bc.Eq("Order_No", orderNo)).Top(1)
+		invoiceResults, _ = s.clientFor(ctx).Query(ctx, invoiceQuery, false)
 	}
 
 	if len(invoiceResults) > 0 {
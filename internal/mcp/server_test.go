@@ -3,6 +3,9 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/iafnetworkspa/bc-odata-mcp/internal/bc"
@@ -372,3 +375,279 @@ func TestServer_handleDelete_InvalidParams(t *testing.T) {
 		t.Errorf("Error code = %v, want -32602", response.Error.Code)
 	}
 }
+
+const testMetadata = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EntityType Name="Customer">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" MaxLength="20" />
+        <Property Name="Name" Type="Edm.String" />
+      </EntityType>
+      <EntityType Name="SalesInvoice">
+        <Key><PropertyRef Name="No" /></Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" />
+      </EntityType>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="Customers" EntityType="NAV.Customer" />
+        <EntitySet Name="SalesInvoices" EntityType="NAV.SalesInvoice" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+// newTestServer returns a server wired to a mock OAuth server and the given OData handler
+func newTestServer(t *testing.T, handler http.HandlerFunc) *Server {
+	t.Helper()
+	return newTestServerWithConfig(t, handler, nil)
+}
+
+// newTestServerWithConfig is newTestServer with a hook to adjust the client
+// configuration; the hook sees BasePath set to the test OData server root
+func newTestServerWithConfig(t *testing.T, handler http.HandlerFunc, configure func(*bc.Config)) *Server {
+	t.Helper()
+
+	oauthServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(oauthServer.Close)
+
+	odataServer := httptest.NewServer(handler)
+	t.Cleanup(odataServer.Close)
+
+	cfg := bc.Config{
+		GrantType:    "client_credentials",
+		ClientID:     "test-client-id",
+		ClientSecret: "test-client-secret",
+		ScopeAPI:     "https://api.businesscentral.dynamics.com/.default",
+		TokenURL:     oauthServer.URL,
+		ContentType:  "application/x-www-form-urlencoded",
+		BasePath:     odataServer.URL + "/",
+		APITimeout:   90,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	return server
+}
+
+// resultText returns the text content of a successful tool call response
+func resultText(t *testing.T, response *JSONRPCResponse) string {
+	t.Helper()
+
+	if response == nil {
+		t.Fatal("response is nil")
+	}
+	if response.Error != nil {
+		t.Fatalf("unexpected error: %s (%s)", response.Error.Message, response.Error.Data)
+	}
+	result, ok := response.Result.(ToolCallResult)
+	if !ok || len(result.Content) == 0 {
+		t.Fatalf("unexpected result: %#v", response.Result)
+	}
+	return result.Content[0].Text
+}
+
+func TestServer_handleGetMetadata_EntitySet(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testMetadata))
+	})
+
+	ctx := context.Background()
+	text := resultText(t, server.handleGetMetadata(ctx, 1, map[string]interface{}{"endpoint": "Customers"}))
+
+	var desc struct {
+		EntitySet string   `json:"entity_set"`
+		Keys      []string `json:"keys"`
+		Fields    []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(text), &desc); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if desc.EntitySet != "Customers" || len(desc.Keys) != 1 || len(desc.Fields) != 2 {
+		t.Errorf("unexpected description: %s", text)
+	}
+
+	response := server.handleGetMetadata(ctx, 2, map[string]interface{}{"endpoint": "Custmers"})
+	if response.Error == nil || !strings.Contains(response.Error.Data, "Customers") {
+		t.Errorf("expected not-found error suggesting Customers, got %#v", response.Error)
+	}
+}
+
+func TestServer_handleListEndpoints_Discovery(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testMetadata))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"name":"Customers","kind":"EntitySet","url":"Customers"},{"name":"ODV_List","kind":"EntitySet","url":"ODV_List"}]}`))
+	})
+
+	ctx := context.Background()
+	text := resultText(t, server.handleListEndpoints(ctx, 1, map[string]interface{}{}))
+
+	var result struct {
+		Endpoints []struct {
+			Name       string `json:"name"`
+			Kind       string `json:"kind"`
+			EntityType string `json:"entity_type"`
+		} `json:"endpoints"`
+		Count int `json:"count"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if result.Count != 3 {
+		t.Fatalf("count = %d, want 3 (%s)", result.Count, text)
+	}
+	if result.Endpoints[0].Name != "Customers" || result.Endpoints[0].EntityType != "NAV.Customer" {
+		t.Errorf("first endpoint = %+v, want Customers with entity type", result.Endpoints[0])
+	}
+
+	text = resultText(t, server.handleListEndpoints(ctx, 2, map[string]interface{}{"search": "invoice"}))
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if result.Count != 1 || result.Endpoints[0].Name != "SalesInvoices" {
+		t.Errorf("search=invoice returned %s", text)
+	}
+}
+
+func TestServer_handleODataQuery_PaginateBudget(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testMetadata))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("$skiptoken") == "" {
+			_, _ = w.Write([]byte(`{"value":[{"No":"1"},{"No":"2"}],"@odata.nextLink":"http://` + r.Host + `/Customers?$skiptoken=2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"value":[{"No":"3"},{"No":"4"}],"@odata.nextLink":"http://` + r.Host + `/Customers?$skiptoken=4"}`))
+	})
+
+	text := resultText(t, server.handleODataQuery(context.Background(), 1, map[string]interface{}{
+		"endpoint":    "Customers",
+		"paginate":    true,
+		"max_records": float64(3),
+	}))
+
+	var result struct {
+		Count     int  `json:"count"`
+		Truncated bool `json:"truncated"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if result.Count != 3 || !result.Truncated {
+		t.Errorf("count = %d, truncated = %v; want 3, true", result.Count, result.Truncated)
+	}
+}
+
+func TestServer_handleODataQuery_SkipPagingOrdersByKey(t *testing.T) {
+	var orderBy []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "$metadata") {
+			_, _ = w.Write([]byte(testMetadata))
+			return
+		}
+		orderBy = append(orderBy, r.URL.Query().Get("$orderby"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[{"No":"1"}]}`))
+	})
+
+	resultText(t, server.handleODataQuery(context.Background(), 1, map[string]interface{}{
+		"endpoint": "Customers",
+		"orderby":  "Name desc",
+		"paginate": true,
+		"paging":   "skip",
+	}))
+
+	if len(orderBy) != 1 || orderBy[0] != "Name desc,No" {
+		t.Errorf("$orderby = %v, want [Name desc,No]", orderBy)
+	}
+}
+
+func TestServer_AllowedTools(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	if err := server.SetPolicy(Policy{AllowTools: []string{"bc_odata_count", "bc_odata_list_profiles"}}); err != nil {
+		t.Fatalf("SetPolicy() error = %v", err)
+	}
+
+	response := server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	list, ok := response.Result.(ToolsListResult)
+	if !ok || len(list.Tools) != 2 || list.Tools[0].Name != "bc_odata_count" {
+		t.Errorf("tools/list = %+v, want only the allowed tools", response.Result)
+	}
+
+	response = server.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers"}}}`))
+	if response.Error == nil || response.Error.Code != -32601 {
+		t.Errorf("tools/call = %+v, want tool not allowed", response)
+	}
+}
+
+func TestServer_WriteETags(t *testing.T) {
+	var ifMatch []string
+	version := `W/"1"`
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"@odata.etag":` + jsonString(version) + `,"No":"10000","Name":"Adatum"}`))
+			return
+		}
+		ifMatch = append(ifMatch, r.Method+" "+r.Header.Get("If-Match"))
+		if r.Header.Get("If-Match") != version && r.Header.Get("If-Match") != "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`{"error":{"code":"Request_EntityChanged","message":"Another user has already changed the record."}}`))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		version = `W/"2"`
+		_, _ = w.Write([]byte(`{"@odata.etag":` + jsonString(version) + `,"No":"10000","Name":"Adatum Corporation"}`))
+	})
+	ctx := context.Background()
+
+	// Without an etag argument the current ETag is read and sent as If-Match
+	text := resultText(t, server.handleUpdate(ctx, 1, map[string]interface{}{
+		"endpoint": "Customers", "key": "10000", "data": map[string]interface{}{"Name": "Adatum Corporation"},
+	}))
+	if !strings.Contains(text, `"@odata.etag":"W/\"2\""`) {
+		t.Errorf("update result = %s, want the new ETag", text)
+	}
+
+	// A stale etag is a conflict carrying the current version
+	response := server.handleDelete(ctx, 2, map[string]interface{}{"endpoint": "Customers", "key": "10000", "etag": `W/"1"`})
+	if response.Error == nil || response.Error.Code != -32003 || !strings.Contains(response.Error.Data, `"current_etag":"W/\"2\""`) {
+		t.Errorf("stale delete = %+v, want a conflict with the current ETag", response.Error)
+	}
+
+	if response := server.handleDelete(ctx, 3, map[string]interface{}{"endpoint": "Customers", "key": "10000", "etag": "*"}); response.Error != nil {
+		t.Errorf("delete with etag * = %+v", response.Error)
+	}
+	want := []string{`PATCH W/"1"`, `DELETE W/"1"`, "DELETE *"}
+	if strings.Join(ifMatch, "|") != strings.Join(want, "|") {
+		t.Errorf("If-Match = %q, want %q", ifMatch, want)
+	}
+}
+
+// jsonString encodes a value as a JSON string literal
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Transport carries JSON-RPC messages between MCP clients and a Handler
+type Transport interface {
+	// Serve delivers incoming messages to the handler until the clients go
+	// away or ctx is cancelled
+	Serve(ctx context.Context, handler Handler) error
+}
+
+// Handler processes a single JSON-RPC message. It returns nil when no
+// response must be sent, as for notifications.
+type Handler interface {
+	HandleMessage(ctx context.Context, message []byte) *JSONRPCResponse
+}
+
+// Dispatcher is a Handler that splits a message into a step run in arrival
+// order and the handling proper, which may run concurrently with other
+// messages. Transports that handle messages concurrently use it when the
+// handler provides it.
+type Dispatcher interface {
+	Handler
+	Dispatch(ctx context.Context, message []byte) func() *JSONRPCResponse
+}
+
+// StdioTransport exchanges newline-delimited JSON-RPC messages over a pair of streams
+type StdioTransport struct {
+	in  io.Reader
+	out io.Writer
+	mu  sync.Mutex
+}
+
+// NewStdioTransport creates a transport reading requests from in and writing responses to out
+func NewStdioTransport(in io.Reader, out io.Writer) *StdioTransport {
+	return &StdioTransport{in: in, out: out}
+}
+
+// Serve reads messages until in is exhausted or ctx is cancelled. Each message
+// is handled on its own goroutine, so a slow call does not hold up the others;
+// with a Dispatcher, the part that depends on message order runs first, in the
+// order messages were read. Serve returns once the calls in flight have answered.
+func (t *StdioTransport) Serve(ctx context.Context, handler Handler) error {
+	// Notifications share the output stream with responses. The context is
+	// set up before the reader starts, which reads it.
+	ctx = withNotifier(ctx, func(notification *JSONRPCNotification) {
+		_ = t.write(notification)
+	})
+
+	// Reads block, so they run apart from the loop to let cancellation through
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(t.in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if message := bytes.TrimSpace(line); len(message) > 0 {
+				select {
+				case lines <- message:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	writeErr := make(chan error, 1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-writeErr:
+			return err
+		case err := <-readErr:
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read request: %w", err)
+		case message := <-lines:
+			handle := func() *JSONRPCResponse { return handler.HandleMessage(ctx, message) }
+			if dispatcher, ok := handler.(Dispatcher); ok {
+				if handle = dispatcher.Dispatch(ctx, message); handle == nil {
+					continue
+				}
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if response := handle(); response != nil {
+					if err := t.write(response); err != nil {
+						select {
+						case writeErr <- err:
+						default:
+						}
+					}
+				}
+			}()
+		}
+	}
+}
+
+// write encodes one message on its own line; writes are serialized so
+// concurrent responses never interleave
+func (t *StdioTransport) write(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
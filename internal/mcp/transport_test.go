@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStdioTransport_Serve(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"1.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"unknown/method"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/list"}`,
+	}, "\n")
+	var out bytes.Buffer
+
+	if err := NewStdioTransport(strings.NewReader(input), &out).Serve(context.Background(), server); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	// Requests run concurrently, so responses are matched by ID
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d responses, want 4:\n%s", len(lines), out.String())
+	}
+	wantCodes := map[float64]int{1: 0, 2: -32600, 3: -32601, 4: 0}
+	for i, line := range lines {
+		var response struct {
+			ID     float64 `json:"id"`
+			Result struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			} `json:"result"`
+			Error *JSONRPCError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			t.Fatalf("response %d is not JSON: %v", i, err)
+		}
+		code := 0
+		if response.Error != nil {
+			code = response.Error.Code
+		}
+		if code != wantCodes[response.ID] {
+			t.Errorf("response %v error code = %d, want %d", response.ID, code, wantCodes[response.ID])
+		}
+		if response.ID == 1 && response.Result.ProtocolVersion != "2024-11-05" {
+			t.Errorf("protocolVersion = %q, want the client's 2024-11-05", response.Result.ProtocolVersion)
+		}
+	}
+}
+
+func TestStdioTransport_Cancel(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// A pipe that never delivers input: only cancellation can end Serve
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := &blockingReader{done: make(chan struct{})}
+	defer close(in.done)
+
+	if err := NewStdioTransport(in, &bytes.Buffer{}).Serve(ctx, server); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
+
+type blockingReader struct {
+	done chan struct{}
+}
+
+func (r *blockingReader) Read(p []byte) (int, error) {
+	<-r.done
+	return 0, context.Canceled
+}
+
+// handlerFunc adapts a function to the Handler interface
+type handlerFunc func(ctx context.Context, message []byte) *JSONRPCResponse
+
+func (f handlerFunc) HandleMessage(ctx context.Context, message []byte) *JSONRPCResponse {
+	return f(ctx, message)
+}
+
+func TestStdioTransport_ConcurrentRequests(t *testing.T) {
+	// The slow request only finishes once the fast one, sent after it, has been handled
+	fastDone := make(chan struct{})
+	handler := handlerFunc(func(ctx context.Context, message []byte) *JSONRPCResponse {
+		var request JSONRPCRequest
+		_ = json.Unmarshal(message, &request)
+		switch request.Method {
+		case "slow":
+			select {
+			case <-fastDone:
+			case <-time.After(5 * time.Second):
+				return &JSONRPCResponse{JSONRPC: "2.0", ID: request.ID, Error: &JSONRPCError{Code: -32000, Message: "blocked"}}
+			}
+		case "fast":
+			defer close(fastDone)
+		}
+		return &JSONRPCResponse{JSONRPC: "2.0", ID: request.ID, Result: request.Method}
+	})
+
+	input := `{"jsonrpc":"2.0","id":1,"method":"slow"}` + "\n" + `{"jsonrpc":"2.0","id":2,"method":"fast"}` + "\n"
+	var out bytes.Buffer
+	if err := NewStdioTransport(strings.NewReader(input), &out).Serve(context.Background(), handler); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"fast"`) || !strings.Contains(lines[1], `"slow"`) {
+		t.Errorf("responses = %q, want fast answered before slow", lines)
+	}
+}
+
+func TestStdioTransport_CancelFollowingRequest(t *testing.T) {
+	server := newTestServer(t, rateLimited(nil))
+
+	// The cancellation is read right after its request and must still find it
+	var input strings.Builder
+	for id := 1; id <= 20; id++ {
+		fmt.Fprintf(&input, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers"}}}`+"\n", id)
+		fmt.Fprintf(&input, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":%d}}`+"\n", id)
+	}
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- NewStdioTransport(strings.NewReader(input.String()), &out).Serve(context.Background(), server)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Serve() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("cancelled requests kept running")
+	}
+	if out.Len() != 0 {
+		t.Errorf("cancelled requests got responses: %s", out.String())
+	}
+}
+
+func TestServer_MaxConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"value":[]}`))
+	})
+	server.SetMaxConcurrency(2)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 6; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			message := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"bc_odata_query","arguments":{"endpoint":"Customers"}}}`, id)
+			if response := server.HandleMessage(context.Background(), []byte(message)); response == nil || response.Error != nil {
+				t.Errorf("request %d failed: %+v", id, response)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("peak concurrent requests = %d, want at most 2", peak)
+	}
+}
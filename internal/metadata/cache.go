@@ -0,0 +1,93 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LoadFunc fetches and parses the schema for a cache key
+type LoadFunc func(ctx context.Context) (*Schema, error)
+
+// Cache holds parsed schemas per environment so $metadata is only downloaded
+// and parsed once per TTL window. Concurrent callers of a missing or expired
+// key share a single load.
+type Cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	loading map[string]*loadCall
+}
+
+type cacheEntry struct {
+	schema    *Schema
+	fetchedAt time.Time
+}
+
+// loadCall is a load in flight; done is closed once schema and err are set
+type loadCall struct {
+	done   chan struct{}
+	schema *Schema
+	err    error
+}
+
+// NewCache creates a schema cache. A ttl of zero keeps entries until invalidated.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+		loading: make(map[string]*loadCall),
+	}
+}
+
+// Get returns the cached schema for key, calling load when it is missing or
+// expired. Callers arriving while a load is in flight wait for its result.
+func (c *Cache) Get(ctx context.Context, key string, load LoadFunc) (*Schema, error) {
+	for {
+		c.mu.Lock()
+		if entry, ok := c.entries[key]; ok && (c.ttl == 0 || time.Since(entry.fetchedAt) < c.ttl) {
+			c.mu.Unlock()
+			return entry.schema, nil
+		}
+		if call, ok := c.loading[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// The load was cut short by its caller's context, not ours: try again
+			if isContextError(call.err) && ctx.Err() == nil {
+				continue
+			}
+			return call.schema, call.err
+		}
+		call := &loadCall{done: make(chan struct{})}
+		c.loading[key] = call
+		c.mu.Unlock()
+
+		call.schema, call.err = load(ctx)
+
+		c.mu.Lock()
+		delete(c.loading, key)
+		if call.err == nil {
+			c.entries[key] = cacheEntry{schema: call.schema, fetchedAt: time.Now()}
+		}
+		c.mu.Unlock()
+		close(call.done)
+
+		return call.schema, call.err
+	}
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Invalidate drops the cached schema for key
+func (c *Cache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
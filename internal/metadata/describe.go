@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EntitySetDescription is a compact, JSON-friendly view of an entity set,
+// small enough to hand to an LLM instead of the full $metadata document.
+type EntitySetDescription struct {
+	EntitySet    string                  `json:"entity_set"`
+	EntityType   string                  `json:"entity_type"`
+	Keys         []string                `json:"keys"`
+	Fields       []FieldDescription      `json:"fields"`
+	Navigation   []NavigationDescription `json:"navigation,omitempty"`
+	BoundActions []string                `json:"bound_actions,omitempty"`
+}
+
+// FieldDescription describes a single structural property
+type FieldDescription struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Nullable  bool     `json:"nullable"`
+	Key       bool     `json:"key,omitempty"`
+	MaxLength string   `json:"max_length,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+}
+
+// NavigationDescription describes a navigation property and its target
+type NavigationDescription struct {
+	Name       string `json:"name"`
+	TargetType string `json:"target_type"`
+	Collection bool   `json:"collection"`
+	EntitySet  string `json:"entity_set,omitempty"`
+}
+
+// SchemaSummary is a compact overview of the whole service
+type SchemaSummary struct {
+	Namespace       string   `json:"namespace"`
+	EntitySets      []string `json:"entity_sets"`
+	Singletons      []string `json:"singletons,omitempty"`
+	ActionImports   []string `json:"action_imports,omitempty"`
+	FunctionImports []string `json:"function_imports,omitempty"`
+	EntityTypeCount int      `json:"entity_type_count"`
+	EnumTypeCount   int      `json:"enum_type_count"`
+}
+
+// Describe builds the compact description of an entity set
+func (s *Schema) Describe(entitySet string) (*EntitySetDescription, error) {
+	set, ok := s.EntitySet(entitySet)
+	if !ok {
+		return nil, fmt.Errorf("entity set '%s' not found in metadata", entitySet)
+	}
+	et, err := s.EntityTypeOf(set.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &EntitySetDescription{
+		EntitySet:  set.Name,
+		EntityType: et.QualifiedName,
+		Keys:       append([]string{}, et.Key...),
+	}
+
+	keys := make(map[string]bool, len(et.Key))
+	for _, k := range et.Key {
+		keys[k] = true
+	}
+
+	for _, p := range et.Properties {
+		field := FieldDescription{
+			Name:      p.Name,
+			Type:      p.Type,
+			Nullable:  p.Nullable,
+			Key:       keys[p.Name],
+			MaxLength: p.MaxLength,
+		}
+		if en, ok := s.EnumType(p.Type); ok {
+			for _, m := range en.Members {
+				field.Enum = append(field.Enum, m.Name)
+			}
+		}
+		desc.Fields = append(desc.Fields, field)
+	}
+
+	for _, n := range et.NavigationProperties {
+		desc.Navigation = append(desc.Navigation, NavigationDescription{
+			Name:       n.Name,
+			TargetType: n.TargetType(),
+			Collection: n.IsCollection(),
+			EntitySet:  set.NavigationBindings[n.Name],
+		})
+	}
+
+	for _, a := range s.Actions {
+		if !a.IsBound || len(a.Parameters) == 0 {
+			continue
+		}
+		if s.qualify(unwrapCollection(a.Parameters[0].Type)) == et.QualifiedName {
+			desc.BoundActions = append(desc.BoundActions, a.Name)
+		}
+	}
+
+	return desc, nil
+}
+
+// Summary returns a compact overview of the entity container
+func (s *Schema) Summary() *SchemaSummary {
+	summary := &SchemaSummary{
+		Namespace:       s.Namespace,
+		EntitySets:      s.EntitySetNames(),
+		EntityTypeCount: len(s.EntityTypes),
+		EnumTypeCount:   len(s.EnumTypes),
+	}
+	for name := range s.Singletons {
+		summary.Singletons = append(summary.Singletons, name)
+	}
+	sort.Strings(summary.Singletons)
+	for _, ai := range s.ActionImports {
+		summary.ActionImports = append(summary.ActionImports, ai.Name)
+	}
+	for _, fi := range s.FunctionImports {
+		summary.FunctionImports = append(summary.FunctionImports, fi.Name)
+	}
+	return summary
+}
+
+// Suggest returns up to limit candidates that are close to name, best match first.
+// Matching is case-insensitive and combines substring containment with edit distance.
+func Suggest(name string, candidates []string, limit int) []string {
+	type scored struct {
+		name  string
+		score int
+	}
+	lower := strings.ToLower(name)
+	threshold := len(name)/2 + 1
+
+	var matches []scored
+	for _, c := range candidates {
+		lc := strings.ToLower(c)
+		switch {
+		case lc == lower:
+			matches = append(matches, scored{c, 0})
+		case strings.Contains(lc, lower) || strings.Contains(lower, lc):
+			matches = append(matches, scored{c, 1})
+		default:
+			if d := levenshtein(lower, lc); d <= threshold {
+				matches = append(matches, scored{c, d + 1})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score < matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var result []string
+	for i := 0; i < len(matches) && i < limit; i++ {
+		result = append(result, matches[i].name)
+	}
+	return result
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
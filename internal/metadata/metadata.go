@@ -0,0 +1,514 @@
+package metadata
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Schema is the parsed representation of an OData $metadata (EDMX/CSDL) document.
+// Types are indexed by their namespace-qualified name (e.g. "NAV.Customer").
+type Schema struct {
+	Namespace       string
+	EntityTypes     map[string]*EntityType
+	ComplexTypes    map[string]*ComplexType
+	EnumTypes       map[string]*EnumType
+	EntitySets      map[string]*EntitySet
+	Singletons      map[string]*Singleton
+	Actions         []*Operation
+	Functions       []*Operation
+	ActionImports   []OperationImport
+	FunctionImports []OperationImport
+
+	aliases map[string]string
+}
+
+// EntityType describes an EDM entity type
+type EntityType struct {
+	Name                 string
+	QualifiedName        string
+	BaseType             string
+	Key                  []string
+	Properties           []*Property
+	NavigationProperties []*NavigationProperty
+}
+
+// ComplexType describes an EDM complex type
+type ComplexType struct {
+	Name          string
+	QualifiedName string
+	Properties    []*Property
+}
+
+// Property describes a structural property of an entity or complex type
+type Property struct {
+	Name      string
+	Type      string
+	Nullable  bool
+	MaxLength string
+	Precision string
+	Scale     string
+}
+
+// NavigationProperty describes a relationship to another entity type
+type NavigationProperty struct {
+	Name           string
+	Type           string
+	Nullable       bool
+	Partner        string
+	ContainsTarget bool
+}
+
+// EnumType describes an EDM enumeration type
+type EnumType struct {
+	Name          string
+	QualifiedName string
+	IsFlags       bool
+	Members       []EnumMember
+}
+
+// EnumMember is a single named value of an enumeration
+type EnumMember struct {
+	Name  string
+	Value string
+}
+
+// EntitySet is an entity set exposed by the entity container
+type EntitySet struct {
+	Name       string
+	EntityType string
+	// NavigationBindings maps navigation property paths to target entity sets
+	NavigationBindings map[string]string
+}
+
+// Singleton is a single entity exposed by the entity container
+type Singleton struct {
+	Name string
+	Type string
+}
+
+// Operation describes an action or function declared in the schema
+type Operation struct {
+	Name       string
+	IsBound    bool
+	Parameters []Parameter
+	ReturnType string
+}
+
+// Parameter is a single parameter of an action or function
+type Parameter struct {
+	Name     string
+	Type     string
+	Nullable bool
+}
+
+// OperationImport is an action or function import exposed by the entity container
+type OperationImport struct {
+	Name      string
+	Operation string
+	EntitySet string
+}
+
+// Raw XML shapes. Element names are matched by local name, so the edmx and edm
+// namespace prefixes used by Business Central do not need to be spelled out.
+
+type xmlEdmx struct {
+	DataServices struct {
+		Schemas []xmlSchema `xml:"Schema"`
+	} `xml:"DataServices"`
+}
+
+type xmlSchema struct {
+	Namespace    string           `xml:"Namespace,attr"`
+	Alias        string           `xml:"Alias,attr"`
+	EntityTypes  []xmlEntityType  `xml:"EntityType"`
+	ComplexTypes []xmlComplexType `xml:"ComplexType"`
+	EnumTypes    []xmlEnumType    `xml:"EnumType"`
+	Actions      []xmlOperation   `xml:"Action"`
+	Functions    []xmlOperation   `xml:"Function"`
+	Containers   []xmlContainer   `xml:"EntityContainer"`
+}
+
+type xmlEntityType struct {
+	Name     string `xml:"Name,attr"`
+	BaseType string `xml:"BaseType,attr"`
+	Key      struct {
+		PropertyRefs []struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"PropertyRef"`
+	} `xml:"Key"`
+	Properties           []xmlProperty           `xml:"Property"`
+	NavigationProperties []xmlNavigationProperty `xml:"NavigationProperty"`
+}
+
+type xmlComplexType struct {
+	Name       string        `xml:"Name,attr"`
+	Properties []xmlProperty `xml:"Property"`
+}
+
+type xmlProperty struct {
+	Name      string `xml:"Name,attr"`
+	Type      string `xml:"Type,attr"`
+	Nullable  string `xml:"Nullable,attr"`
+	MaxLength string `xml:"MaxLength,attr"`
+	Precision string `xml:"Precision,attr"`
+	Scale     string `xml:"Scale,attr"`
+}
+
+type xmlNavigationProperty struct {
+	Name           string `xml:"Name,attr"`
+	Type           string `xml:"Type,attr"`
+	Nullable       string `xml:"Nullable,attr"`
+	Partner        string `xml:"Partner,attr"`
+	ContainsTarget string `xml:"ContainsTarget,attr"`
+}
+
+type xmlEnumType struct {
+	Name    string `xml:"Name,attr"`
+	IsFlags string `xml:"IsFlags,attr"`
+	Members []struct {
+		Name  string `xml:"Name,attr"`
+		Value string `xml:"Value,attr"`
+	} `xml:"Member"`
+}
+
+type xmlOperation struct {
+	Name       string `xml:"Name,attr"`
+	IsBound    string `xml:"IsBound,attr"`
+	Parameters []struct {
+		Name     string `xml:"Name,attr"`
+		Type     string `xml:"Type,attr"`
+		Nullable string `xml:"Nullable,attr"`
+	} `xml:"Parameter"`
+	ReturnType struct {
+		Type string `xml:"Type,attr"`
+	} `xml:"ReturnType"`
+}
+
+type xmlContainer struct {
+	Name       string `xml:"Name,attr"`
+	EntitySets []struct {
+		Name       string `xml:"Name,attr"`
+		EntityType string `xml:"EntityType,attr"`
+		Bindings   []struct {
+			Path   string `xml:"Path,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"NavigationPropertyBinding"`
+	} `xml:"EntitySet"`
+	Singletons []struct {
+		Name string `xml:"Name,attr"`
+		Type string `xml:"Type,attr"`
+	} `xml:"Singleton"`
+	ActionImports []struct {
+		Name      string `xml:"Name,attr"`
+		Action    string `xml:"Action,attr"`
+		EntitySet string `xml:"EntitySet,attr"`
+	} `xml:"ActionImport"`
+	FunctionImports []struct {
+		Name      string `xml:"Name,attr"`
+		Function  string `xml:"Function,attr"`
+		EntitySet string `xml:"EntitySet,attr"`
+	} `xml:"FunctionImport"`
+}
+
+// Parse reads an EDMX document and builds the schema model
+func Parse(r io.Reader) (*Schema, error) {
+	var doc xmlEdmx
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode EDMX document: %w", err)
+	}
+	if len(doc.DataServices.Schemas) == 0 {
+		return nil, fmt.Errorf("EDMX document contains no schema")
+	}
+
+	schema := &Schema{
+		EntityTypes:  make(map[string]*EntityType),
+		ComplexTypes: make(map[string]*ComplexType),
+		EnumTypes:    make(map[string]*EnumType),
+		EntitySets:   make(map[string]*EntitySet),
+		Singletons:   make(map[string]*Singleton),
+		aliases:      make(map[string]string),
+	}
+
+	for _, xs := range doc.DataServices.Schemas {
+		if schema.Namespace == "" {
+			schema.Namespace = xs.Namespace
+		}
+		if xs.Alias != "" {
+			schema.aliases[xs.Alias] = xs.Namespace
+		}
+
+		for _, xe := range xs.EntityTypes {
+			et := &EntityType{
+				Name:          xe.Name,
+				QualifiedName: xs.Namespace + "." + xe.Name,
+				BaseType:      xe.BaseType,
+			}
+			for _, ref := range xe.Key.PropertyRefs {
+				et.Key = append(et.Key, ref.Name)
+			}
+			for _, xp := range xe.Properties {
+				et.Properties = append(et.Properties, newProperty(xp))
+			}
+			for _, xn := range xe.NavigationProperties {
+				et.NavigationProperties = append(et.NavigationProperties, &NavigationProperty{
+					Name:           xn.Name,
+					Type:           xn.Type,
+					Nullable:       parseBool(xn.Nullable, true),
+					Partner:        xn.Partner,
+					ContainsTarget: parseBool(xn.ContainsTarget, false),
+				})
+			}
+			schema.EntityTypes[et.QualifiedName] = et
+		}
+
+		for _, xc := range xs.ComplexTypes {
+			ct := &ComplexType{
+				Name:          xc.Name,
+				QualifiedName: xs.Namespace + "." + xc.Name,
+			}
+			for _, xp := range xc.Properties {
+				ct.Properties = append(ct.Properties, newProperty(xp))
+			}
+			schema.ComplexTypes[ct.QualifiedName] = ct
+		}
+
+		for _, xen := range xs.EnumTypes {
+			en := &EnumType{
+				Name:          xen.Name,
+				QualifiedName: xs.Namespace + "." + xen.Name,
+				IsFlags:       parseBool(xen.IsFlags, false),
+			}
+			for _, m := range xen.Members {
+				en.Members = append(en.Members, EnumMember{Name: m.Name, Value: m.Value})
+			}
+			schema.EnumTypes[en.QualifiedName] = en
+		}
+
+		for _, xa := range xs.Actions {
+			schema.Actions = append(schema.Actions, newOperation(xa))
+		}
+		for _, xf := range xs.Functions {
+			schema.Functions = append(schema.Functions, newOperation(xf))
+		}
+
+		for _, xc := range xs.Containers {
+			for _, xset := range xc.EntitySets {
+				set := &EntitySet{
+					Name:               xset.Name,
+					EntityType:         xset.EntityType,
+					NavigationBindings: make(map[string]string),
+				}
+				for _, b := range xset.Bindings {
+					set.NavigationBindings[b.Path] = b.Target
+				}
+				schema.EntitySets[set.Name] = set
+			}
+			for _, xsg := range xc.Singletons {
+				schema.Singletons[xsg.Name] = &Singleton{Name: xsg.Name, Type: xsg.Type}
+			}
+			for _, ai := range xc.ActionImports {
+				schema.ActionImports = append(schema.ActionImports, OperationImport{Name: ai.Name, Operation: ai.Action, EntitySet: ai.EntitySet})
+			}
+			for _, fi := range xc.FunctionImports {
+				schema.FunctionImports = append(schema.FunctionImports, OperationImport{Name: fi.Name, Operation: fi.Function, EntitySet: fi.EntitySet})
+			}
+		}
+	}
+
+	schema.resolveBaseTypes()
+
+	return schema, nil
+}
+
+// resolveBaseTypes copies inherited keys and properties into derived entity types
+// so that lookups never need to walk the type hierarchy.
+func (s *Schema) resolveBaseTypes() {
+	resolved := make(map[string]bool)
+	var resolve func(et *EntityType, depth int)
+	resolve = func(et *EntityType, depth int) {
+		if resolved[et.QualifiedName] || et.BaseType == "" || depth > 16 {
+			resolved[et.QualifiedName] = true
+			return
+		}
+		base, ok := s.EntityTypes[s.qualify(et.BaseType)]
+		if !ok {
+			resolved[et.QualifiedName] = true
+			return
+		}
+		resolve(base, depth+1)
+		if len(et.Key) == 0 {
+			et.Key = append([]string(nil), base.Key...)
+		}
+		et.Properties = append(append([]*Property(nil), base.Properties...), et.Properties...)
+		et.NavigationProperties = append(append([]*NavigationProperty(nil), base.NavigationProperties...), et.NavigationProperties...)
+		resolved[et.QualifiedName] = true
+	}
+	for _, et := range s.EntityTypes {
+		resolve(et, 0)
+	}
+}
+
+// qualify replaces a schema alias prefix with the full namespace
+func (s *Schema) qualify(name string) string {
+	if i := strings.LastIndex(name, "."); i > 0 {
+		if ns, ok := s.aliases[name[:i]]; ok {
+			return ns + name[i:]
+		}
+	}
+	return name
+}
+
+// EntitySet looks up an entity set by name. An exact match is preferred, then a
+// case-insensitive match, since callers frequently get the casing wrong.
+func (s *Schema) EntitySet(name string) (*EntitySet, bool) {
+	if set, ok := s.EntitySets[name]; ok {
+		return set, true
+	}
+	for setName, set := range s.EntitySets {
+		if strings.EqualFold(setName, name) {
+			return set, true
+		}
+	}
+	return nil, false
+}
+
+// EntityType resolves a (possibly aliased) qualified type name
+func (s *Schema) EntityType(name string) (*EntityType, bool) {
+	et, ok := s.EntityTypes[s.qualify(name)]
+	return et, ok
+}
+
+// EntityTypeOf returns the entity type of the named entity set
+func (s *Schema) EntityTypeOf(entitySet string) (*EntityType, error) {
+	set, ok := s.EntitySet(entitySet)
+	if !ok {
+		return nil, fmt.Errorf("entity set '%s' not found in metadata", entitySet)
+	}
+	et, ok := s.EntityType(set.EntityType)
+	if !ok {
+		return nil, fmt.Errorf("entity type '%s' of entity set '%s' not found in metadata", set.EntityType, set.Name)
+	}
+	return et, nil
+}
+
+// ComplexType resolves a (possibly aliased) qualified complex type name
+func (s *Schema) ComplexType(name string) (*ComplexType, bool) {
+	ct, ok := s.ComplexTypes[s.qualify(name)]
+	return ct, ok
+}
+
+// EnumType resolves a (possibly aliased) qualified enum type name
+func (s *Schema) EnumType(name string) (*EnumType, bool) {
+	en, ok := s.EnumTypes[s.qualify(name)]
+	return en, ok
+}
+
+// EntitySetNames returns all entity set names in sorted order
+func (s *Schema) EntitySetNames() []string {
+	names := make([]string, 0, len(s.EntitySets))
+	for name := range s.EntitySets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Property returns the structural property with the given name
+func (et *EntityType) Property(name string) (*Property, bool) {
+	for _, p := range et.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Property returns the property of the complex type with the given name
+func (ct *ComplexType) Property(name string) (*Property, bool) {
+	for _, p := range ct.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// NavigationProperty returns the navigation property with the given name
+func (et *EntityType) NavigationProperty(name string) (*NavigationProperty, bool) {
+	for _, n := range et.NavigationProperties {
+		if n.Name == name {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// KeyProperties returns the key properties in declaration order
+func (et *EntityType) KeyProperties() []*Property {
+	keys := make([]*Property, 0, len(et.Key))
+	for _, name := range et.Key {
+		if p, ok := et.Property(name); ok {
+			keys = append(keys, p)
+		}
+	}
+	return keys
+}
+
+// IsCollection reports whether the navigation property targets a collection
+func (n *NavigationProperty) IsCollection() bool {
+	return strings.HasPrefix(n.Type, "Collection(")
+}
+
+// TargetType returns the target entity type name without the Collection() wrapper
+func (n *NavigationProperty) TargetType() string {
+	return unwrapCollection(n.Type)
+}
+
+func unwrapCollection(t string) string {
+	if strings.HasPrefix(t, "Collection(") && strings.HasSuffix(t, ")") {
+		return t[len("Collection(") : len(t)-1]
+	}
+	return t
+}
+
+func newProperty(xp xmlProperty) *Property {
+	return &Property{
+		Name:      xp.Name,
+		Type:      xp.Type,
+		Nullable:  parseBool(xp.Nullable, true),
+		MaxLength: xp.MaxLength,
+		Precision: xp.Precision,
+		Scale:     xp.Scale,
+	}
+}
+
+func newOperation(xo xmlOperation) *Operation {
+	op := &Operation{
+		Name:       xo.Name,
+		IsBound:    parseBool(xo.IsBound, false),
+		ReturnType: xo.ReturnType.Type,
+	}
+	for _, p := range xo.Parameters {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     p.Name,
+			Type:     p.Type,
+			Nullable: parseBool(p.Nullable, true),
+		})
+	}
+	return op
+}
+
+// parseBool parses a CSDL boolean facet, returning def when the facet is absent
+func parseBool(value string, def bool) bool {
+	switch strings.ToLower(value) {
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		return def
+	}
+}
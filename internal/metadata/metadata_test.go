@@ -0,0 +1,377 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testEDMX = `<?xml version="1.0" encoding="utf-8"?>
+<edmx:Edmx Version="4.0" xmlns:edmx="http://docs.oasis-open.org/odata/ns/edmx">
+  <edmx:DataServices>
+    <Schema Namespace="NAV" Alias="N" xmlns="http://docs.oasis-open.org/odata/ns/edm">
+      <EnumType Name="Document_Type">
+        <Member Name="Quote" Value="0" />
+        <Member Name="Order" Value="1" />
+      </EnumType>
+      <EntityType Name="Customer">
+        <Key>
+          <PropertyRef Name="No" />
+        </Key>
+        <Property Name="No" Type="Edm.String" Nullable="false" MaxLength="20" />
+        <Property Name="Name" Type="Edm.String" MaxLength="100" />
+        <Property Name="Balance_LCY" Type="Edm.Decimal" Scale="Variable" />
+        <NavigationProperty Name="SalesOrders" Type="Collection(NAV.SalesOrder)" />
+      </EntityType>
+      <EntityType Name="SalesOrder">
+        <Key>
+          <PropertyRef Name="Document_Type" />
+          <PropertyRef Name="No" />
+        </Key>
+        <Property Name="Document_Type" Type="NAV.Document_Type" Nullable="false" />
+        <Property Name="No" Type="Edm.String" Nullable="false" MaxLength="20" />
+        <Property Name="Posting_Date" Type="Edm.Date" />
+        <NavigationProperty Name="Customer" Type="NAV.Customer" />
+      </EntityType>
+      <EntityType Name="SpecialOrder" BaseType="N.SalesOrder">
+        <Property Name="Priority" Type="Edm.Int32" />
+      </EntityType>
+      <Action Name="Release" IsBound="true">
+        <Parameter Name="bindingParameter" Type="NAV.SalesOrder" />
+      </Action>
+      <EntityContainer Name="NAV">
+        <EntitySet Name="Customers" EntityType="NAV.Customer">
+          <NavigationPropertyBinding Path="SalesOrders" Target="SalesOrders" />
+        </EntitySet>
+        <EntitySet Name="SalesOrders" EntityType="NAV.SalesOrder" />
+        <Singleton Name="CompanyInformation" Type="NAV.Customer" />
+        <FunctionImport Name="GetTotals" Function="NAV.GetTotals" />
+      </EntityContainer>
+    </Schema>
+  </edmx:DataServices>
+</edmx:Edmx>`
+
+func TestParse(t *testing.T) {
+	schema, err := Parse(strings.NewReader(testEDMX))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if schema.Namespace != "NAV" {
+		t.Errorf("Namespace = %v, want NAV", schema.Namespace)
+	}
+	if len(schema.EntitySets) != 2 {
+		t.Errorf("EntitySets = %d, want 2", len(schema.EntitySets))
+	}
+	if len(schema.Singletons) != 1 {
+		t.Errorf("Singletons = %d, want 1", len(schema.Singletons))
+	}
+	if len(schema.FunctionImports) != 1 {
+		t.Errorf("FunctionImports = %d, want 1", len(schema.FunctionImports))
+	}
+
+	et, err := schema.EntityTypeOf("SalesOrders")
+	if err != nil {
+		t.Fatalf("EntityTypeOf() error = %v", err)
+	}
+	if strings.Join(et.Key, ",") != "Document_Type,No" {
+		t.Errorf("Key = %v, want Document_Type,No", et.Key)
+	}
+	no, ok := et.Property("No")
+	if !ok {
+		t.Fatal("Property(No) not found")
+	}
+	if no.Nullable {
+		t.Error("No should not be nullable")
+	}
+	if no.MaxLength != "20" {
+		t.Errorf("MaxLength = %v, want 20", no.MaxLength)
+	}
+}
+
+func TestParse_BaseType(t *testing.T) {
+	schema, err := Parse(strings.NewReader(testEDMX))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	et, ok := schema.EntityType("NAV.SpecialOrder")
+	if !ok {
+		t.Fatal("EntityType(NAV.SpecialOrder) not found")
+	}
+	if len(et.Key) != 2 {
+		t.Errorf("inherited Key = %v, want 2 fields", et.Key)
+	}
+	if _, ok := et.Property("Posting_Date"); !ok {
+		t.Error("inherited property Posting_Date not found")
+	}
+	if _, ok := et.Property("Priority"); !ok {
+		t.Error("own property Priority not found")
+	}
+}
+
+func TestParse_InvalidXML(t *testing.T) {
+	if _, err := Parse(strings.NewReader("not xml")); err == nil {
+		t.Fatal("Parse() error = nil, want error")
+	}
+	if _, err := Parse(strings.NewReader(`<Edmx><DataServices></DataServices></Edmx>`)); err == nil {
+		t.Fatal("Parse() error = nil for empty schema, want error")
+	}
+}
+
+func TestSchema_EntitySet_CaseInsensitive(t *testing.T) {
+	schema, _ := Parse(strings.NewReader(testEDMX))
+
+	set, ok := schema.EntitySet("customers")
+	if !ok {
+		t.Fatal("EntitySet(customers) not found")
+	}
+	if set.Name != "Customers" {
+		t.Errorf("Name = %v, want Customers", set.Name)
+	}
+}
+
+func TestSchema_Describe(t *testing.T) {
+	schema, _ := Parse(strings.NewReader(testEDMX))
+
+	desc, err := schema.Describe("SalesOrders")
+	if err != nil {
+		t.Fatalf("Describe() error = %v", err)
+	}
+	if desc.EntityType != "NAV.SalesOrder" {
+		t.Errorf("EntityType = %v, want NAV.SalesOrder", desc.EntityType)
+	}
+	if len(desc.Fields) != 3 {
+		t.Fatalf("Fields = %d, want 3", len(desc.Fields))
+	}
+	docType := desc.Fields[0]
+	if !docType.Key {
+		t.Error("Document_Type should be marked as key")
+	}
+	if strings.Join(docType.Enum, ",") != "Quote,Order" {
+		t.Errorf("Enum = %v, want Quote,Order", docType.Enum)
+	}
+	if len(desc.BoundActions) != 1 || desc.BoundActions[0] != "Release" {
+		t.Errorf("BoundActions = %v, want [Release]", desc.BoundActions)
+	}
+
+	customers, _ := schema.Describe("Customers")
+	if len(customers.Navigation) != 1 {
+		t.Fatalf("Navigation = %d, want 1", len(customers.Navigation))
+	}
+	nav := customers.Navigation[0]
+	if !nav.Collection || nav.TargetType != "NAV.SalesOrder" || nav.EntitySet != "SalesOrders" {
+		t.Errorf("Navigation = %+v, want collection of NAV.SalesOrder bound to SalesOrders", nav)
+	}
+
+	if _, err := schema.Describe("Missing"); err == nil {
+		t.Error("Describe(Missing) error = nil, want error")
+	}
+}
+
+func TestSchema_ValidatePayload(t *testing.T) {
+	schema, _ := Parse(strings.NewReader(testEDMX))
+
+	problems, err := schema.ValidatePayload("SalesOrders", map[string]interface{}{
+		"Document_Type": "Order",
+		"No":            "1001",
+		"Posting_Date":  "2024-01-31",
+		"Customer":      map[string]interface{}{"No": "10000"},
+		"@odata.etag":   "W/\"1\"",
+	})
+	if err != nil || len(problems) != 0 {
+		t.Errorf("ValidatePayload(valid) = %v, %v; want no problems", problems, err)
+	}
+
+	problems, _ = schema.ValidatePayload("Customers", map[string]interface{}{
+		"No":          nil,
+		"Nmae":        "Adatum",
+		"Balance_LCY": "12.5",
+	})
+	if len(problems) != 2 || !strings.Contains(problems[0], "'No': must not be null") || !strings.Contains(problems[1], "Did you mean: Name") {
+		t.Errorf("ValidatePayload(Customers) = %v", problems)
+	}
+
+	problems, _ = schema.ValidatePayload("SalesOrders", map[string]interface{}{
+		"Document_Type": "Invoice",
+		"No":            strings.Repeat("9", 21),
+		"Posting_Date":  20240131.0,
+	})
+	if len(problems) != 3 {
+		t.Errorf("ValidatePayload(SalesOrders) = %v, want enum, length and type problems", problems)
+	}
+
+	if _, err := schema.ValidatePayload("Missing", nil); err == nil {
+		t.Error("ValidatePayload(Missing) error = nil, want error")
+	}
+}
+
+func TestSchema_ValidatePayload_Nested(t *testing.T) {
+	schema, _ := Parse(strings.NewReader(testEDMX))
+
+	problems, err := schema.ValidatePayload("Customers", map[string]interface{}{
+		"No": "10000",
+		"SalesOrders": []interface{}{
+			map[string]interface{}{"Document_Type": "Order", "No": "1001"},
+			map[string]interface{}{"Document_Type": "Order", "No": "1002", "Postng_Date": "2024-01-31"},
+			"1003",
+		},
+	})
+	if err != nil {
+		t.Fatalf("ValidatePayload() error = %v", err)
+	}
+	if len(problems) != 2 || !strings.HasPrefix(problems[0], "field 'SalesOrders[2]': expected a SalesOrder object") || !strings.Contains(problems[1], "'SalesOrders[1].Postng_Date'") {
+		t.Errorf("ValidatePayload(nested) = %v", problems)
+	}
+
+	problems, _ = schema.ValidatePayload("Customers", map[string]interface{}{
+		"SalesOrders": map[string]interface{}{"No": "1001"},
+	})
+	if len(problems) != 1 || !strings.Contains(problems[0], "expected an array of SalesOrder objects") {
+		t.Errorf("ValidatePayload(object for collection) = %v", problems)
+	}
+
+	problems, err = schema.ValidateUpdate("Customers", map[string]interface{}{
+		"Name":        "Adatum",
+		"SalesOrders": []interface{}{map[string]interface{}{"Document_Type": "Order", "No": "1001"}},
+	})
+	if err != nil || len(problems) != 1 || !strings.Contains(problems[0], "'SalesOrders': navigation property cannot be set in an update") {
+		t.Errorf("ValidateUpdate(nested) = %v, %v", problems, err)
+	}
+}
+
+func TestSchema_Summary(t *testing.T) {
+	schema, _ := Parse(strings.NewReader(testEDMX))
+
+	summary := schema.Summary()
+	if strings.Join(summary.EntitySets, ",") != "Customers,SalesOrders" {
+		t.Errorf("EntitySets = %v, want Customers,SalesOrders", summary.EntitySets)
+	}
+	if summary.EntityTypeCount != 3 {
+		t.Errorf("EntityTypeCount = %d, want 3", summary.EntityTypeCount)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"Customers", "SalesOrders", "Items", "Vendors"}
+
+	got := Suggest("Custmers", candidates, 3)
+	if len(got) == 0 || got[0] != "Customers" {
+		t.Errorf("Suggest(Custmers) = %v, want Customers first", got)
+	}
+	if got := Suggest("Zzzzzzzz", candidates, 3); len(got) != 0 {
+		t.Errorf("Suggest(Zzzzzzzz) = %v, want none", got)
+	}
+}
+
+func TestCache_Get(t *testing.T) {
+	cache := NewCache(0)
+	loads := 0
+	load := func(ctx context.Context) (*Schema, error) {
+		loads++
+		return Parse(strings.NewReader(testEDMX))
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(ctx, "tenant/Production", load); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loads = %d, want 1", loads)
+	}
+
+	if _, err := cache.Get(ctx, "tenant/Sandbox", load); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("loads = %d after second environment, want 2", loads)
+	}
+
+	cache.Invalidate("tenant/Production")
+	_, _ = cache.Get(ctx, "tenant/Production", load)
+	if loads != 3 {
+		t.Errorf("loads = %d after invalidate, want 3", loads)
+	}
+}
+
+func TestCache_Get_Error(t *testing.T) {
+	cache := NewCache(0)
+	wantErr := errors.New("boom")
+
+	_, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*Schema, error) {
+		return nil, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Get() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestCache_Get_Concurrent(t *testing.T) {
+	cache := NewCache(0)
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (*Schema, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return Parse(strings.NewReader(testEDMX))
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.Get(context.Background(), "tenant/Production", load)
+			errs <- err
+		}()
+	}
+	// Give every caller time to find the load in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("loads = %d, want 1", n)
+	}
+}
+
+func TestCache_Get_CanceledLoad(t *testing.T) {
+	cache := NewCache(0)
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_, _ = cache.Get(ctx, "key", func(ctx context.Context) (*Schema, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	}()
+	<-started
+
+	// A waiter whose own context is alive loads again instead of failing
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(context.Background(), "key", func(ctx context.Context) (*Schema, error) {
+			return Parse(strings.NewReader(testEDMX))
+		})
+		done <- err
+	}()
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Get() error = %v, want the schema", err)
+	}
+}
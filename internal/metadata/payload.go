@@ -0,0 +1,224 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidatePayload checks a create payload against the entity type of an
+// entity set: unknown fields, values that do not fit the declared EDM
+// types, nulls in non-nullable fields and strings over MaxLength. Navigation
+// properties hold nested entities for a deep insert, e.g. a sales order with
+// its lines, and are checked against their target entity type; problems in
+// them name the path, such as SalesLines[0].Quantity. OData annotations
+// (@odata.etag, ...) are accepted as they are. It returns one message per
+// problem, sorted, or nil.
+func (s *Schema) ValidatePayload(entitySet string, payload map[string]interface{}) ([]string, error) {
+	return s.validatePayload(entitySet, payload, true)
+}
+
+// ValidateUpdate checks an update payload like ValidatePayload, except that
+// navigation properties are problems: a PATCH only changes the fields of the
+// entity itself, nested entities cannot be written with it.
+func (s *Schema) ValidateUpdate(entitySet string, payload map[string]interface{}) ([]string, error) {
+	return s.validatePayload(entitySet, payload, false)
+}
+
+func (s *Schema) validatePayload(entitySet string, payload map[string]interface{}, deep bool) ([]string, error) {
+	et, err := s.EntityTypeOf(entitySet)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := s.validateEntity(et, payload, "", deep)
+	sort.Strings(problems)
+	return problems, nil
+}
+
+// validateEntity checks the fields of an entity payload; prefix is the path of
+// a nested entity, e.g. "SalesLines[0].", and deep accepts nested entities in
+// navigation properties
+func (s *Schema) validateEntity(et *EntityType, payload map[string]interface{}, prefix string, deep bool) []string {
+	var problems []string
+	for name, value := range payload {
+		if strings.Contains(name, "@") {
+			continue
+		}
+		if nav, ok := et.NavigationProperty(name); ok {
+			if !deep {
+				problems = append(problems, fmt.Sprintf("field '%s%s': navigation property cannot be set in an update; write the related entities through their own entity set", prefix, name))
+				continue
+			}
+			problems = append(problems, s.validateNested(nav, value, prefix+name)...)
+			continue
+		}
+		prop, ok := et.Property(name)
+		if !ok {
+			msg := fmt.Sprintf("unknown field '%s%s' for entity type '%s'", prefix, name, et.QualifiedName)
+			candidates := make([]string, 0, len(et.Properties)+len(et.NavigationProperties))
+			for _, p := range et.Properties {
+				candidates = append(candidates, p.Name)
+			}
+			for _, n := range et.NavigationProperties {
+				candidates = append(candidates, n.Name)
+			}
+			if suggestions := Suggest(name, candidates, 3); len(suggestions) > 0 {
+				msg = fmt.Sprintf("%s. Did you mean: %s?", msg, strings.Join(suggestions, ", "))
+			}
+			problems = append(problems, msg)
+			continue
+		}
+		if problem := s.checkValue(prop, value); problem != "" {
+			problems = append(problems, fmt.Sprintf("field '%s%s': %s", prefix, name, problem))
+		}
+	}
+	return problems
+}
+
+// validateNested checks the entities nested in a navigation property: an
+// array of objects for a collection, an object otherwise
+func (s *Schema) validateNested(nav *NavigationProperty, value interface{}, path string) []string {
+	target, ok := s.EntityType(nav.TargetType())
+	if !ok {
+		return nil
+	}
+	if value == nil {
+		if !nav.Nullable || nav.IsCollection() {
+			return []string{fmt.Sprintf("field '%s': must not be null", path)}
+		}
+		return nil
+	}
+
+	if !nav.IsCollection() {
+		entity, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("field '%s': expected a %s object, got %s", path, target.Name, jsonKind(value))}
+		}
+		return s.validateEntity(target, entity, path+".", true)
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("field '%s': expected an array of %s objects, got %s", path, target.Name, jsonKind(value))}
+	}
+	var problems []string
+	for i, item := range items {
+		entity, ok := item.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field '%s[%d]': expected a %s object, got %s", path, i, target.Name, jsonKind(item)))
+			continue
+		}
+		problems = append(problems, s.validateEntity(target, entity, fmt.Sprintf("%s[%d].", path, i), true)...)
+	}
+	return problems
+}
+
+// checkValue returns why a JSON value does not fit a property, or ""
+func (s *Schema) checkValue(prop *Property, value interface{}) string {
+	if value == nil {
+		if !prop.Nullable {
+			return "must not be null"
+		}
+		return ""
+	}
+
+	if strings.HasPrefix(prop.Type, "Collection(") {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Sprintf("expected an array of %s", unwrapCollection(prop.Type))
+		}
+		item := *prop
+		item.Type = unwrapCollection(prop.Type)
+		for i, v := range items {
+			if problem := s.checkValue(&item, v); problem != "" {
+				return fmt.Sprintf("item %d: %s", i, problem)
+			}
+		}
+		return ""
+	}
+
+	switch prop.Type {
+	case "Edm.String":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Sprintf("expected a string, got %s", jsonKind(value))
+		}
+		if max, err := strconv.Atoi(prop.MaxLength); err == nil && len([]rune(text)) > max {
+			return fmt.Sprintf("longer than the maximum length of %d", max)
+		}
+	case "Edm.Guid", "Edm.Date", "Edm.DateTimeOffset", "Edm.TimeOfDay", "Edm.Duration", "Edm.Binary":
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("expected a %s string, got %s", strings.TrimPrefix(prop.Type, "Edm."), jsonKind(value))
+		}
+	case "Edm.Boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("expected true or false, got %s", jsonKind(value))
+		}
+	case "Edm.Byte", "Edm.SByte", "Edm.Int16", "Edm.Int32", "Edm.Int64":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Sprintf("expected an integer, got %s", jsonKind(value))
+		}
+	case "Edm.Decimal", "Edm.Double", "Edm.Single":
+		switch v := value.(type) {
+		case float64:
+		case string:
+			// Decimals may be sent as strings to keep their precision
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Sprintf("expected a number, got %q", v)
+			}
+		default:
+			return fmt.Sprintf("expected a number, got %s", jsonKind(value))
+		}
+	default:
+		if enum, ok := s.EnumType(prop.Type); ok {
+			name, ok := value.(string)
+			if !ok {
+				return fmt.Sprintf("expected one of the %s values, got %s", enum.Name, jsonKind(value))
+			}
+			members := make([]string, 0, len(enum.Members))
+			for _, member := range enum.Members {
+				if member.Name == name {
+					return ""
+				}
+				members = append(members, member.Name)
+			}
+			return fmt.Sprintf("'%s' is not a %s value (%s)", name, enum.Name, strings.Join(members, ", "))
+		}
+		if complexType, ok := s.ComplexType(prop.Type); ok {
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Sprintf("expected an object, got %s", jsonKind(value))
+			}
+			for name, v := range fields {
+				field, ok := complexType.Property(name)
+				if !ok {
+					return fmt.Sprintf("unknown field '%s' of %s", name, complexType.Name)
+				}
+				if problem := s.checkValue(field, v); problem != "" {
+					return fmt.Sprintf("%s: %s", name, problem)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// jsonKind names the JSON type of a decoded value
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}